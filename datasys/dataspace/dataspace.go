@@ -0,0 +1,50 @@
+package dataspace
+
+import (
+	"encoding/json"
+	"io"
+
+	xos "github.com/balamurugana/goat/pkg/os"
+)
+
+// UnknownSize is size passed to SaveTempFile() for data whose size is known only at EOF, e.g. S3 chunked upload
+// without content length. Data is read until EOF; size of the uploaded part is returned by ListParts().
+const UnknownSize = xos.UnknownSize
+
+type Part struct {
+	ID   string          `json:"id"`
+	Size uint64          `json:"size"`
+	Meta json.RawMessage `json:"meta,omitempty"` // Opaque metadata of upper layer e.g. erasure info of the part.
+}
+
+type DataInfo struct {
+	Parts []Part `json:"parts"`
+	Size  uint64 `json:"size"`
+}
+
+// DataReader reads data range requested by Get(). Offsets of Seek() and ReadAt() are relative to the range.
+type DataReader interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+}
+
+// DataSpace stores write-once data uploaded in parts. Data is first saved as temporary file, moved into an
+// upload as part and made available by data ID on CompleteUpload(). It is implemented by dataspace/disk on a
+// single disk and by dataspace/erasure on a set of shard disks, so that either may be stacked under namespace.
+type DataSpace interface {
+	SaveTempFile(filename string, data io.Reader, size uint64, bitrotProtection bool) (checksum string, err error)
+	RemoveTempFile(filename string, bitrotProtection bool) error
+
+	InitUpload(uploadID UploadID) error
+	UploadPart(uploadID UploadID, partID, tempFile string) error
+	UploadPartCopy(uploadID UploadID, partID string, srcID DataID, offset int64, length uint64) (etag string, err error)
+	AbortUpload(uploadID UploadID) error
+	CompleteUpload(dataID DataID, uploadID UploadID, parts []Part) error
+	ListParts(uploadID UploadID) ([]Part, error)
+
+	Get(dataID DataID, offset int64, length uint64) (DataReader, error)
+	GetMetadata(dataID DataID) (*DataInfo, error)
+	Delete(dataID DataID) error
+	Copy(dataID, srcID DataID, offset int64, length uint64) error
+}
@@ -0,0 +1,284 @@
+// Package dataspacetest implements conformance tests of dataspace.DataSpace implementations.
+package dataspacetest
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/balamurugana/goat/datasys/dataspace"
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	xhash "github.com/balamurugana/goat/pkg/hash"
+)
+
+// NewDataSpace function type returns empty dataspace and function to clean it up.
+type NewDataSpace func(t *testing.T) (ds dataspace.DataSpace, cleanup func())
+
+func randReader() io.Reader {
+	return rand.New(rand.NewSource(271828))
+}
+
+// Parts uploaded by upload() with checksums of ranges of their data.
+var (
+	testParts = []dataspace.Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}
+
+	testRanges = []struct {
+		offset   int64
+		length   uint64
+		checksum string
+	}{
+		{0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
+		{10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
+		{16279, 10992, "60c7436deea126319878ecbf43b853f39f3451dccacde02b4e6a66082e9d168a"},
+		{12958, 10992, "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"},
+	}
+)
+
+// upload uploads testParts to new upload ID and returns the upload ID.
+func upload(t *testing.T, ds dataspace.DataSpace) dataspace.UploadID {
+	uploadID := dataspace.NewUploadID()
+	if err := ds.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range testParts {
+		tempFile := dataspace.NewTempFilename()
+		if _, err := ds.SaveTempFile(tempFile, randReader(), part.Size, true); err != nil {
+			t.Fatalf("%v: %v", part.ID, err)
+		}
+
+		if err := ds.UploadPart(uploadID, part.ID, tempFile); err != nil {
+			t.Fatalf("%v: %v", part.ID, err)
+		}
+	}
+
+	return uploadID
+}
+
+// create creates data of testParts and returns its data ID.
+func create(t *testing.T, ds dataspace.DataSpace) dataspace.DataID {
+	uploadID := upload(t, ds)
+	parts, err := ds.ListParts(uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataID := dataspace.NewDataID()
+	if err = ds.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	return dataID
+}
+
+func checksum(t *testing.T, ds dataspace.DataSpace, dataID dataspace.DataID, offset int64, length uint64) string {
+	dr, err := ds.Get(dataID, offset, length)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dr.Close()
+
+	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+	n, err := io.Copy(hasher, dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uint64(n) != length {
+		t.Fatalf("mismatch: length: expected: %v, got: %v", length, n)
+	}
+
+	return hasher.HexSum(nil)
+}
+
+func testListParts(t *testing.T, ds dataspace.DataSpace) {
+	if _, err := ds.ListParts(dataspace.NewUploadID()); err != xerrors.ErrUploadIDNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrUploadIDNotFound, err)
+	}
+
+	parts, err := ds.ListParts(upload(t, ds))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) != len(testParts) {
+		t.Fatalf("mismatch: parts: expected: %v, got: %v", testParts, parts)
+	}
+
+	for i := range parts {
+		if parts[i].ID != testParts[i].ID || parts[i].Size != testParts[i].Size {
+			t.Fatalf("mismatch: part: expected: %+v, got: %+v", testParts[i], parts[i])
+		}
+	}
+}
+
+func testGet(t *testing.T, ds dataspace.DataSpace) {
+	if _, err := ds.GetMetadata(dataspace.NewDataID()); err != xerrors.ErrDataIDNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+
+	dataID := create(t, ds)
+
+	dataInfo, err := ds.GetMetadata(dataID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dataInfo.Size != testParts[0].Size+testParts[1].Size {
+		t.Fatalf("mismatch: size: expected: %v, got: %v", testParts[0].Size+testParts[1].Size, dataInfo.Size)
+	}
+
+	for _, r := range testRanges {
+		if got := checksum(t, ds, dataID, r.offset, r.length); got != r.checksum {
+			t.Fatalf("range %v-%v: expected: %v, got: %v", r.offset, r.length, r.checksum, got)
+		}
+	}
+
+	dr, err := ds.Get(dataID, 0, dataInfo.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dr.Close()
+
+	r := testRanges[3]
+	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+	if _, err = io.Copy(hasher, io.NewSectionReader(dr, r.offset, int64(r.length))); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hasher.HexSum(nil); got != r.checksum {
+		t.Fatalf("ReadAt: expected: %v, got: %v", r.checksum, got)
+	}
+}
+
+func testUploadPartCopy(t *testing.T, ds dataspace.DataSpace) {
+	srcID := create(t, ds)
+	r := testRanges[3]
+
+	uploadID := dataspace.NewUploadID()
+	if err := ds.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.UploadPartCopy(uploadID, "1", srcID, r.offset, r.length); err != nil {
+		t.Fatal(err)
+	}
+
+	parts, err := ds.ListParts(uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataID := dataspace.NewDataID()
+	if err = ds.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := checksum(t, ds, dataID, 0, r.length); got != r.checksum {
+		t.Fatalf("mismatch: checksum: expected: %v, got: %v", r.checksum, got)
+	}
+}
+
+func testCopy(t *testing.T, ds dataspace.DataSpace) {
+	srcID := create(t, ds)
+
+	// Whole part and partial parts.
+	for _, r := range testRanges[2:] {
+		dataID := dataspace.NewDataID()
+		if err := ds.Copy(dataID, srcID, r.offset, r.length); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := checksum(t, ds, dataID, 0, r.length); got != r.checksum {
+			t.Fatalf("range %v-%v: expected: %v, got: %v", r.offset, r.length, r.checksum, got)
+		}
+
+		if err := ds.Copy(dataID, srcID, r.offset, r.length); err == nil {
+			t.Fatalf("mismatch: expected: <error>, got: <nil>")
+		}
+	}
+}
+
+func testDelete(t *testing.T, ds dataspace.DataSpace) {
+	dataID := create(t, ds)
+	if err := ds.Delete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.GetMetadata(dataID); err != xerrors.ErrDataIDNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+
+	if _, err := ds.Get(dataID, 0, 10); err == nil {
+		t.Fatalf("mismatch: expected: <error>, got: <nil>")
+	}
+
+	if err := ds.Delete(dataID); err == nil {
+		t.Fatalf("mismatch: expected: <error>, got: <nil>")
+	}
+}
+
+func testAbortUpload(t *testing.T, ds dataspace.DataSpace) {
+	uploadID := upload(t, ds)
+	if err := ds.AbortUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.ListParts(uploadID); err != xerrors.ErrUploadIDNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrUploadIDNotFound, err)
+	}
+
+	if err := ds.CompleteUpload(dataspace.NewDataID(), uploadID, testParts); err == nil {
+		t.Fatalf("mismatch: expected: <error>, got: <nil>")
+	}
+}
+
+func testRemoveTempFile(t *testing.T, ds dataspace.DataSpace) {
+	tempFile := dataspace.NewTempFilename()
+	if _, err := ds.SaveTempFile(tempFile, randReader(), 16279, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ds.RemoveTempFile(tempFile, true); err != nil {
+		t.Fatal(err)
+	}
+
+	uploadID := dataspace.NewUploadID()
+	if err := ds.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ds.UploadPart(uploadID, "1", tempFile); err == nil {
+		t.Fatalf("mismatch: expected: <error>, got: <nil>")
+	}
+}
+
+// Run runs conformance tests on dataspaces returned by newDataSpace. Each test uses its own dataspace.
+func Run(t *testing.T, newDataSpace NewDataSpace) {
+	testCases := []struct {
+		name string
+		test func(t *testing.T, ds dataspace.DataSpace)
+	}{
+		{"ListParts", testListParts},
+		{"Get", testGet},
+		{"UploadPartCopy", testUploadPartCopy},
+		{"Copy", testCopy},
+		{"Delete", testDelete},
+		{"AbortUpload", testAbortUpload},
+		{"RemoveTempFile", testRemoveTempFile},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(
+			testCase.name,
+			func(t *testing.T) {
+				ds, cleanup := newDataSpace(t)
+				defer cleanup()
+
+				testCase.test(t, ds)
+			},
+		)
+	}
+}
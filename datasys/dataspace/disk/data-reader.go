@@ -4,22 +4,21 @@ import (
 	"errors"
 	"io"
 	"path"
+	"sync"
 
+	"github.com/balamurugana/goat/datasys/dataspace"
 	"github.com/balamurugana/goat/pkg/boundary"
 	xos "github.com/balamurugana/goat/pkg/os"
 )
 
-type Part struct {
-	ID   string `json:"id"`
-	Size uint64 `json:"size"`
-}
-
-type DataInfo struct {
-	Parts []Part `json:"parts"`
-	Size  uint64 `json:"size"`
-}
+type (
+	Part       = dataspace.Part
+	DataInfo   = dataspace.DataInfo
+	DataReader = dataspace.DataReader
+)
 
-func (dataInfo DataInfo) getParts(offset, length int64) (requiredParts []Part, bytesToSkip, bytesToRead int64) {
+// getParts returns parts of dataInfo covering given range.
+func getParts(dataInfo *DataInfo, offset, length int64) (requiredParts []Part, bytesToSkip, bytesToRead int64) {
 	partSizes := make([]int64, len(dataInfo.Parts))
 	for i, part := range dataInfo.Parts {
 		partSizes[i] = int64(part.Size)
@@ -30,15 +29,43 @@ func (dataInfo DataInfo) getParts(offset, length int64) (requiredParts []Part, b
 	return dataInfo.Parts[startPart:endPart], bytesToSkip, bytesToRead
 }
 
+// partCacheBlocks is number of verified blocks cached per part file opened for random access.
+const partCacheBlocks = 4
+
 type dataReader struct {
 	dataDir       string
 	dataInfo      *DataInfo
+	offset        int64
+	length        int64
+	pos           int64
 	requiredParts []Part
 	bytesToSkip   int64
 	bytesToRead   int64
 	rc            io.ReadCloser
 	index         int
 	err           error
+
+	// Part files opened by ReadAt() are kept open until Close().
+	partReaders      map[string]xos.FileReader
+	partReadersMutex sync.Mutex
+
+	openPart       func(partFile string, offset int64, length uint64) (io.ReadCloser, error)
+	openPartReader func(partFile string) (xos.FileReader, error)
+	release        func()
+}
+
+// setPos sets parts to be read by Read() from pos of the range.
+func (dr *dataReader) setPos(pos int64) {
+	dr.pos = pos
+	dr.index = 0
+	dr.err = nil
+	if pos >= dr.length {
+		dr.requiredParts = nil
+		dr.err = io.EOF
+		return
+	}
+
+	dr.requiredParts, dr.bytesToSkip, dr.bytesToRead = getParts(dr.dataInfo, dr.offset+pos, dr.length-pos)
 }
 
 func (dr *dataReader) Read(b []byte) (int, error) {
@@ -54,7 +81,6 @@ func (dr *dataReader) Read(b []byte) (int, error) {
 
 	if dr.rc == nil {
 		partFile := dr.requiredParts[dr.index].ID + ".part"
-		filename := path.Join(dr.dataDir, partFile)
 
 		offset := int64(0)
 		if dr.index == 0 {
@@ -66,7 +92,7 @@ func (dr *dataReader) Read(b []byte) (int, error) {
 			length = uint64(dr.bytesToRead)
 		}
 
-		if dr.rc, dr.err = xos.OpenFile(filename, offset, length, true); dr.err != nil {
+		if dr.rc, dr.err = dr.openPart(partFile, offset, length); dr.err != nil {
 			return 0, dr.err
 		}
 
@@ -75,7 +101,9 @@ func (dr *dataReader) Read(b []byte) (int, error) {
 
 	var n int
 
-	if n, dr.err = dr.rc.Read(b); dr.err != nil {
+	n, dr.err = dr.rc.Read(b)
+	dr.pos += int64(n)
+	if dr.err != nil {
 		dr.rc.Close()
 		dr.rc = nil
 
@@ -89,14 +117,126 @@ func (dr *dataReader) Read(b []byte) (int, error) {
 	return n, dr.err
 }
 
-func (dr *dataReader) Close() error {
+// Seek sets position of next Read(). Part file being read is closed.
+func (dr *dataReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += dr.pos
+	case io.SeekEnd:
+		offset += dr.length
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
 	if dr.rc != nil {
-		err := dr.rc.Close()
+		dr.rc.Close()
 		dr.rc = nil
-		return err
 	}
 
-	return nil
+	dr.setPos(offset)
+	return offset, nil
+}
+
+func (dr *dataReader) getPartReader(partID string) (xos.FileReader, error) {
+	dr.partReadersMutex.Lock()
+	defer dr.partReadersMutex.Unlock()
+
+	if fr, found := dr.partReaders[partID]; found {
+		return fr, nil
+	}
+
+	fr, err := dr.openPartReader(partID + ".part")
+	if err != nil {
+		return nil, err
+	}
+
+	dr.partReaders[partID] = fr
+	return fr, nil
+}
+
+// ReadAt reads len(b) bytes from offset off of the range. Only blocks of part files covering requested
+// bytes are read.
+func (dr *dataReader) ReadAt(b []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if off >= dr.length {
+		return 0, io.EOF
+	}
+
+	truncated := false
+	if remaining := dr.length - off; int64(len(b)) > remaining {
+		b = b[:remaining]
+		truncated = true
+	}
+
+	requiredParts, bytesToSkip, _ := getParts(dr.dataInfo, dr.offset+off, int64(len(b)))
+	for i, part := range requiredParts {
+		offset := int64(0)
+		if i == 0 {
+			offset = bytesToSkip
+		}
+
+		var fr xos.FileReader
+		if fr, err = dr.getPartReader(part.ID); err != nil {
+			return n, err
+		}
+
+		length := int64(part.Size) - offset
+		if length > int64(len(b)-n) {
+			length = int64(len(b) - n)
+		}
+
+		var m int
+		m, err = fr.ReadAt(b[n:int64(n)+length], offset)
+		n += m
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return n, err
+		}
+	}
+
+	if n < len(b) {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	if truncated {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (dr *dataReader) Close() (err error) {
+	if dr.rc != nil {
+		err = dr.rc.Close()
+		dr.rc = nil
+	}
+
+	dr.partReadersMutex.Lock()
+	for partID, fr := range dr.partReaders {
+		if cerr := fr.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(dr.partReaders, partID)
+	}
+	dr.partReadersMutex.Unlock()
+
+	if dr.release != nil {
+		dr.release()
+		dr.release = nil
+	}
+
+	return err
 }
 
 func newDataReader(dataDir string, dataInfo *DataInfo, offset int64, length uint64) (*dataReader, error) {
@@ -115,13 +255,20 @@ func newDataReader(dataDir string, dataInfo *DataInfo, offset int64, length uint
 		return nil, errors.New("insufficient data")
 	}
 
-	requiredParts, bytesToSkip, bytesToRead := dataInfo.getParts(offset, dataLength)
+	dr := &dataReader{
+		dataDir:     dataDir,
+		dataInfo:    dataInfo,
+		offset:      offset,
+		length:      dataLength,
+		partReaders: make(map[string]xos.FileReader),
+		openPart: func(partFile string, offset int64, length uint64) (io.ReadCloser, error) {
+			return xos.OpenFile(path.Join(dataDir, partFile), offset, length, true)
+		},
+		openPartReader: func(partFile string) (xos.FileReader, error) {
+			return xos.OpenFileReader(path.Join(dataDir, partFile), true, partCacheBlocks)
+		},
+	}
+	dr.setPos(0)
 
-	return &dataReader{
-		dataDir:       dataDir,
-		dataInfo:      dataInfo,
-		requiredParts: requiredParts,
-		bytesToSkip:   bytesToSkip,
-		bytesToRead:   bytesToRead,
-	}, nil
+	return dr, nil
 }
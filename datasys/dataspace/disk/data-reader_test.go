@@ -25,15 +25,15 @@ func TestDataReader(t *testing.T) {
 		length   uint64
 		checksum string
 	}{
-		{[]Part{{"1", 16279}}, 0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
-		{[]Part{{"3", 16279}, {"8", 10992}}, 0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
-		{[]Part{{"1", 16279}}, 10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
-		{[]Part{{"3", 16279}, {"8", 10992}}, 10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
-		{[]Part{{"1", 16279}}, 0, 16279, "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"},
-		{[]Part{{"3", 16279}, {"8", 10992}}, 16279, 10992, "60c7436deea126319878ecbf43b853f39f3451dccacde02b4e6a66082e9d168a"},
-		{[]Part{{"3", 16279}, {"8", 10992}}, 12958, 10992, "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"},
-		{[]Part{{"3", 16279}, {"8", 10992}, {"1", 25489}}, 12958, 17343, "f76f77b058eb962e5099062cccfcf5e9363cdb533a86563680f8488ee99f0cfa"},
-		{[]Part{{"3", 16279}, {"8", 10992}, {"1", 25489}}, 27271, 70, "f9b63a4a399ca9f26b15f7dc5987b1644b6054ac9c34f6c136c6576eb77d9956"},
+		{[]Part{{ID: "1", Size: 16279}}, 0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}, 0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
+		{[]Part{{ID: "1", Size: 16279}}, 10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}, 10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
+		{[]Part{{ID: "1", Size: 16279}}, 0, 16279, "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}, 16279, 10992, "60c7436deea126319878ecbf43b853f39f3451dccacde02b4e6a66082e9d168a"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}, 12958, 10992, "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}, {ID: "1", Size: 25489}}, 12958, 17343, "f76f77b058eb962e5099062cccfcf5e9363cdb533a86563680f8488ee99f0cfa"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}, {ID: "1", Size: 25489}}, 27271, 70, "f9b63a4a399ca9f26b15f7dc5987b1644b6054ac9c34f6c136c6576eb77d9956"},
 	}
 
 	for i, testCase := range testCases {
@@ -98,6 +98,33 @@ func TestDataReader(t *testing.T) {
 				if testCase.checksum != checksum {
 					t.Fatalf("expected: %v, got: %v", testCase.checksum, checksum)
 				}
+
+				// Random access and seek within the range.
+				hasher.Reset()
+				if _, err = io.Copy(hasher, io.NewSectionReader(dr, 0, int64(testCase.length))); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum = hasher.HexSum(nil); testCase.checksum != checksum {
+					t.Fatalf("ReadAt: expected: %v, got: %v", testCase.checksum, checksum)
+				}
+
+				if _, err = dr.Seek(0, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+
+				hasher.Reset()
+				if _, err = io.Copy(hasher, dr); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum = hasher.HexSum(nil); testCase.checksum != checksum {
+					t.Fatalf("Seek: expected: %v, got: %v", testCase.checksum, checksum)
+				}
+
+				if _, err = dr.ReadAt(make([]byte, 1), int64(testCase.length)); err != io.EOF {
+					t.Fatalf("expected: %v, got: %v", io.EOF, err)
+				}
 			},
 		)
 	}
@@ -0,0 +1,173 @@
+package disk
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	xos "github.com/balamurugana/goat/pkg/os"
+)
+
+// dataRef tracks open readers of a data ID and where its data directory currently lives. Deleted data still being
+// read is tracked by its trash entry name.
+type dataRef struct {
+	dataDir string
+	readers int
+	deleted bool
+}
+
+// acquireDataRef returns reference of given data ID with incremented reader count.
+func (disk *Disk) acquireDataRef(dataID DataID) (*dataRef, error) {
+	disk.refsMutex.Lock()
+	defer disk.refsMutex.Unlock()
+
+	ref, found := disk.refs[dataID.String()]
+	if !found {
+		dataDir := disk.getDataDir(dataID)
+		if !xos.Exist(dataDir) {
+			return nil, xerrors.ErrDataIDNotFound
+		}
+
+		ref = &dataRef{dataDir: dataDir}
+		disk.refs[dataID.String()] = ref
+	}
+
+	ref.readers++
+	return ref, nil
+}
+
+// releaseDataRef decrements reader count of given data ID. Deleted data stays in trash for the reaper or
+// PurgeTrash().
+func (disk *Disk) releaseDataRef(dataID DataID, ref *dataRef) {
+	disk.refsMutex.Lock()
+	defer disk.refsMutex.Unlock()
+
+	ref.readers--
+	if ref.readers > 0 {
+		return
+	}
+
+	if ref.deleted {
+		delete(disk.deletedRefs, path.Base(ref.dataDir))
+	} else if disk.refs[dataID.String()] == ref {
+		delete(disk.refs, dataID.String())
+	}
+}
+
+// detachDataRef moves reference of given data ID, whose data directory is moved to trashDir, out of refs so
+// that new readers get new reference. Existing readers continue to read from trashDir. refsMutex must be held.
+func (disk *Disk) detachDataRef(dataID DataID, trashDir string) {
+	ref, found := disk.refs[dataID.String()]
+	if !found {
+		return
+	}
+
+	delete(disk.refs, dataID.String())
+	if ref.readers > 0 {
+		ref.dataDir = trashDir
+		ref.deleted = true
+		disk.deletedRefs[path.Base(trashDir)] = ref
+	}
+}
+
+// attachDataRef is reverse of detachDataRef() after given trash entry is moved back to dataDir. refsMutex must
+// be held.
+func (disk *Disk) attachDataRef(dataID DataID, trashName, dataDir string) {
+	ref, found := disk.deletedRefs[trashName]
+	if !found {
+		return
+	}
+
+	delete(disk.deletedRefs, trashName)
+	ref.dataDir = dataDir
+	ref.deleted = false
+	if _, found = disk.refs[dataID.String()]; !found {
+		disk.refs[dataID.String()] = ref
+	}
+}
+
+// withDataDir calls fn with data directory of referenced data. Only the directory is read under lock; if fn
+// fails by missing file because Delete() or RevertDelete() moved the directory meanwhile, fn is retried with
+// the new directory.
+func (disk *Disk) withDataDir(ref *dataRef, fn func(dataDir string) error) error {
+	disk.refsMutex.Lock()
+	dataDir := ref.dataDir
+	disk.refsMutex.Unlock()
+
+	for {
+		err := fn(dataDir)
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		disk.refsMutex.Lock()
+		movedDir := ref.dataDir
+		disk.refsMutex.Unlock()
+		if movedDir == dataDir {
+			return err
+		}
+
+		dataDir = movedDir
+	}
+}
+
+// readDataInfo reads data.json of referenced data.
+func (disk *Disk) readDataInfo(ref *dataRef) (*DataInfo, error) {
+	var file *os.File
+	err := disk.withDataDir(ref, func(dataDir string) (err error) {
+		file, err = os.Open(path.Join(dataDir, "data.json"))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var dataInfo DataInfo
+	if err = json.NewDecoder(file).Decode(&dataInfo); err != nil {
+		return nil, err
+	}
+
+	return &dataInfo, nil
+}
+
+// openPart opens part file of referenced data. Opened file is read even if Delete() moves data directory.
+func (disk *Disk) openPart(ref *dataRef, partFile string, offset int64, length uint64) (rc io.ReadCloser, err error) {
+	err = disk.withDataDir(ref, func(dataDir string) (err error) {
+		rc, err = xos.OpenFile(path.Join(dataDir, partFile), offset, length, true)
+		return err
+	})
+
+	return rc, err
+}
+
+// openPartReader opens part file of referenced data for random access like openPart().
+func (disk *Disk) openPartReader(ref *dataRef, partFile string) (fr xos.FileReader, err error) {
+	err = disk.withDataDir(ref, func(dataDir string) (err error) {
+		fr, err = xos.OpenFileReader(path.Join(dataDir, partFile), true, partCacheBlocks)
+		return err
+	})
+
+	return fr, err
+}
+
+// skipTrashEntry returns whether given expired trash entry is to be kept as deleted data still being read. Entry
+// not kept is removed by trash reaper, hence record of Delete() of it is dropped.
+func (disk *Disk) skipTrashEntry(name string) bool {
+	disk.refsMutex.Lock()
+	defer disk.refsMutex.Unlock()
+
+	if _, found := disk.deletedRefs[name]; found {
+		return true
+	}
+
+	if i := strings.Index(name, "."); i > 0 && disk.deleted[name[:i]] == name {
+		delete(disk.deleted, name[:i])
+	}
+
+	return false
+}
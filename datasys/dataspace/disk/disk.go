@@ -4,14 +4,30 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	xerrors "github.com/balamurugana/goat/datasys/errors"
+	"github.com/balamurugana/goat/datasys/format"
+	"github.com/balamurugana/goat/datasys/recovery"
+	"github.com/balamurugana/goat/datasys/trash"
 	xos "github.com/balamurugana/goat/pkg/os"
 )
 
+// Trash reaper started by NewDisk(). Deleted data is kept in trash for grace period so that RevertDelete() works
+// within it, and is removed afterwards once it has no readers.
+const (
+	DefaultTrashGracePeriod = 15 * time.Minute
+	defaultTrashMinInterval = time.Minute
+	defaultTrashMaxInterval = 5 * time.Minute
+)
+
 type Disk struct {
 	id       string
 	storeDir string
@@ -20,6 +36,18 @@ type Disk struct {
 	tmpDir     string
 	uploadsDir string
 	trashDir   string
+
+	fs           xos.FS
+	writeOptions xos.WriteOptions
+
+	refs        map[string]*dataRef
+	deletedRefs map[string]*dataRef
+	deleted     map[string]string // Data ID to trash entry name of its data moved by Delete().
+	refsMutex   sync.Mutex
+	reaper      *trash.Reaper
+
+	format         *format.Format
+	recoveryReport *recovery.Report
 }
 
 func NewDisk(id, dir string) (*Disk, error) {
@@ -49,6 +77,11 @@ func NewDisk(id, dir string) (*Disk, error) {
 		return nil, err
 	}
 
+	diskFormat, err := format.Open(storeDir, format.DataSpaceType, id, migrations)
+	if err != nil {
+		return nil, err
+	}
+
 	dataDir := path.Join(storeDir, "data")
 	if err := os.Mkdir(dataDir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
 		return nil, err
@@ -69,22 +102,114 @@ func NewDisk(id, dir string) (*Disk, error) {
 		return nil, err
 	}
 
-	return &Disk{
-		id:         id,
-		storeDir:   storeDir,
-		dataDir:    dataDir,
-		tmpDir:     tmpDir,
-		uploadsDir: uploadsDir,
-		trashDir:   trashDir,
-	}, nil
+	disk := &Disk{
+		id:          id,
+		storeDir:    storeDir,
+		dataDir:     dataDir,
+		tmpDir:      tmpDir,
+		uploadsDir:  uploadsDir,
+		trashDir:    trashDir,
+		format:      diskFormat,
+		refs:        make(map[string]*dataRef),
+		deletedRefs: make(map[string]*dataRef),
+		deleted:     make(map[string]string),
+	}
+
+	if disk.recoveryReport, err = disk.recoverStore(); err != nil {
+		return nil, err
+	}
+
+	disk.StartTrashReaper(DefaultTrashGracePeriod, defaultTrashMinInterval, defaultTrashMaxInterval)
+	return disk, nil
+}
+
+// getDataDir returns <STORE_DIR>/data/<INDEX>/<ID> directory of given data ID.
+func (disk *Disk) getDataDir(dataID DataID) string {
+	return path.Join(disk.dataDir, getIndex(dataID.String()), dataID.String())
+}
+
+// getUploadIDDir returns <STORE_DIR>/uploads/<INDEX>/<UPLOAD_ID> directory of given upload ID.
+func (disk *Disk) getUploadIDDir(uploadID UploadID) string {
+	return path.Join(disk.uploadsDir, getIndex(uploadID.String()), uploadID.String())
 }
 
 func (disk *Disk) ID() string {
 	return disk.id
 }
 
+// SetSync enables or disables syncing file contents and directory entries to stable storage on every write.
+func (disk *Disk) SetSync(sync bool) {
+	disk.fs.Sync = sync
+}
+
+// SetWriteOptions sets checksum block size, hash algorithm, hash key and checksum format used by SaveTempFile().
+// BitrotProtection of given options is ignored as it is passed to SaveTempFile().
+func (disk *Disk) SetWriteOptions(opts xos.WriteOptions) {
+	disk.writeOptions = opts
+}
+
+// Format returns format of this disk.
+func (disk *Disk) Format() format.Format {
+	return *disk.format
+}
+
+// SetPosition assigns deployment ID and position in erasure set to this disk on first use and
+// verifies them on later use.
+func (disk *Disk) SetPosition(deploymentID string, setIndex, diskIndex int) error {
+	return disk.format.SetPosition(disk.storeDir, deploymentID, setIndex, diskIndex)
+}
+
+// RecoveryReport returns leftovers of interrupted operations repaired by NewDisk().
+func (disk *Disk) RecoveryReport() *recovery.Report {
+	return disk.recoveryReport
+}
+
+// StartTrashReaper starts background removal of trash entries older than grace period at random
+// intervals between minInterval and maxInterval, replacing running reaper. RevertDelete() works within grace
+// period. NewDisk() starts it with DefaultTrashGracePeriod.
+func (disk *Disk) StartTrashReaper(gracePeriod, minInterval, maxInterval time.Duration) *trash.Reaper {
+	reaper := trash.NewReaper(disk.trashDir, gracePeriod, disk.skipTrashEntry)
+
+	disk.refsMutex.Lock()
+	oldReaper := disk.reaper
+	disk.reaper = reaper
+	disk.refsMutex.Unlock()
+
+	if oldReaper != nil {
+		oldReaper.Stop()
+	}
+
+	reaper.Start(minInterval, maxInterval)
+	return reaper
+}
+
+// PurgeTrash removes all trash entries except deleted data still being read; returns number of entries removed
+// and bytes reclaimed. It is used where trash reaper is stopped.
+func (disk *Disk) PurgeTrash() (count int, bytes uint64, err error) {
+	return trash.NewReaper(disk.trashDir, 0, disk.skipTrashEntry).Reap()
+}
+
+// StopTrashReaper stops background trash reaper started by StartTrashReaper().
+func (disk *Disk) StopTrashReaper() {
+	disk.refsMutex.Lock()
+	reaper := disk.reaper
+	disk.reaper = nil
+	disk.refsMutex.Unlock()
+
+	if reaper != nil {
+		reaper.Stop()
+	}
+}
+
 func (disk *Disk) SaveTempFile(filename string, data io.Reader, size uint64, bitrotProtection bool) (checksum string, err error) {
-	return xos.WriteFile(path.Join(disk.tmpDir, filename), data, size, bitrotProtection)
+	opts := disk.writeOptions
+	opts.BitrotProtection = bitrotProtection
+	return disk.fs.WriteFileWithOptions(path.Join(disk.tmpDir, filename), data, size, opts)
+}
+
+// GetTempFile returns reader of given range of temporary file saved by SaveTempFile().
+func (disk *Disk) GetTempFile(filename string, offset int64, length uint64, bitrotProtection bool) (io.ReadCloser, error) {
+	return xos.OpenFile(path.Join(disk.tmpDir, filename), offset, length, bitrotProtection)
 }
 
 func (disk *Disk) RemoveTempFile(filename string, bitrotProtection bool) (err error) {
@@ -92,9 +217,12 @@ func (disk *Disk) RemoveTempFile(filename string, bitrotProtection bool) (err er
 }
 
 func (disk *Disk) InitUpload(uploadID UploadID) (err error) {
-	uploadIDDir := path.Join(disk.uploadsDir, uploadID.String())
+	uploadIDDir := disk.getUploadIDDir(uploadID)
+	if err = disk.fs.CreatePath(path.Dir(uploadIDDir), "", false); err != nil {
+		return err
+	}
 
-	if err = os.Mkdir(uploadIDDir, os.ModePerm); errors.Is(err, os.ErrExist) {
+	if err = disk.fs.Mkdir(uploadIDDir, os.ModePerm); errors.Is(err, os.ErrExist) {
 		err = xerrors.ErrUploadIDAlreadyExist
 	}
 
@@ -102,7 +230,7 @@ func (disk *Disk) InitUpload(uploadID UploadID) (err error) {
 }
 
 func (disk *Disk) RevertInitUpload(uploadID UploadID) (err error) {
-	uploadIDDir := path.Join(disk.uploadsDir, uploadID.String())
+	uploadIDDir := disk.getUploadIDDir(uploadID)
 
 	if err = os.Remove(uploadIDDir); errors.Is(err, os.ErrNotExist) {
 		err = xerrors.ErrUploadIDNotFound
@@ -112,51 +240,100 @@ func (disk *Disk) RevertInitUpload(uploadID UploadID) (err error) {
 }
 
 func (disk *Disk) UploadPart(uploadID UploadID, partID, tempFile string) (err error) {
-	uploadIDDir := path.Join(disk.uploadsDir, uploadID.String())
+	return disk.UploadPartWithMeta(uploadID, partID, tempFile, nil)
+}
+
+// UploadPartWithMeta is same as UploadPart() and also saves given opaque metadata of the part which is returned
+// by ListParts(). Metadata is saved as <partID>.part.json before the part file is moved.
+func (disk *Disk) UploadPartWithMeta(uploadID UploadID, partID, tempFile string, meta json.RawMessage) (err error) {
+	uploadIDDir := disk.getUploadIDDir(uploadID)
 	if !xos.Exist(uploadIDDir) {
 		return xerrors.ErrUploadIDNotFound
 	}
 
+	metaFile := path.Join(uploadIDDir, partID+".part.json")
+	if meta != nil {
+		if err = disk.fs.WriteBytes(metaFile, meta); err != nil {
+			return err
+		}
+	} else if err = os.Remove(metaFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
 	src := path.Join(disk.tmpDir, tempFile)
 	dest := path.Join(uploadIDDir, partID+".part")
-	return xos.RenameFile(src, dest, true)
+	if err = disk.fs.RenameFile(src, dest, true); err != nil && meta != nil {
+		os.Remove(metaFile)
+	}
+
+	return err
 }
 
 func (disk *Disk) RevertUploadPart(uploadID UploadID, partID, tempFile string) (err error) {
-	uploadIDDir := path.Join(disk.uploadsDir, uploadID.String())
+	uploadIDDir := disk.getUploadIDDir(uploadID)
 	if !xos.Exist(uploadIDDir) {
 		return xerrors.ErrUploadIDNotFound
 	}
 
 	partFile := path.Join(uploadIDDir, partID+".part")
 	dest := path.Join(disk.tmpDir, tempFile)
-	if err = xos.RenameFile(partFile, dest, true); errors.Is(err, os.ErrNotExist) {
+	if err = disk.fs.RenameFile(partFile, dest, true); errors.Is(err, os.ErrNotExist) {
 		err = xerrors.ErrPartNotFound
 	}
 
+	if err == nil {
+		os.Remove(partFile + ".json")
+	}
+
 	return err
 }
 
-// func (disk *Disk) UploadPartCopy(uploadID UploadID, partID string, srcID DataID, offset int64, length uint64) (etag string, err error) {
-// }
-//
+// UploadPartCopy copies given range of data of srcID as part of upload. Data is read by Get() so that it is
+// verified against bitrot. Returned etag is checksum of copied data like SaveTempFile().
+func (disk *Disk) UploadPartCopy(uploadID UploadID, partID string, srcID DataID, offset int64, length uint64) (etag string, err error) {
+	if !xos.Exist(disk.getUploadIDDir(uploadID)) {
+		return "", xerrors.ErrUploadIDNotFound
+	}
+
+	rc, err := disk.Get(srcID, offset, length)
+	if err != nil {
+		return "", err
+	}
+
+	defer rc.Close()
+
+	tempFile := NewTempFilename()
+	defer func() {
+		if err != nil {
+			disk.RemoveTempFile(tempFile, true)
+		}
+	}()
+
+	if etag, err = disk.SaveTempFile(tempFile, rc, length, true); err != nil {
+		return "", err
+	}
+
+	if err = disk.UploadPart(uploadID, partID, tempFile); err != nil {
+		return "", err
+	}
+
+	return etag, nil
+}
 
 func (disk *Disk) AbortUpload(uploadID UploadID) (err error) {
-	uploadIDDir := path.Join(disk.uploadsDir, uploadID.String())
+	uploadIDDir := disk.getUploadIDDir(uploadID)
 	trashDir := path.Join(disk.trashDir, uploadID.String())
-	if err = os.Rename(uploadIDDir, trashDir); errors.Is(err, os.ErrNotExist) {
+	if err = disk.fs.Rename(uploadIDDir, trashDir); errors.Is(err, os.ErrNotExist) {
 		err = xerrors.ErrUploadIDNotFound
 	}
 
-	// FIXME: cleanup trash
-
 	return err
 }
 
 func (disk *Disk) RevertAbortUpload(uploadID UploadID) (err error) {
 	uploadIDDirInTrash := path.Join(disk.trashDir, uploadID.String())
-	uploadIDDir := path.Join(disk.uploadsDir, uploadID.String())
-	if err = os.Rename(uploadIDDirInTrash, uploadIDDir); errors.Is(err, os.ErrNotExist) {
+	uploadIDDir := disk.getUploadIDDir(uploadID)
+	if err = disk.fs.Rename(uploadIDDirInTrash, uploadIDDir); errors.Is(err, os.ErrNotExist) {
 		err = xerrors.ErrUploadIDNotFound
 	}
 
@@ -164,12 +341,12 @@ func (disk *Disk) RevertAbortUpload(uploadID UploadID) (err error) {
 }
 
 func (disk *Disk) CompleteUpload(dataID DataID, uploadID UploadID, parts []Part) (err error) {
-	uploadIDDir := path.Join(disk.uploadsDir, uploadID.String())
+	uploadIDDir := disk.getUploadIDDir(uploadID)
 	if !xos.Exist(uploadIDDir) {
 		return xerrors.ErrUploadIDNotFound
 	}
 
-	dataDir := path.Join(disk.dataDir, dataID.String())
+	dataDir := disk.getDataDir(dataID)
 	if xos.Exist(dataDir) {
 		return xerrors.ErrDataIDAlreadyExist
 	}
@@ -186,27 +363,25 @@ func (disk *Disk) CompleteUpload(dataID DataID, uploadID UploadID, parts []Part)
 		Size:  size,
 	}
 
-	err = func() error {
-		dataJSONFile := path.Join(uploadIDDir, "data.json")
-		file, err := os.OpenFile(dataJSONFile, os.O_WRONLY|os.O_CREATE, 0644)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+	if err = disk.fs.WriteJSONFile(path.Join(uploadIDDir, "data.json"), dataInfo); err != nil {
+		return err
+	}
 
-		return json.NewEncoder(file).Encode(dataInfo)
-	}()
-	if err != nil {
+	if err = disk.fs.SyncDir(uploadIDDir); err != nil {
 		return err
 	}
 
-	return os.Rename(uploadIDDir, dataDir)
+	if err = disk.fs.CreatePath(path.Dir(dataDir), "", false); err != nil {
+		return err
+	}
+
+	return disk.fs.Rename(uploadIDDir, dataDir)
 }
 
 func (disk *Disk) RevertCompleteUpload(dataID DataID, uploadID UploadID, parts []Part) (err error) {
-	dataDir := path.Join(disk.dataDir, dataID.String())
-	uploadIDDir := path.Join(disk.uploadsDir, uploadID.String())
-	if err = os.Rename(dataDir, uploadIDDir); errors.Is(err, os.ErrNotExist) {
+	dataDir := disk.getDataDir(dataID)
+	uploadIDDir := disk.getUploadIDDir(uploadID)
+	if err = disk.fs.Rename(dataDir, uploadIDDir); errors.Is(err, os.ErrNotExist) {
 		err = xerrors.ErrDataIDNotFound
 	}
 	if err != nil {
@@ -219,39 +394,321 @@ func (disk *Disk) RevertCompleteUpload(dataID DataID, uploadID UploadID, parts [
 	return err
 }
 
-// func (disk *Disk) ListParts(uploadID string) ([]Part, error) {
-// }
-//
+// ListParts returns parts uploaded so far to given upload ID sorted by part ID. Size of each part is read from
+// its checksum header and metadata saved by UploadPartWithMeta() is returned as part's Meta.
+func (disk *Disk) ListParts(uploadID UploadID) ([]Part, error) {
+	uploadIDDir := disk.getUploadIDDir(uploadID)
+	if !xos.Exist(uploadIDDir) {
+		return nil, xerrors.ErrUploadIDNotFound
+	}
 
-func (disk *Disk) Get(dataID DataID, offset int64, length uint64) (rc io.ReadCloser, err error) {
-	dataDir := path.Join(disk.dataDir, dataID.String())
-	if !xos.Exist(dataDir) {
-		err = xerrors.ErrDataIDNotFound
+	var partIDs []string
+	picker := func(name string, mode os.FileMode) (stop bool) {
+		if mode.IsRegular() && strings.HasSuffix(name, ".part") {
+			partIDs = append(partIDs, strings.TrimSuffix(name, ".part"))
+		}
+
+		return false
+	}
+
+	if err := xos.Readdirnames(uploadIDDir, picker); err != nil {
+		return nil, err
 	}
 
-	var dataInfo DataInfo
-	err = func() error {
-		file, err := os.Open(path.Join(dataDir, "data.json"))
+	sort.Strings(partIDs)
+
+	parts := make([]Part, len(partIDs))
+	for i, partID := range partIDs {
+		partFile := path.Join(uploadIDDir, partID+".part")
+		fr, err := xos.OpenFileReader(partFile, true, 0)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		size := fr.Size()
+		fr.Close()
+
+		parts[i] = Part{ID: partID, Size: uint64(size)}
+
+		meta, err := ioutil.ReadFile(partFile + ".json")
+		switch {
+		case err == nil:
+			parts[i].Meta = meta
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
 		}
-		defer file.Close()
+	}
 
-		return json.NewDecoder(file).Decode(&dataInfo)
+	return parts, nil
+}
+
+// Get returns reader of given range of data. Returned reader also supports random access within the range.
+func (disk *Disk) Get(dataID DataID, offset int64, length uint64) (rc DataReader, err error) {
+	ref, err := disk.acquireDataRef(dataID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			disk.releaseDataRef(dataID, ref)
+		}
 	}()
+
+	dataInfo, err := disk.readDataInfo(ref)
 	if err != nil {
 		return nil, err
 	}
 
-	return newDataReader(dataDir, &dataInfo, offset, length)
+	dr, err := newDataReader(ref.dataDir, dataInfo, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	dr.openPart = func(partFile string, offset int64, length uint64) (io.ReadCloser, error) {
+		return disk.openPart(ref, partFile, offset, length)
+	}
+	dr.openPartReader = func(partFile string) (xos.FileReader, error) {
+		return disk.openPartReader(ref, partFile)
+	}
+	dr.release = func() {
+		disk.releaseDataRef(dataID, ref)
+	}
+
+	return dr, nil
 }
 
-// func (disk *Disk) GetMetadata(ID string) (map[string][]string, error) {
-// }
-//
-// func (disk *Disk) Delete(ID string) error {
-// }
-//
-// func (disk *Disk) Copy(ID, srcID string, offset, length uint64, metadata map[string][]string) error {
-// }
-//
+// GetMetadata returns data info of given data ID saved by CompleteUpload() or Copy().
+func (disk *Disk) GetMetadata(dataID DataID) (*DataInfo, error) {
+	ref, err := disk.acquireDataRef(dataID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer disk.releaseDataRef(dataID, ref)
+
+	return disk.readDataInfo(ref)
+}
+
+// Delete moves data of given data ID to trash. Data is removed from trash by trash reaper after its grace period,
+// or by PurgeTrash(), once it has no readers; RevertDelete() restores it until then.
+func (disk *Disk) Delete(dataID DataID) (err error) {
+	dataDir := disk.getDataDir(dataID)
+	trashDir := path.Join(disk.trashDir, dataID.String()+"."+newTempName())
+
+	disk.refsMutex.Lock()
+	defer disk.refsMutex.Unlock()
+
+	if err = disk.fs.Rename(dataDir, trashDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = xerrors.ErrDataIDNotFound
+		}
+
+		return err
+	}
+
+	disk.detachDataRef(dataID, trashDir)
+	disk.deleted[dataID.String()] = path.Base(trashDir)
+	return nil
+}
+
+// RevertDelete restores data of given data ID moved to trash by last Delete(). Data can be restored only if it is
+// not removed yet; other trash entries of the data ID, e.g. data replaced by Heal(), are never restored.
+func (disk *Disk) RevertDelete(dataID DataID) (err error) {
+	disk.refsMutex.Lock()
+	defer disk.refsMutex.Unlock()
+
+	trashName, found := disk.deleted[dataID.String()]
+	if !found {
+		return xerrors.ErrDataIDNotFound
+	}
+
+	dataDir := disk.getDataDir(dataID)
+	if err = disk.fs.Rename(path.Join(disk.trashDir, trashName), dataDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			delete(disk.deleted, dataID.String())
+			err = xerrors.ErrDataIDNotFound
+		}
+
+		return err
+	}
+
+	delete(disk.deleted, dataID.String())
+	disk.attachDataRef(dataID, trashName, dataDir)
+	return nil
+}
+
+// Copy creates data of dataID from given range of data of srcID. Parts fully covered by the range are hard
+// linked, and partially covered parts are copied after bitrot verification.
+func (disk *Disk) Copy(dataID, srcID DataID, offset int64, length uint64) (err error) {
+	dataDir := disk.getDataDir(dataID)
+	if xos.Exist(dataDir) {
+		return xerrors.ErrDataIDAlreadyExist
+	}
+
+	ref, err := disk.acquireDataRef(srcID)
+	if err != nil {
+		return err
+	}
+
+	defer disk.releaseDataRef(srcID, ref)
+
+	srcDataInfo, err := disk.readDataInfo(ref)
+	if err != nil {
+		return err
+	}
+
+	if offset < 0 || uint64(offset)+length > srcDataInfo.Size {
+		return errors.New("insufficient data")
+	}
+
+	var requiredParts []Part
+	var bytesToSkip, bytesToRead int64
+	if length > 0 {
+		requiredParts, bytesToSkip, bytesToRead = getParts(srcDataInfo, offset, int64(length))
+	}
+
+	copyDir := path.Join(disk.tmpDir, newTempName())
+	if err = disk.fs.Mkdir(copyDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			os.RemoveAll(copyDir)
+		}
+	}()
+
+	parts := make([]Part, len(requiredParts))
+	for i, part := range requiredParts {
+		partOffset := int64(0)
+		if i == 0 {
+			partOffset = bytesToSkip
+		}
+
+		partLength := int64(part.Size) - partOffset
+		if i == len(requiredParts)-1 {
+			partLength = bytesToRead
+		}
+
+		parts[i] = Part{ID: part.ID, Size: uint64(partLength)}
+		if partOffset == 0 && uint64(partLength) == part.Size {
+			parts[i].Meta = part.Meta
+		}
+		if err = disk.copyPart(ref, part.ID+".part", path.Join(copyDir, part.ID+".part"), partOffset, partLength, part.Size); err != nil {
+			return err
+		}
+	}
+
+	dataInfo := &DataInfo{
+		Parts: parts,
+		Size:  length,
+	}
+
+	if err = disk.fs.WriteJSONFile(path.Join(copyDir, "data.json"), dataInfo); err != nil {
+		return err
+	}
+
+	if err = disk.fs.SyncDir(copyDir); err != nil {
+		return err
+	}
+
+	if err = disk.fs.CreatePath(path.Dir(dataDir), "", false); err != nil {
+		return err
+	}
+
+	return disk.fs.Rename(copyDir, dataDir)
+}
+
+// Heal replaces data of dataID by given data info. Parts found in tempFiles, which maps part ID to temporary
+// file saved by SaveTempFile(), are moved from the temporary files and other parts are hard linked from
+// existing data. Existing data is moved to trash like Delete() so that its readers are not disturbed.
+func (disk *Disk) Heal(dataID DataID, dataInfo *DataInfo, tempFiles map[string]string) (err error) {
+	healDir := path.Join(disk.tmpDir, newTempName())
+	if err = disk.fs.Mkdir(healDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			os.RemoveAll(healDir)
+		}
+	}()
+
+	dataDir := disk.getDataDir(dataID)
+	for _, part := range dataInfo.Parts {
+		dest := path.Join(healDir, part.ID+".part")
+		if tempFile, found := tempFiles[part.ID]; found {
+			err = disk.fs.RenameFile(path.Join(disk.tmpDir, tempFile), dest, true)
+		} else if err = xos.LinkFile(path.Join(dataDir, part.ID+".part"), dest, true); errors.Is(err, os.ErrNotExist) {
+			err = xerrors.ErrPartNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = disk.fs.WriteJSONFile(path.Join(healDir, "data.json"), dataInfo); err != nil {
+		return err
+	}
+
+	if err = disk.fs.SyncDir(healDir); err != nil {
+		return err
+	}
+
+	if err = disk.fs.CreatePath(path.Dir(dataDir), "", false); err != nil {
+		return err
+	}
+
+	// Existing data is moved to trash and healed data is moved in under refsMutex, hence new readers never see
+	// missing data, and readers of existing data continue to read it from trash. Trash entry is named apart from
+	// that of Delete() so that RevertDelete() never restores replaced data.
+	trashDir := path.Join(disk.trashDir, dataID.String()+".heal."+newTempName())
+
+	disk.refsMutex.Lock()
+	defer disk.refsMutex.Unlock()
+
+	found := true
+	if err = disk.fs.Rename(dataDir, trashDir); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		found = false
+	}
+
+	if err = disk.fs.Rename(healDir, dataDir); err != nil {
+		if found {
+			disk.fs.Rename(trashDir, dataDir)
+		}
+
+		return err
+	}
+
+	if found {
+		disk.detachDataRef(dataID, trashDir)
+	}
+
+	return nil
+}
+
+// copyPart hard links part file of referenced data if whole part is requested, else copies requested range.
+func (disk *Disk) copyPart(ref *dataRef, partFile, dest string, offset, length int64, size uint64) error {
+	if offset == 0 && uint64(length) == size {
+		return disk.withDataDir(ref, func(dataDir string) error {
+			return xos.LinkFile(path.Join(dataDir, partFile), dest, true)
+		})
+	}
+
+	rc, err := disk.openPart(ref, partFile, offset, uint64(length))
+	if err != nil {
+		return err
+	}
+
+	defer rc.Close()
+
+	opts := disk.writeOptions
+	opts.BitrotProtection = true
+	_, err = disk.fs.WriteFileWithOptions(dest, rc, uint64(length), opts)
+	return err
+}
@@ -2,17 +2,72 @@ package disk
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/balamurugana/goat/datasys/dataspace"
+	"github.com/balamurugana/goat/datasys/dataspace/dataspacetest"
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	"github.com/balamurugana/goat/datasys/format"
 	xhash "github.com/balamurugana/goat/pkg/hash"
+	xos "github.com/balamurugana/goat/pkg/os"
 	xrand "github.com/balamurugana/goat/pkg/rand"
 )
 
+func TestNewDisk(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			diskFormat := disk.Format()
+			if diskFormat.ID != id || diskFormat.UUID == "" {
+				t.Fatalf("unexpected format %+v", diskFormat)
+			}
+
+			if err = disk.SetPosition("deployment", 0, 3); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = NewDisk("other", dataDir); !errors.Is(err, xerrors.ErrDiskIDMismatch) {
+				t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDiskIDMismatch, err)
+			}
+
+			if disk, err = NewDisk(id, dataDir); err != nil {
+				t.Fatal(err)
+			}
+
+			if disk.Format() != (format.Format{Version: 2, Type: format.DataSpaceType, ID: id, UUID: diskFormat.UUID, DeploymentID: "deployment", DiskIndex: 3}) {
+				t.Fatalf("unexpected format %+v", disk.Format())
+			}
+
+			if err = disk.SetPosition("deployment", 1, 3); !errors.Is(err, xerrors.ErrDiskPositionMismatch) {
+				t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDiskPositionMismatch, err)
+			}
+		},
+	)
+}
+
 func TestSaveTempFile(t *testing.T) {
 	t.Run(
 		"test0",
@@ -212,6 +267,93 @@ func TestUploadPart(t *testing.T) {
 	)
 }
 
+func TestUploadPartCopy(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, part := range []Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}} {
+				tempFilename := NewTempFilename()
+				if _, err = disk.SaveTempFile(tempFilename, randReader(), part.Size, true); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = disk.UploadPart(uploadID, part.ID, tempFilename); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			srcID := NewDataID()
+			if err = disk.CompleteUpload(srcID, uploadID, []Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}); err != nil {
+				t.Fatal(err)
+			}
+
+			uploadID = NewUploadID()
+			if _, err = disk.UploadPartCopy(uploadID, "1", srcID, 12958, 10992); !errors.Is(err, xerrors.ErrUploadIDNotFound) {
+				t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrUploadIDNotFound, err)
+			}
+
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = disk.UploadPartCopy(uploadID, "1", NewDataID(), 0, 10); !errors.Is(err, xerrors.ErrDataIDNotFound) {
+				t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+			}
+
+			expectedChecksum := "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"
+			etag, err := disk.UploadPartCopy(uploadID, "1", srcID, 12958, 10992)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if etag != expectedChecksum {
+				t.Fatalf("mismatch: etag: expected: %v, got: %v", expectedChecksum, etag)
+			}
+
+			dataID := NewDataID()
+			if err = disk.CompleteUpload(dataID, uploadID, []Part{{ID: "1", Size: 10992}}); err != nil {
+				t.Fatal(err)
+			}
+
+			rc, err := disk.Get(dataID, 0, 10992)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rc.Close()
+
+			hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+			if _, err = io.Copy(hasher, rc); err != nil {
+				t.Fatal(err)
+			}
+
+			if checksum := hasher.HexSum(nil); checksum != expectedChecksum {
+				t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+			}
+		},
+	)
+}
+
 func TestRevertUploadPart(t *testing.T) {
 	t.Run(
 		"test0",
@@ -377,6 +519,71 @@ func TestRevertAbortUpload(t *testing.T) {
 	)
 }
 
+func TestListParts(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			uploadID := NewUploadID()
+			if _, err = disk.ListParts(uploadID); err != xerrors.ErrUploadIDNotFound {
+				t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrUploadIDNotFound, err)
+			}
+
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedParts := []Part{
+				{ID: "3", Size: 16279, Meta: json.RawMessage(`{"shard":1}`)},
+				{ID: "8", Size: 10992},
+			}
+			for i := len(expectedParts) - 1; i >= 0; i-- {
+				part := expectedParts[i]
+				tempFilename := NewTempFilename()
+				if _, err = disk.SaveTempFile(tempFilename, randReader(), part.Size, true); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = disk.UploadPartWithMeta(uploadID, part.ID, tempFilename, part.Meta); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			parts, err := disk.ListParts(uploadID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(parts, expectedParts) {
+				t.Fatalf("mismatch: expected: %+v, got: %+v", expectedParts, parts)
+			}
+
+			dataID := NewDataID()
+			if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = disk.ListParts(uploadID); err != xerrors.ErrUploadIDNotFound {
+				t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrUploadIDNotFound, err)
+			}
+		},
+	)
+}
+
 func TestCompleteUpload(t *testing.T) {
 	t.Run(
 		"test0",
@@ -433,7 +640,7 @@ func TestCompleteUpload(t *testing.T) {
 			}
 
 			dataID := NewDataID()
-			parts := []Part{{"3", 16279}, {"8", 70009289}}
+			parts := []Part{{ID: "3", Size: 16279}, {ID: "8", Size: 70009289}}
 			if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
 				t.Fatal(err)
 			}
@@ -443,7 +650,7 @@ func TestCompleteUpload(t *testing.T) {
 				Size:  16279 + 70009289,
 			}
 
-			dataJSONFile := path.Join(dataDir, "data", dataID.String(), "data.json")
+			dataJSONFile := path.Join(dataDir, "data", dataID.String()[:2], dataID.String(), "data.json")
 			file, err := os.Open(dataJSONFile)
 			if err != nil {
 				t.Fatal(err)
@@ -519,7 +726,7 @@ func TestRevertCompleteUpload(t *testing.T) {
 			}
 
 			dataID := NewDataID()
-			parts := []Part{{"3", 16279}, {"8", 70009289}}
+			parts := []Part{{ID: "3", Size: 16279}, {ID: "8", Size: 70009289}}
 			if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
 				t.Fatal(err)
 			}
@@ -529,7 +736,7 @@ func TestRevertCompleteUpload(t *testing.T) {
 				Size:  16279 + 70009289,
 			}
 
-			dataJSONFile := path.Join(dataDir, "data", dataID.String(), "data.json")
+			dataJSONFile := path.Join(dataDir, "data", dataID.String()[:2], dataID.String(), "data.json")
 			file, err := os.Open(dataJSONFile)
 			if err != nil {
 				t.Fatal(err)
@@ -564,15 +771,15 @@ func TestGet(t *testing.T) {
 		length   uint64
 		checksum string
 	}{
-		{[]Part{{"1", 16279}}, 0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
-		{[]Part{{"3", 16279}, {"8", 10992}}, 0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
-		{[]Part{{"1", 16279}}, 10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
-		{[]Part{{"3", 16279}, {"8", 10992}}, 10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
-		{[]Part{{"1", 16279}}, 0, 16279, "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"},
-		{[]Part{{"3", 16279}, {"8", 10992}}, 16279, 10992, "60c7436deea126319878ecbf43b853f39f3451dccacde02b4e6a66082e9d168a"},
-		{[]Part{{"3", 16279}, {"8", 10992}}, 12958, 10992, "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"},
-		{[]Part{{"3", 16279}, {"8", 10992}, {"1", 25489}}, 12958, 17343, "f76f77b058eb962e5099062cccfcf5e9363cdb533a86563680f8488ee99f0cfa"},
-		{[]Part{{"3", 16279}, {"8", 10992}, {"1", 25489}}, 27271, 70, "f9b63a4a399ca9f26b15f7dc5987b1644b6054ac9c34f6c136c6576eb77d9956"},
+		{[]Part{{ID: "1", Size: 16279}}, 0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}, 0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
+		{[]Part{{ID: "1", Size: 16279}}, 10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}, 10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
+		{[]Part{{ID: "1", Size: 16279}}, 0, 16279, "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}, 16279, 10992, "60c7436deea126319878ecbf43b853f39f3451dccacde02b4e6a66082e9d168a"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}, 12958, 10992, "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}, {ID: "1", Size: 25489}}, 12958, 17343, "f76f77b058eb962e5099062cccfcf5e9363cdb533a86563680f8488ee99f0cfa"},
+		{[]Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}, {ID: "1", Size: 25489}}, 27271, 70, "f9b63a4a399ca9f26b15f7dc5987b1644b6054ac9c34f6c136c6576eb77d9956"},
 	}
 
 	for i, testCase := range testCases {
@@ -642,3 +849,991 @@ func TestGet(t *testing.T) {
 		)
 	}
 }
+
+func TestGetMetadata(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dataID := NewDataID()
+			if _, err = disk.GetMetadata(dataID); err != xerrors.ErrDataIDNotFound {
+				t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+			}
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			tempFilename := NewTempFilename()
+			if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+				t.Fatal(err)
+			}
+
+			parts := []Part{{ID: "1", Size: 16279, Meta: json.RawMessage(`{"shard":1}`)}}
+			if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedDataInfo := &DataInfo{Parts: parts, Size: 16279}
+			dataInfo, err := disk.GetMetadata(dataID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(dataInfo, expectedDataInfo) {
+				t.Fatalf("mismatch: expected: %+v, got: %+v", expectedDataInfo, dataInfo)
+			}
+
+			if err = disk.Delete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = disk.GetMetadata(dataID); err != xerrors.ErrDataIDNotFound {
+				t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+			}
+		},
+	)
+}
+
+func TestDelete(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parts := []Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}
+			dataID := NewDataID()
+			if err = disk.Delete(dataID); err == nil {
+				t.Fatalf("mismatch: expected: <error>, got: <nil>")
+			}
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			for j, part := range parts {
+				tempFilename := NewTempFilename()
+				if _, err := disk.SaveTempFile(tempFilename, randReader(), part.Size, true); err != nil {
+					t.Fatalf("parts[%+v]: %v", j, err)
+				}
+
+				if err := disk.UploadPart(uploadID, part.ID, tempFilename); err != nil {
+					t.Fatalf("parts[%+v]: %v", j, err)
+				}
+			}
+
+			if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
+				t.Fatal(err)
+			}
+
+			rc, err := disk.Get(dataID, 12958, 10992)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.Delete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = disk.Get(dataID, 0, 10); err == nil {
+				t.Fatalf("mismatch: expected: <error>, got: <nil>")
+			}
+
+			hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+			if _, err = io.Copy(hasher, rc); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedChecksum := "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"
+			if checksum := hasher.HexSum(nil); checksum != expectedChecksum {
+				t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+			}
+
+			if err = rc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Deleted data stays in trash until it is purged.
+			names, err := ioutil.ReadDir(path.Join(dataDir, "trash"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(names) != 1 {
+				t.Fatalf("mismatch: trash entries: expected: 1, got: %v", len(names))
+			}
+
+			if count, _, err := disk.PurgeTrash(); err != nil || count != 1 {
+				t.Fatalf("mismatch: expected: 1, <nil>, got: %v, %v", count, err)
+			}
+
+			if names, err = ioutil.ReadDir(path.Join(dataDir, "trash")); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(names) != 0 {
+				t.Fatalf("mismatch: trash entries: expected: 0, got: %v", len(names))
+			}
+
+			if err = disk.Delete(dataID); err == nil {
+				t.Fatalf("mismatch: expected: <error>, got: <nil>")
+			}
+		},
+	)
+}
+
+func TestRevertDelete(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			tempFilename := NewTempFilename()
+			if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+				t.Fatal(err)
+			}
+
+			dataID := NewDataID()
+			if err = disk.CompleteUpload(dataID, uploadID, []Part{{ID: "1", Size: 16279}}); err != nil {
+				t.Fatal(err)
+			}
+
+			rc, err := disk.Get(dataID, 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.Delete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.RevertDelete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = rc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if rc, err = disk.Get(dataID, 0, 10); err != nil {
+				t.Fatal(err)
+			}
+			rc.Close()
+
+			// Deleted data without readers is restored from trash until it is purged.
+			if err = disk.Delete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.RevertDelete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.Delete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err = disk.PurgeTrash(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.RevertDelete(dataID); err == nil {
+				t.Fatalf("mismatch: expected: <error>, got: <nil>")
+			}
+		},
+	)
+}
+
+func TestDeleteWithTrashReaper(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reaper := disk.StartTrashReaper(time.Hour, time.Hour, time.Hour)
+			defer disk.StopTrashReaper()
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			tempFilename := NewTempFilename()
+			if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+				t.Fatal(err)
+			}
+
+			dataID := NewDataID()
+			if err = disk.CompleteUpload(dataID, uploadID, []Part{{ID: "1", Size: 16279}}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.Delete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.RevertDelete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			rc, err := disk.Get(dataID, 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.Delete(dataID); err != nil {
+				t.Fatal(err)
+			}
+
+			if count, _, err := reaper.Reap(); err != nil || count != 0 {
+				t.Fatalf("mismatch: expected: 0, <nil>, got: %v, %v", count, err)
+			}
+
+			if err = rc.Close(); err != nil {
+				t.Fatal(err)
+			}
+		},
+	)
+}
+
+func TestDeleteReclaim(t *testing.T) {
+	id := xrand.NewID(8).String()
+	dataDir := id
+	if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dataDir)
+	}()
+
+	disk, err := NewDisk(id, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer disk.StopTrashReaper()
+
+	// NewDisk() starts trash reaper by default.
+	if disk.reaper == nil {
+		t.Fatalf("mismatch: expected: <reaper>, got: <nil>")
+	}
+
+	disk.StartTrashReaper(0, 10*time.Millisecond, 20*time.Millisecond)
+
+	uploadID := NewUploadID()
+	if err = disk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFilename := NewTempFilename()
+	if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+		t.Fatal(err)
+	}
+
+	dataID := NewDataID()
+	if err = disk.CompleteUpload(dataID, uploadID, []Part{{ID: "1", Size: 16279}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := disk.Get(dataID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.Delete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	trashCount := func() int {
+		names, err := ioutil.ReadDir(path.Join(dataDir, "trash"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return len(names)
+	}
+
+	// Deleted data being read is kept in trash.
+	time.Sleep(100 * time.Millisecond)
+	if count := trashCount(); count != 1 {
+		t.Fatalf("mismatch: trash entries: expected: 1, got: %v", count)
+	}
+
+	if err = rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleted data is removed once last reader is closed.
+	for start := time.Now(); trashCount() != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("mismatch: trash entries: expected: 0, got: %v", trashCount())
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			disk.SetSync(true)
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			tempFilename := NewTempFilename()
+			if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+				t.Fatal(err)
+			}
+
+			dataID := NewDataID()
+			if err = disk.CompleteUpload(dataID, uploadID, []Part{{ID: "1", Size: 16279}}); err != nil {
+				t.Fatal(err)
+			}
+
+			rc, err := disk.Get(dataID, 0, 16279)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+			_, err = io.Copy(hasher, rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectedChecksum := "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"
+			if checksum := hasher.HexSum(nil); checksum != expectedChecksum {
+				t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+			}
+		},
+	)
+}
+
+func TestSetWriteOptions(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			disk.SetWriteOptions(xos.WriteOptions{BlockSize: 4096, HashName: xhash.SHA256Algorithm})
+
+			tempFilename := NewTempFilename()
+			expectedChecksum := "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"
+			checksum, err := disk.SaveTempFile(tempFilename, randReader(), 16279, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if checksum != expectedChecksum {
+				t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+			}
+
+			file, err := os.Open(path.Join(dataDir, "tmp", tempFilename+".checksum"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer file.Close()
+
+			var header map[string]interface{}
+			if err = json.NewDecoder(file).Decode(&header); err != nil {
+				t.Fatal(err)
+			}
+
+			if header["hashName"] != xhash.SHA256Algorithm || header["blockSize"] != float64(4096) {
+				t.Fatalf("unexpected checksum header %v", header)
+			}
+		},
+	)
+}
+
+func TestInlineChecksum(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			disk.SetWriteOptions(xos.WriteOptions{BlockSize: 4096, ChecksumFormat: xos.ChecksumFormatInline})
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			tempFilename := NewTempFilename()
+			if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+				t.Fatal(err)
+			}
+
+			// Unused temporary file.
+			if _, err = disk.SaveTempFile(NewTempFilename(), randReader(), 1024, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if disk, err = NewDisk(id, dataDir); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedEntries := []string{"unused temporary file"}
+			var reasons []string
+			for _, entry := range disk.RecoveryReport().Entries {
+				reasons = append(reasons, entry.Reason)
+			}
+			if !reflect.DeepEqual(reasons, expectedEntries) {
+				t.Fatalf("mismatch: expected: %v, got: %v", expectedEntries, reasons)
+			}
+
+			dataID := NewDataID()
+			if err = disk.CompleteUpload(dataID, uploadID, []Part{{ID: "1", Size: 16279}}); err != nil {
+				t.Fatal(err)
+			}
+
+			rc, err := disk.Get(dataID, 10, 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rc.Close()
+
+			hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+			if _, err = io.Copy(hasher, rc); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedChecksum := "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"
+			if checksum := hasher.HexSum(nil); checksum != expectedChecksum {
+				t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+			}
+		},
+	)
+}
+
+func TestCopy(t *testing.T) {
+	testCases := []struct {
+		offset      int64
+		length      uint64
+		checksum    string
+		linkedParts []string
+	}{
+		{16279, 10992, "60c7436deea126319878ecbf43b853f39f3451dccacde02b4e6a66082e9d168a", []string{"8"}},
+		{12958, 17343, "f76f77b058eb962e5099062cccfcf5e9363cdb533a86563680f8488ee99f0cfa", []string{"8"}},
+		{27271, 70, "f9b63a4a399ca9f26b15f7dc5987b1644b6054ac9c34f6c136c6576eb77d9956", nil},
+		{0, 52760, "", []string{"3", "8", "1"}},
+	}
+
+	id := xrand.NewID(8).String()
+	dataDir := id
+	if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dataDir)
+	}()
+
+	disk, err := NewDisk(id, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}, {ID: "1", Size: 25489}}
+	uploadID := NewUploadID()
+	if err = disk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range parts {
+		tempFilename := NewTempFilename()
+		if _, err = disk.SaveTempFile(tempFilename, randReader(), part.Size, true); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = disk.UploadPart(uploadID, part.ID, tempFilename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srcID := NewDataID()
+	if err = disk.CompleteUpload(srcID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.Copy(NewDataID(), NewDataID(), 0, 10); !errors.Is(err, xerrors.ErrDataIDNotFound) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+
+	if err = disk.Copy(srcID, srcID, 0, 10); !errors.Is(err, xerrors.ErrDataIDAlreadyExist) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDAlreadyExist, err)
+	}
+
+	dataIDs := make([]DataID, len(testCases))
+	for i, testCase := range testCases {
+		dataIDs[i] = NewDataID()
+		if err = disk.Copy(dataIDs[i], srcID, testCase.offset, testCase.length); err != nil {
+			t.Fatalf("test%v: %v", i, err)
+		}
+
+		for _, partID := range testCase.linkedParts {
+			srcInfo, err := os.Stat(path.Join(disk.getDataDir(srcID), partID+".part"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(path.Join(disk.getDataDir(dataIDs[i]), partID+".part"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !os.SameFile(srcInfo, info) {
+				t.Fatalf("test%v: part %v: expected: <hard link>, got: <copy>", i, partID)
+			}
+		}
+	}
+
+	// Copied data is not affected by removal of source data.
+	if err = disk.Delete(srcID); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, testCase := range testCases {
+		if testCase.checksum == "" {
+			continue
+		}
+
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				rc, err := disk.Get(dataIDs[i], 0, testCase.length)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer rc.Close()
+
+				hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+				if _, err = io.Copy(hasher, rc); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum := hasher.HexSum(nil); checksum != testCase.checksum {
+					t.Fatalf("mismatch: checksum: expected: %v, got: %v", testCase.checksum, checksum)
+				}
+			},
+		)
+	}
+}
+
+func TestDataSpace(t *testing.T) {
+	dataspacetest.Run(t, func(t *testing.T) (dataspace.DataSpace, func()) {
+		id := xrand.NewID(8).String()
+		dataDir := id
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		disk, err := NewDisk(id, dataDir)
+		if err != nil {
+			os.RemoveAll(dataDir)
+			t.Fatal(err)
+		}
+
+		return disk, func() {
+			os.RemoveAll(dataDir)
+		}
+	})
+}
+
+func TestHeal(t *testing.T) {
+	id := xrand.NewID(8).String()
+	dataDir := id
+	if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dataDir)
+	}()
+
+	disk, err := NewDisk(id, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadID := NewUploadID()
+	if err = disk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}
+	for _, part := range parts {
+		tempFilename := NewTempFilename()
+		if _, err = disk.SaveTempFile(tempFilename, randReader(), part.Size, true); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = disk.UploadPart(uploadID, part.ID, tempFilename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dataID := NewDataID()
+	if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	partFile := func(partID string) string {
+		return path.Join(dataDir, "data", dataID.String()[:2], dataID.String(), partID+".part")
+	}
+
+	fi3, err := os.Stat(partFile("3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataInfo := &DataInfo{Parts: parts, Size: 16279 + 10992}
+	tempFilename := NewTempFilename()
+	if _, err = disk.SaveTempFile(tempFilename, randReader(), 10992, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reader of existing data continues to read it after heal.
+	oldRC, err := disk.Get(dataID, 16279, 10992)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.Heal(dataID, dataInfo, map[string]string{"8": tempFilename}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi, err := os.Stat(partFile("3")); err != nil || !os.SameFile(fi, fi3) {
+		t.Fatalf("mismatch: part 3: expected: <hard link>, got: %v", err)
+	}
+
+	// Existing data being read is not purged.
+	if count, _, err := disk.PurgeTrash(); err != nil || count != 0 {
+		t.Fatalf("mismatch: expected: 0, <nil>, got: %v, %v", count, err)
+	}
+
+	expectedChecksum := "60c7436deea126319878ecbf43b853f39f3451dccacde02b4e6a66082e9d168a"
+	for _, getReader := range []func() (io.ReadCloser, error){
+		func() (io.ReadCloser, error) { return disk.Get(dataID, 16279, 10992) },
+		func() (io.ReadCloser, error) { return oldRC, nil },
+	} {
+		rc, err := getReader()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+		_, err = io.Copy(hasher, rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if checksum := hasher.HexSum(nil); checksum != expectedChecksum {
+			t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+		}
+	}
+
+	// Existing data is in trash until it is purged.
+	if count, _, err := disk.PurgeTrash(); err != nil || count != 1 {
+		t.Fatalf("mismatch: expected: 1, <nil>, got: %v, %v", count, err)
+	}
+
+	// Parts not given by temporary files must exist.
+	if err = disk.Heal(NewDataID(), dataInfo, nil); err != xerrors.ErrPartNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrPartNotFound, err)
+	}
+}
+
+func TestHealRevertDelete(t *testing.T) {
+	id := xrand.NewID(8).String()
+	dataDir := id
+	if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dataDir)
+	}()
+
+	disk, err := NewDisk(id, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadID := NewUploadID()
+	if err = disk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFilename := NewTempFilename()
+	if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+		t.Fatal(err)
+	}
+
+	dataID := NewDataID()
+	parts := []Part{{ID: "1", Size: 16279}}
+	if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	partFile := path.Join(dataDir, "data", dataID.String()[:2], dataID.String(), "1.part")
+	file, err := os.OpenFile(partFile, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.WriteAt([]byte{0, 0, 0, 0}, 100); err != nil {
+		file.Close()
+		t.Fatal(err)
+	}
+	file.Close()
+
+	tempFilename = NewTempFilename()
+	if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.Heal(dataID, &DataInfo{Parts: parts, Size: 16279}, map[string]string{"1": tempFilename}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reverted Delete() restores healed data, not corrupt data replaced by Heal().
+	if err = disk.Delete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.RevertDelete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := disk.Get(dataID, 0, 16279)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+	_, err = io.Copy(hasher, rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedChecksum := "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"
+	if checksum := hasher.HexSum(nil); checksum != expectedChecksum {
+		t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+	}
+
+	// Nothing is left to revert.
+	if err = disk.RevertDelete(dataID); err != xerrors.ErrDataIDNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+}
+
+func TestNewScrubber(t *testing.T) {
+	id := xrand.NewID(8).String()
+	dataDir := id
+	if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dataDir)
+	}()
+
+	disk, err := NewDisk(id, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataIDs := make([]DataID, 2)
+	for i := range dataIDs {
+		uploadID := NewUploadID()
+		if err = disk.InitUpload(uploadID); err != nil {
+			t.Fatal(err)
+		}
+
+		tempFilename := NewTempFilename()
+		if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+			t.Fatal(err)
+		}
+
+		dataIDs[i] = NewDataID()
+		if err = disk.CompleteUpload(dataIDs[i], uploadID, []Part{{ID: "1", Size: 16279}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	partFile := path.Join(dataDir, "data", dataIDs[1].String()[:2], dataIDs[1].String(), "1.part")
+	file, err := os.OpenFile(partFile, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.WriteAt([]byte{0, 0, 0, 0}, 100); err != nil {
+		file.Close()
+		t.Fatal(err)
+	}
+	file.Close()
+
+	var corruptIDs []string
+	scrubber := disk.NewScrubber(0, func(dataID DataID, err error) error {
+		corruptIDs = append(corruptIDs, dataID.String())
+		return nil
+	})
+
+	count, corrupt, err := scrubber.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Fatalf("mismatch: count: expected: 2, got: %v", count)
+	}
+
+	if _, found := corrupt[dataIDs[1].String()]; len(corrupt) != 1 || !found {
+		t.Fatalf("mismatch: corrupt: expected: %v, got: %v", dataIDs[1], corrupt)
+	}
+
+	if !reflect.DeepEqual(corruptIDs, []string{dataIDs[1].String()}) {
+		t.Fatalf("mismatch: expected: %v, got: %v", dataIDs[1], corruptIDs)
+	}
+}
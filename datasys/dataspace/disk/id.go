@@ -1,32 +1,44 @@
 package disk
 
-import "github.com/balamurugana/goat/pkg/rand"
+import (
+	"github.com/balamurugana/goat/datasys/dataspace"
+	"github.com/balamurugana/goat/pkg/rand"
+)
+
+// ID types are defined in dataspace package and kept here for existing users.
+type (
+	DataID    = dataspace.DataID
+	UploadID  = dataspace.UploadID
+	VersionID = dataspace.VersionID
+)
 
 // NewTempFilename returns new temporary filename.
 func NewTempFilename() string {
-	return rand.NewID(128).String()
-}
-
-type DataID struct {
-	*rand.ID
+	return dataspace.NewTempFilename()
 }
 
 func NewDataID() DataID {
-	return DataID{rand.NewID(128)}
+	return dataspace.NewDataID()
 }
 
-type UploadID struct {
-	*rand.ID
+func NewUploadID() UploadID {
+	return dataspace.NewUploadID()
 }
 
-func NewUploadID() UploadID {
-	return UploadID{rand.NewID(128)}
+func NewVersionID() VersionID {
+	return dataspace.NewVersionID()
 }
 
-type VersionID struct {
-	*rand.ID
+func newTempName() string {
+	return rand.NewID(8).String()
 }
 
-func NewVersionID() VersionID {
-	return VersionID{rand.NewID(128)}
+// getIndex returns INDEX of given ID which is first two characters of the ID. ID shorter than two characters is
+// padded by '_' which is not used in IDs.
+func getIndex(id string) string {
+	if len(id) < 2 {
+		return (id + "__")[:2]
+	}
+
+	return id[:2]
 }
@@ -0,0 +1,56 @@
+package disk
+
+import (
+	"errors"
+	"os"
+	"path"
+
+	"github.com/balamurugana/goat/datasys/format"
+)
+
+// migrations migrate disk layout; migrations[i] migrates format version i to i+1.
+var migrations = []format.Migration{
+	// Version 0 is the layout before format.json is introduced which is same as version 1.
+	func(storeDir string) error { return nil },
+
+	// Version 2 moves <ID> directories of data/ and uploads/ to <INDEX>/<ID>.
+	func(storeDir string) error {
+		for _, dir := range []string{path.Join(storeDir, "data"), path.Join(storeDir, "uploads")} {
+			if err := moveToIndex(dir); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+// moveToIndex moves every <ID> directory in given directory to <INDEX>/<ID>. Already moved directories are
+// skipped as INDEX is two characters long.
+func moveToIndex(dir string) error {
+	names, modes, err := readdir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, name := range names {
+		if !modes[name].IsDir() || len(name) <= 2 {
+			continue
+		}
+
+		indexDir := path.Join(dir, getIndex(name))
+		if err = os.MkdirAll(indexDir, 0755); err != nil {
+			return err
+		}
+
+		if err = os.Rename(path.Join(dir, name), path.Join(indexDir, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package disk
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestMigrateToIndex(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			tempFilename := NewTempFilename()
+			if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.UploadPart(uploadID, "1", tempFilename); err != nil {
+				t.Fatal(err)
+			}
+
+			dataID := NewDataID()
+			if err = disk.CompleteUpload(dataID, uploadID, []Part{{ID: "1", Size: 16279}}); err != nil {
+				t.Fatal(err)
+			}
+
+			pendingUploadID := NewUploadID()
+			if err = disk.InitUpload(pendingUploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			// Convert to flat layout without format.json.
+			for _, name := range []string{path.Join("data", dataID.String()), path.Join("uploads", pendingUploadID.String())} {
+				indexDir := path.Join(dataDir, path.Dir(name), getIndex(path.Base(name)))
+				if err = os.Rename(path.Join(indexDir, path.Base(name)), path.Join(dataDir, name)); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = os.Remove(indexDir); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err = os.Remove(path.Join(dataDir, "format.json")); err != nil {
+				t.Fatal(err)
+			}
+
+			if disk, err = NewDisk(id, dataDir); err != nil {
+				t.Fatal(err)
+			}
+
+			if disk.Format().Version != len(migrations) {
+				t.Fatalf("version mismatch: expected: %v, got: %v", len(migrations), disk.Format().Version)
+			}
+
+			rc, err := disk.Get(dataID, 0, 16279)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(data) != 16279 {
+				t.Fatalf("length mismatch: expected: 16279, got: %v", len(data))
+			}
+
+			tempFilename = NewTempFilename()
+			if _, err = disk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = disk.UploadPart(pendingUploadID, "1", tempFilename); err != nil {
+				t.Fatal(err)
+			}
+		},
+	)
+}
@@ -0,0 +1,153 @@
+package disk
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/balamurugana/goat/datasys/recovery"
+	xos "github.com/balamurugana/goat/pkg/os"
+)
+
+// readdir returns sorted entry names of given directory and their modes.
+func readdir(dir string) ([]string, map[string]os.FileMode, error) {
+	modes := make(map[string]os.FileMode)
+	picker := func(name string, mode os.FileMode) (stop bool) {
+		modes[name] = mode
+		return false
+	}
+
+	if err := xos.Readdirnames(dir, picker); err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(modes))
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, modes, nil
+}
+
+// recoverStore repairs leftovers of operations interrupted by crash. As no operation survives a restart,
+// every leftover is rolled back by moving it to trash.
+func (disk *Disk) recoverStore() (*recovery.Report, error) {
+	report := &recovery.Report{}
+
+	if err := disk.recoverTmp(report); err != nil {
+		return nil, err
+	}
+
+	if err := disk.recoverUploads(report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// recoverTmp moves files left by SaveTempFile() and not used by UploadPart(), and directories left by
+// interrupted Copy() or Heal() to trash.
+func (disk *Disk) recoverTmp(report *recovery.Report) error {
+	names, modes, err := readdir(disk.tmpDir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		tmpFile := path.Join(disk.tmpDir, name)
+		trashFile := path.Join(disk.trashDir, name+"."+newTempName())
+
+		if modes[name].IsDir() {
+			report.Add(path.Join("tmp", name), "directory of interrupted Copy or Heal", recovery.RollBack, os.Rename(tmpFile, trashFile))
+			continue
+		}
+
+		if strings.HasSuffix(name, ".checksum") {
+			if _, found := modes[strings.TrimSuffix(name, ".checksum")]; !found {
+				report.Add(path.Join("tmp", name), "checksum file without data", recovery.RollBack, os.Rename(tmpFile, trashFile))
+			}
+
+			continue
+		}
+
+		if _, found := modes[name+".checksum"]; found || xos.IsInlineFile(tmpFile) {
+			report.Add(path.Join("tmp", name), "unused temporary file", recovery.RollBack, xos.RenameFile(tmpFile, trashFile, true))
+		} else {
+			report.Add(path.Join("tmp", name), "temporary file without checksum", recovery.RollBack, os.Rename(tmpFile, trashFile))
+		}
+	}
+
+	return nil
+}
+
+// recoverUploads moves data.json left by CompleteUpload() and part files half renamed by
+// UploadPart()/RevertUploadPart() and part metadata files without part files to trash.
+func (disk *Disk) recoverUploads(report *recovery.Report) error {
+	indices, modes, err := readdir(disk.uploadsDir)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indices {
+		if !modes[index].IsDir() {
+			continue
+		}
+
+		if err = disk.recoverUploadIndex(report, index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (disk *Disk) recoverUploadIndex(report *recovery.Report, index string) error {
+	uploadIDs, modes, err := readdir(path.Join(disk.uploadsDir, index))
+	if err != nil {
+		return err
+	}
+
+	for _, uploadID := range uploadIDs {
+		if !modes[uploadID].IsDir() {
+			continue
+		}
+
+		uploadIDDir := path.Join(disk.uploadsDir, index, uploadID)
+		names, modes, err := readdir(uploadIDDir)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			var reason string
+			switch {
+			case name == "data.json":
+				reason = "data.json of interrupted CompleteUpload"
+			case strings.HasSuffix(name, ".part.checksum"):
+				if _, found := modes[strings.TrimSuffix(name, ".checksum")]; found {
+					continue
+				}
+				reason = "part checksum file without part file"
+			case strings.HasSuffix(name, ".part.json"):
+				if _, found := modes[strings.TrimSuffix(name, ".json")]; found {
+					continue
+				}
+				reason = "part metadata file without part file"
+			case strings.HasSuffix(name, ".part"):
+				if _, found := modes[name+".checksum"]; found || xos.IsInlineFile(path.Join(uploadIDDir, name)) {
+					continue
+				}
+				reason = "part file without checksum file"
+			default:
+				continue
+			}
+
+			err := os.Rename(path.Join(uploadIDDir, name), path.Join(disk.trashDir, uploadID+"."+name+"."+newTempName()))
+			report.Add(path.Join("uploads", index, uploadID, name), reason, recovery.RollBack, err)
+		}
+	}
+
+	return nil
+}
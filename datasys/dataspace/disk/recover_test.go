@@ -0,0 +1,175 @@
+package disk
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/balamurugana/goat/datasys/recovery"
+	xos "github.com/balamurugana/goat/pkg/os"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestRecoverStore(t *testing.T) {
+	t.Run(
+		"test0",
+		func(t *testing.T) {
+			id := xrand.NewID(8).String()
+			dataDir := id
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() {
+				os.RemoveAll(dataDir)
+			}()
+
+			disk, err := NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if entries := disk.RecoveryReport().Entries; len(entries) != 0 {
+				t.Fatalf("mismatch: expected: <empty>, got: %v", entries)
+			}
+
+			uploadID := NewUploadID()
+			if err = disk.InitUpload(uploadID); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, partID := range []string{"1", "2", "3"} {
+				tempFilename := NewTempFilename()
+				if _, err = disk.SaveTempFile(tempFilename, randReader(), 1024, true); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = disk.UploadPart(uploadID, partID, tempFilename); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Unused temporary file.
+			if _, err = disk.SaveTempFile("unused", randReader(), 1024, true); err != nil {
+				t.Fatal(err)
+			}
+
+			// UploadPart() interrupted after renaming checksum file.
+			if _, err = disk.SaveTempFile("interrupted", randReader(), 1024, true); err != nil {
+				t.Fatal(err)
+			}
+			uploadIDDir := path.Join(dataDir, "uploads", uploadID.String()[:2], uploadID.String())
+			if err = os.Rename(path.Join(dataDir, "tmp", "interrupted.checksum"), path.Join(uploadIDDir, "4.part.checksum")); err != nil {
+				t.Fatal(err)
+			}
+
+			// RevertUploadPart() interrupted after renaming checksum file.
+			if err = os.Rename(path.Join(uploadIDDir, "3.part.checksum"), path.Join(dataDir, "tmp", "reverted.checksum")); err != nil {
+				t.Fatal(err)
+			}
+
+			// CompleteUpload() interrupted before renaming upload ID directory.
+			if err = ioutil.WriteFile(path.Join(uploadIDDir, "data.json"), []byte("{"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			disk, err = NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectedEntries := []recovery.Entry{
+				{Path: path.Join("tmp", "interrupted"), Reason: "temporary file without checksum", Action: recovery.RollBack},
+				{Path: path.Join("tmp", "reverted.checksum"), Reason: "checksum file without data", Action: recovery.RollBack},
+				{Path: path.Join("tmp", "unused"), Reason: "unused temporary file", Action: recovery.RollBack},
+				{Path: path.Join("uploads", uploadID.String()[:2], uploadID.String(), "3.part"), Reason: "part file without checksum file", Action: recovery.RollBack},
+				{Path: path.Join("uploads", uploadID.String()[:2], uploadID.String(), "4.part.checksum"), Reason: "part checksum file without part file", Action: recovery.RollBack},
+				{Path: path.Join("uploads", uploadID.String()[:2], uploadID.String(), "data.json"), Reason: "data.json of interrupted CompleteUpload", Action: recovery.RollBack},
+			}
+
+			entries := disk.RecoveryReport().Entries
+			sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+			if !reflect.DeepEqual(entries, expectedEntries) {
+				t.Fatalf("mismatch: expected: %v, got: %v", expectedEntries, entries)
+			}
+
+			var names []string
+			picker := func(name string, mode os.FileMode) (stop bool) {
+				names = append(names, name)
+				return false
+			}
+			if err = xos.Readdirnames(uploadIDDir, picker); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(names)
+
+			expectedNames := []string{"1.part", "1.part.checksum", "2.part", "2.part.checksum"}
+			if !reflect.DeepEqual(names, expectedNames) {
+				t.Fatalf("mismatch: expected: %v, got: %v", expectedNames, names)
+			}
+
+			dataID := NewDataID()
+			if err = disk.CompleteUpload(dataID, uploadID, []Part{{ID: "1", Size: 1024}, {ID: "2", Size: 1024}}); err != nil {
+				t.Fatal(err)
+			}
+
+			disk, err = NewDisk(id, dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if entries := disk.RecoveryReport().Entries; len(entries) != 0 {
+				t.Fatalf("mismatch: expected: <empty>, got: %v", entries)
+			}
+		},
+	)
+}
+
+func TestRecoverTmpSameName(t *testing.T) {
+	id := xrand.NewID(8).String()
+	dataDir := id
+	if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dataDir)
+	}()
+
+	if _, err := NewDisk(id, dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same leftover names across restarts do not collide in trash.
+	for i := 0; i < 2; i++ {
+		leftoverDir := path.Join(dataDir, "tmp", "interrupted")
+		if err := os.Mkdir(leftoverDir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path.Join(leftoverDir, "data.json"), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		disk, err := NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if entries := disk.RecoveryReport().Failed(); len(entries) != 0 {
+			t.Fatalf("mismatch: expected: <empty>, got: %v", entries)
+		}
+	}
+
+	entries, err := os.ReadDir(path.Join(dataDir, "trash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("mismatch: expected: 2 trash entries, got: %v", len(entries))
+	}
+}
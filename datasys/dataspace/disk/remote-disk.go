@@ -0,0 +1,348 @@
+package disk
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	"github.com/balamurugana/goat/locksys"
+	xos "github.com/balamurugana/goat/pkg/os"
+	xrpc "github.com/balamurugana/goat/pkg/rpc"
+)
+
+// rpcErrors are errors of Disk restored from error message received by RemoteDisk, so that callers can check
+// them by errors.Is() like errors of local disk.
+var rpcErrors = []error{
+	xerrors.ErrUploadIDAlreadyExist,
+	xerrors.ErrUploadIDNotFound,
+	xerrors.ErrPartChecksumNotFound,
+	xerrors.ErrPartNotFound,
+	xerrors.ErrDataIDAlreadyExist,
+	xerrors.ErrDataIDNotFound,
+	xerrors.ErrInvalidName,
+	xerrors.ErrDiskIDMismatch,
+	xerrors.ErrDiskTypeMismatch,
+	xerrors.ErrDiskPositionMismatch,
+	xerrors.ErrUnsupportedFormat,
+}
+
+func toRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	for _, rpcErr := range rpcErrors {
+		if err.Error() == rpcErr.Error() {
+			return rpcErr
+		}
+	}
+
+	return err
+}
+
+// RemoteDisk is client of disk served by NewDiskRPCServer() on another node. It provides Disk methods used by
+// erasure sets, so that shards can be stored on disks of remote nodes.
+type RemoteDisk struct {
+	id     string
+	client *locksys.RPCClient
+}
+
+// NewRemoteDisk returns client of disk of given ID served at serviceURL. No connection is made until first call.
+func NewRemoteDisk(id, serviceURL string, tlsConfig *tls.Config) *RemoteDisk {
+	return &RemoteDisk{
+		id:     id,
+		client: locksys.NewRPCClient(serviceURL, tlsConfig, xrpc.DefaultRPCTimeout, locksys.APIVersion()),
+	}
+}
+
+func (disk *RemoteDisk) ID() string {
+	return disk.id
+}
+
+func (disk *RemoteDisk) args() DiskRPCArgs {
+	return DiskRPCArgs{DiskID: disk.id}
+}
+
+func (disk *RemoteDisk) call(serviceMethod string, args interface {
+	SetAuthArgs(args locksys.AuthArgs)
+}, reply interface{}) error {
+	return toRPCError(disk.client.Call(serviceMethod, args, reply))
+}
+
+func (disk *RemoteDisk) SetPosition(deploymentID string, setIndex, diskIndex int) error {
+	args := &SetPositionRPCArgs{DiskRPCArgs: disk.args(), DeploymentID: deploymentID, SetIndex: setIndex, DiskIndex: diskIndex}
+	return disk.call(diskSetPosition, args, &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) SaveTempFile(filename string, data io.Reader, size uint64, bitrotProtection bool) (checksum string, err error) {
+	args := &TempFileRPCArgs{DiskRPCArgs: disk.args(), Filename: filename, Length: size, BitrotProtection: bitrotProtection}
+	if size != xos.UnknownSize {
+		data = io.LimitReader(data, int64(size))
+	}
+
+	body, err := disk.client.CallWith(diskSaveTempFile, args, data, &checksum)
+	if body != nil {
+		body.Close()
+	}
+
+	return checksum, toRPCError(err)
+}
+
+func (disk *RemoteDisk) GetTempFile(filename string, offset int64, length uint64, bitrotProtection bool) (io.ReadCloser, error) {
+	args := &TempFileRPCArgs{DiskRPCArgs: disk.args(), Filename: filename, Offset: offset, Length: length, BitrotProtection: bitrotProtection}
+	body, err := disk.client.CallWith(diskGetTempFile, args, nil, &locksys.VoidReply{})
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+
+	return &rpcBody{ReadCloser: body, remaining: length}, nil
+}
+
+func (disk *RemoteDisk) RemoveTempFile(filename string, bitrotProtection bool) error {
+	args := &TempFileRPCArgs{DiskRPCArgs: disk.args(), Filename: filename, BitrotProtection: bitrotProtection}
+	return disk.call(diskRemoveTempFile, args, &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) uploadArgs(uploadID UploadID) *UploadRPCArgs {
+	return &UploadRPCArgs{DiskRPCArgs: disk.args(), UploadID: uploadID.String()}
+}
+
+func (disk *RemoteDisk) InitUpload(uploadID UploadID) error {
+	return disk.call(diskInitUpload, disk.uploadArgs(uploadID), &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) RevertInitUpload(uploadID UploadID) error {
+	return disk.call(diskRevertInitUpload, disk.uploadArgs(uploadID), &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) UploadPart(uploadID UploadID, partID, tempFile string) error {
+	return disk.UploadPartWithMeta(uploadID, partID, tempFile, nil)
+}
+
+func (disk *RemoteDisk) UploadPartWithMeta(uploadID UploadID, partID, tempFile string, meta json.RawMessage) error {
+	args := &UploadPartRPCArgs{UploadRPCArgs: *disk.uploadArgs(uploadID), PartID: partID, TempFile: tempFile, Meta: meta}
+	return disk.call(diskUploadPartWithMeta, args, &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) RevertUploadPart(uploadID UploadID, partID, tempFile string) error {
+	args := &UploadPartRPCArgs{UploadRPCArgs: *disk.uploadArgs(uploadID), PartID: partID, TempFile: tempFile}
+	return disk.call(diskRevertUploadPart, args, &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) AbortUpload(uploadID UploadID) error {
+	return disk.call(diskAbortUpload, disk.uploadArgs(uploadID), &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) RevertAbortUpload(uploadID UploadID) error {
+	return disk.call(diskRevertAbortUpload, disk.uploadArgs(uploadID), &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) completeUploadArgs(dataID DataID, uploadID UploadID, parts []Part) *CompleteUploadRPCArgs {
+	return &CompleteUploadRPCArgs{DataRPCArgs: *disk.dataArgs(dataID, 0, 0), UploadID: uploadID.String(), Parts: parts}
+}
+
+func (disk *RemoteDisk) CompleteUpload(dataID DataID, uploadID UploadID, parts []Part) error {
+	return disk.call(diskCompleteUpload, disk.completeUploadArgs(dataID, uploadID, parts), &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) RevertCompleteUpload(dataID DataID, uploadID UploadID, parts []Part) error {
+	return disk.call(diskRevertCompleteUpload, disk.completeUploadArgs(dataID, uploadID, parts), &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) ListParts(uploadID UploadID) ([]Part, error) {
+	var parts []Part
+	if err := disk.call(diskListParts, disk.uploadArgs(uploadID), &parts); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+func (disk *RemoteDisk) dataArgs(dataID DataID, offset int64, length uint64) *DataRPCArgs {
+	return &DataRPCArgs{DiskRPCArgs: disk.args(), DataID: dataID.String(), Offset: offset, Length: length}
+}
+
+// Get returns reader of given range of data. Returned reader also supports random access within the range.
+// Data is not transferred until read; each Read() after Seek() and each ReadAt() is an RPC call. Unlike
+// Disk.Get(), returned reader can not read data once it is deleted by Delete().
+func (disk *RemoteDisk) Get(dataID DataID, offset int64, length uint64) (DataReader, error) {
+	if err := disk.call(diskCheckRange, disk.dataArgs(dataID, offset, length), &locksys.VoidReply{}); err != nil {
+		return nil, err
+	}
+
+	return &remoteDataReader{
+		disk:   disk,
+		dataID: dataID,
+		offset: offset,
+		length: int64(length),
+	}, nil
+}
+
+func (disk *RemoteDisk) GetMetadata(dataID DataID) (*DataInfo, error) {
+	var dataInfo DataInfo
+	if err := disk.call(diskGetMetadata, disk.dataArgs(dataID, 0, 0), &dataInfo); err != nil {
+		return nil, err
+	}
+
+	return &dataInfo, nil
+}
+
+func (disk *RemoteDisk) Delete(dataID DataID) error {
+	return disk.call(diskDelete, disk.dataArgs(dataID, 0, 0), &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) RevertDelete(dataID DataID) error {
+	return disk.call(diskRevertDelete, disk.dataArgs(dataID, 0, 0), &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) Copy(dataID, srcID DataID, offset int64, length uint64) error {
+	args := &CopyRPCArgs{DataRPCArgs: *disk.dataArgs(dataID, offset, length), SrcID: srcID.String()}
+	return disk.call(diskCopy, args, &locksys.VoidReply{})
+}
+
+func (disk *RemoteDisk) Heal(dataID DataID, dataInfo *DataInfo, tempFiles map[string]string) error {
+	args := &HealRPCArgs{DataRPCArgs: *disk.dataArgs(dataID, 0, 0), DataInfo: *dataInfo, TempFiles: tempFiles}
+	return disk.call(diskHeal, args, &locksys.VoidReply{})
+}
+
+// openRange returns stream of given range of data from remote disk.
+func (disk *RemoteDisk) openRange(dataID DataID, offset int64, length uint64) (io.ReadCloser, error) {
+	body, err := disk.client.CallWith(diskGet, disk.dataArgs(dataID, offset, length), nil, &locksys.VoidReply{})
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+
+	return &rpcBody{ReadCloser: body, remaining: length}, nil
+}
+
+// rpcBody is stream of known length received after RPC reply. As RPC server can not report error after the
+// reply is sent, stream ending early is io.ErrUnexpectedEOF.
+type rpcBody struct {
+	io.ReadCloser
+	remaining uint64
+}
+
+func (body *rpcBody) Read(b []byte) (int, error) {
+	if body.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	if uint64(len(b)) > body.remaining {
+		b = b[:body.remaining]
+	}
+
+	n, err := body.ReadCloser.Read(b)
+	body.remaining -= uint64(n)
+	if errors.Is(err, io.EOF) && body.remaining != 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+type remoteDataReader struct {
+	disk   *RemoteDisk
+	dataID DataID
+	offset int64
+	length int64
+	pos    int64
+	rc     io.ReadCloser
+}
+
+func (dr *remoteDataReader) Read(b []byte) (n int, err error) {
+	if dr.pos >= dr.length {
+		return 0, io.EOF
+	}
+
+	if dr.rc == nil {
+		if dr.rc, err = dr.disk.openRange(dr.dataID, dr.offset+dr.pos, uint64(dr.length-dr.pos)); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = dr.rc.Read(b)
+	dr.pos += int64(n)
+	if err != nil {
+		dr.rc.Close()
+		dr.rc = nil
+
+		if errors.Is(err, io.EOF) && dr.pos < dr.length {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+
+	return n, err
+}
+
+// Seek sets position of next Read(). Stream being read is closed.
+func (dr *remoteDataReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += dr.pos
+	case io.SeekEnd:
+		offset += dr.length
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if dr.rc != nil {
+		dr.rc.Close()
+		dr.rc = nil
+	}
+
+	dr.pos = offset
+	return offset, nil
+}
+
+// ReadAt reads len(b) bytes from offset off of the range by its own RPC call.
+func (dr *remoteDataReader) ReadAt(b []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if off >= dr.length {
+		return 0, io.EOF
+	}
+
+	truncated := false
+	if remaining := dr.length - off; int64(len(b)) > remaining {
+		b = b[:remaining]
+		truncated = true
+	}
+
+	rc, err := dr.disk.openRange(dr.dataID, dr.offset+off, uint64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	if n, err = io.ReadFull(rc, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return n, err
+	}
+
+	if truncated {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (dr *remoteDataReader) Close() (err error) {
+	if dr.rc != nil {
+		err = dr.rc.Close()
+		dr.rc = nil
+	}
+
+	return err
+}
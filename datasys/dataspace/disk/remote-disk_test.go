@@ -0,0 +1,290 @@
+package disk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	xhash "github.com/balamurugana/goat/pkg/hash"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestRemoteDisk(t *testing.T) {
+	id := xrand.NewID(8).String()
+	dataDir := id
+	if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dataDir)
+	}()
+
+	localDisk, err := NewDisk(id, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewDiskRPCServer([]*Disk{localDisk}))
+	defer server.Close()
+
+	disk := NewRemoteDisk(id, server.URL, nil)
+
+	uploadID := NewUploadID()
+	if err = disk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.InitUpload(uploadID); !errors.Is(err, xerrors.ErrUploadIDAlreadyExist) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrUploadIDAlreadyExist, err)
+	}
+
+	parts := []Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}
+	for _, part := range parts {
+		tempFilename := NewTempFilename()
+		checksum, err := disk.SaveTempFile(tempFilename, randReader(), part.Size, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedChecksum, err := localDisk.SaveTempFile(NewTempFilename(), randReader(), part.Size, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if checksum != expectedChecksum {
+			t.Fatalf("mismatch: expected: %v, got: %v", expectedChecksum, checksum)
+		}
+
+		if err = disk.UploadPart(uploadID, part.ID, tempFilename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uploadedParts, err := disk.ListParts(uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(uploadedParts) != len(parts) {
+		t.Fatalf("mismatch: expected: %v parts, got: %+v", len(parts), uploadedParts)
+	}
+
+	dataID := NewDataID()
+	if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.RevertCompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = disk.GetMetadata(dataID); !errors.Is(err, xerrors.ErrDataIDNotFound) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+
+	if err = disk.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	dataInfo, err := disk.GetMetadata(dataID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dataInfo.Size != 27271 {
+		t.Fatalf("mismatch: expected: 27271, got: %v", dataInfo.Size)
+	}
+
+	rc, err := disk.Get(dataID, 12958, 10992)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+	hasher.Write(data)
+	expectedChecksum := "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"
+	if checksum := hasher.HexSum(nil); checksum != expectedChecksum {
+		t.Fatalf("mismatch: expected: %v, got: %v", expectedChecksum, checksum)
+	}
+
+	b := make([]byte, 5000)
+	if _, err = rc.ReadAt(b, 3000); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data[3000:8000]) {
+		t.Fatalf("mismatch: ReadAt() data differs")
+	}
+
+	if n, err := rc.ReadAt(b, 8000); n != 2992 || !errors.Is(err, io.EOF) {
+		t.Fatalf("mismatch: expected: 2992, %v, got: %v, %v", io.EOF, n, err)
+	}
+
+	if _, err = rc.Seek(-100, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = ioutil.ReadAll(rc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data[len(data)-100:]) {
+		t.Fatalf("mismatch: data after Seek() differs")
+	}
+
+	if _, err = disk.Get(dataID, 20000, 10000); err == nil {
+		t.Fatalf("mismatch: expected: <error>, got: <nil>")
+	}
+
+	if err = disk.Delete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = disk.Get(dataID, 0, 0); !errors.Is(err, xerrors.ErrDataIDNotFound) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+
+	if err = disk.RevertDelete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = disk.GetMetadata(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = NewRemoteDisk("unknown", server.URL, nil).InitUpload(NewUploadID()); err == nil {
+		t.Fatalf("mismatch: expected: <error>, got: <nil>")
+	}
+}
+
+func TestRemoteDiskInvalidNames(t *testing.T) {
+	id := xrand.NewID(8).String()
+	workDir := id
+	dataDir := path.Join(workDir, "data")
+	if err := os.MkdirAll(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	// File outside of the disk reachable by path traversal from its tmp directory.
+	secretFile := path.Join(workDir, "secret")
+	if err := ioutil.WriteFile(secretFile, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	localDisk, err := NewDisk(id, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewDiskRPCServer([]*Disk{localDisk}))
+	defer server.Close()
+
+	disk := NewRemoteDisk(id, server.URL, nil)
+
+	names := []string{"../../secret", "../../written", "..", ".", "", "a/b", "a\\b"}
+	for _, name := range names {
+		if _, err = disk.SaveTempFile(name, randReader(), 100, false); !errors.Is(err, xerrors.ErrInvalidName) {
+			t.Fatalf("%q: mismatch: expected: %v, got: %v", name, xerrors.ErrInvalidName, err)
+		}
+
+		if _, err = disk.GetTempFile(name, 0, 6, false); !errors.Is(err, xerrors.ErrInvalidName) {
+			t.Fatalf("%q: mismatch: expected: %v, got: %v", name, xerrors.ErrInvalidName, err)
+		}
+
+		if err = disk.RemoveTempFile(name, false); !errors.Is(err, xerrors.ErrInvalidName) {
+			t.Fatalf("%q: mismatch: expected: %v, got: %v", name, xerrors.ErrInvalidName, err)
+		}
+	}
+
+	uploadID := NewUploadID()
+	if err = disk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = disk.UploadPart(uploadID, "1", "../../secret"); !errors.Is(err, xerrors.ErrInvalidName) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrInvalidName, err)
+	}
+
+	if err = disk.UploadPart(uploadID, "../../../part", NewTempFilename()); !errors.Is(err, xerrors.ErrInvalidName) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrInvalidName, err)
+	}
+
+	if err = disk.CompleteUpload(NewDataID(), uploadID, []Part{{ID: "../../secret", Size: 6}}); !errors.Is(err, xerrors.ErrInvalidName) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrInvalidName, err)
+	}
+
+	if err = disk.Heal(NewDataID(), &DataInfo{Parts: []Part{{ID: "1", Size: 6}}}, map[string]string{"1": "../../secret"}); !errors.Is(err, xerrors.ErrInvalidName) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrInvalidName, err)
+	}
+
+	if _, err = os.Stat(secretFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(path.Join(workDir, "written")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("mismatch: expected: %v, got: %v", os.ErrNotExist, err)
+	}
+}
+
+func TestRemoteDiskInvalidIDs(t *testing.T) {
+	id := xrand.NewID(8).String()
+	dataDir := id
+	if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dataDir)
+	}()
+
+	localDisk, err := NewDisk(id, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewDiskRPCServer([]*Disk{localDisk}))
+	defer server.Close()
+
+	disk := NewRemoteDisk(id, server.URL, nil)
+
+	for _, s := range []string{"", "AA", "AAAA"} {
+		randID, err := xrand.ParseID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = disk.GetMetadata(DataID{ID: randID}); err == nil {
+			t.Fatalf("%q: mismatch: expected: <error>, got: <nil>", s)
+		}
+
+		if err = disk.InitUpload(UploadID{ID: randID}); err == nil {
+			t.Fatalf("%q: mismatch: expected: <error>, got: <nil>", s)
+		}
+
+		// Local disk does not panic on short IDs either.
+		if _, err = localDisk.GetMetadata(DataID{ID: randID}); !errors.Is(err, xerrors.ErrDataIDNotFound) {
+			t.Fatalf("%q: mismatch: expected: %v, got: %v", s, xerrors.ErrDataIDNotFound, err)
+		}
+	}
+
+	for s, expectedResult := range map[string]string{"": "__", "A": "A_", "AB": "AB", "ABC": "AB"} {
+		if result := getIndex(s); result != expectedResult {
+			t.Fatalf("%q: mismatch: expected: %v, got: %v", s, expectedResult, result)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package disk
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/balamurugana/goat/datasys/dataspace"
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	"github.com/balamurugana/goat/locksys"
+	xrpc "github.com/balamurugana/goat/pkg/rpc"
+)
+
+const (
+	diskServiceName          = "Disk"
+	diskSetPosition          = diskServiceName + ".SetPosition"
+	diskSaveTempFile         = diskServiceName + ".SaveTempFile"
+	diskGetTempFile          = diskServiceName + ".GetTempFile"
+	diskRemoveTempFile       = diskServiceName + ".RemoveTempFile"
+	diskInitUpload           = diskServiceName + ".InitUpload"
+	diskRevertInitUpload     = diskServiceName + ".RevertInitUpload"
+	diskUploadPartWithMeta   = diskServiceName + ".UploadPartWithMeta"
+	diskRevertUploadPart     = diskServiceName + ".RevertUploadPart"
+	diskAbortUpload          = diskServiceName + ".AbortUpload"
+	diskRevertAbortUpload    = diskServiceName + ".RevertAbortUpload"
+	diskCompleteUpload       = diskServiceName + ".CompleteUpload"
+	diskRevertCompleteUpload = diskServiceName + ".RevertCompleteUpload"
+	diskListParts            = diskServiceName + ".ListParts"
+	diskCheckRange           = diskServiceName + ".CheckRange"
+	diskGet                  = diskServiceName + ".Get"
+	diskGetMetadata          = diskServiceName + ".GetMetadata"
+	diskDelete               = diskServiceName + ".Delete"
+	diskRevertDelete         = diskServiceName + ".RevertDelete"
+	diskCopy                 = diskServiceName + ".Copy"
+	diskHeal                 = diskServiceName + ".Heal"
+)
+
+type diskRPCReceiver struct {
+	disks map[string]*Disk
+}
+
+// DiskRPCArgs is base argument of disk RPC calls. DiskID selects disk served by the RPC server.
+type DiskRPCArgs struct {
+	locksys.AuthArgs
+	DiskID string
+}
+
+func (receiver *diskRPCReceiver) getDisk(args *DiskRPCArgs) (*Disk, error) {
+	disk, found := receiver.disks[args.DiskID]
+	if !found {
+		return nil, fmt.Errorf("disk %v not found", args.DiskID)
+	}
+
+	return disk, nil
+}
+
+// checkNames returns ErrInvalidName if any of names received from peer is not a single clean path element, so
+// that temporary file and part names can not refer to files outside of directories of the disk.
+func checkNames(names ...string) error {
+	for _, name := range names {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+			return xerrors.ErrInvalidName
+		}
+	}
+
+	return nil
+}
+
+// checkPartNames returns ErrInvalidName if ID of any part is invalid name.
+func checkPartNames(parts []Part) error {
+	for _, part := range parts {
+		if err := checkNames(part.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type SetPositionRPCArgs struct {
+	DiskRPCArgs
+	DeploymentID string
+	SetIndex     int
+	DiskIndex    int
+}
+
+func (receiver *diskRPCReceiver) SetPosition(args *SetPositionRPCArgs, reply *locksys.VoidReply) error {
+	disk, err := receiver.getDisk(&args.DiskRPCArgs)
+	if err != nil {
+		return err
+	}
+
+	return disk.SetPosition(args.DeploymentID, args.SetIndex, args.DiskIndex)
+}
+
+// TempFileRPCArgs is argument of temporary file calls. Length is size of data streamed by SaveTempFile.
+type TempFileRPCArgs struct {
+	DiskRPCArgs
+	Filename         string
+	Offset           int64
+	Length           uint64
+	BitrotProtection bool
+}
+
+// getTempFileDisk returns disk of args after checking name of temporary file.
+func (receiver *diskRPCReceiver) getTempFileDisk(args *TempFileRPCArgs) (*Disk, error) {
+	if err := checkNames(args.Filename); err != nil {
+		return nil, err
+	}
+
+	return receiver.getDisk(&args.DiskRPCArgs)
+}
+
+func (receiver *diskRPCReceiver) SaveTempFile(args *TempFileRPCArgs, reader io.Reader, reply *string) (io.ReadCloser, error) {
+	disk, err := receiver.getTempFileDisk(args)
+	if err != nil {
+		return nil, err
+	}
+
+	*reply, err = disk.SaveTempFile(args.Filename, reader, args.Length, args.BitrotProtection)
+	return nil, err
+}
+
+func (receiver *diskRPCReceiver) GetTempFile(args *TempFileRPCArgs, reader io.Reader, reply *locksys.VoidReply) (io.ReadCloser, error) {
+	disk, err := receiver.getTempFileDisk(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return disk.GetTempFile(args.Filename, args.Offset, args.Length, args.BitrotProtection)
+}
+
+func (receiver *diskRPCReceiver) RemoveTempFile(args *TempFileRPCArgs, reply *locksys.VoidReply) error {
+	disk, err := receiver.getTempFileDisk(args)
+	if err != nil {
+		return err
+	}
+
+	return disk.RemoveTempFile(args.Filename, args.BitrotProtection)
+}
+
+type UploadRPCArgs struct {
+	DiskRPCArgs
+	UploadID string
+}
+
+func (receiver *diskRPCReceiver) uploadCall(args *UploadRPCArgs, fn func(disk *Disk, uploadID UploadID) error) error {
+	disk, err := receiver.getDisk(&args.DiskRPCArgs)
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := dataspace.ParseUploadID(args.UploadID)
+	if err != nil {
+		return err
+	}
+
+	return fn(disk, uploadID)
+}
+
+func (receiver *diskRPCReceiver) InitUpload(args *UploadRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.uploadCall(args, (*Disk).InitUpload)
+}
+
+func (receiver *diskRPCReceiver) RevertInitUpload(args *UploadRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.uploadCall(args, (*Disk).RevertInitUpload)
+}
+
+func (receiver *diskRPCReceiver) AbortUpload(args *UploadRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.uploadCall(args, (*Disk).AbortUpload)
+}
+
+func (receiver *diskRPCReceiver) RevertAbortUpload(args *UploadRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.uploadCall(args, (*Disk).RevertAbortUpload)
+}
+
+func (receiver *diskRPCReceiver) ListParts(args *UploadRPCArgs, reply *[]Part) error {
+	return receiver.uploadCall(args, func(disk *Disk, uploadID UploadID) (err error) {
+		*reply, err = disk.ListParts(uploadID)
+		return err
+	})
+}
+
+type UploadPartRPCArgs struct {
+	UploadRPCArgs
+	PartID   string
+	TempFile string
+	Meta     []byte
+}
+
+func (receiver *diskRPCReceiver) UploadPartWithMeta(args *UploadPartRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.uploadCall(&args.UploadRPCArgs, func(disk *Disk, uploadID UploadID) error {
+		if err := checkNames(args.PartID, args.TempFile); err != nil {
+			return err
+		}
+
+		return disk.UploadPartWithMeta(uploadID, args.PartID, args.TempFile, args.Meta)
+	})
+}
+
+func (receiver *diskRPCReceiver) RevertUploadPart(args *UploadPartRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.uploadCall(&args.UploadRPCArgs, func(disk *Disk, uploadID UploadID) error {
+		if err := checkNames(args.PartID, args.TempFile); err != nil {
+			return err
+		}
+
+		return disk.RevertUploadPart(uploadID, args.PartID, args.TempFile)
+	})
+}
+
+type DataRPCArgs struct {
+	DiskRPCArgs
+	DataID string
+	Offset int64
+	Length uint64
+}
+
+func (receiver *diskRPCReceiver) dataCall(args *DataRPCArgs, fn func(disk *Disk, dataID DataID) error) error {
+	disk, err := receiver.getDisk(&args.DiskRPCArgs)
+	if err != nil {
+		return err
+	}
+
+	dataID, err := dataspace.ParseDataID(args.DataID)
+	if err != nil {
+		return err
+	}
+
+	return fn(disk, dataID)
+}
+
+type CompleteUploadRPCArgs struct {
+	DataRPCArgs
+	UploadID string
+	Parts    []Part
+}
+
+func (receiver *diskRPCReceiver) completeUploadCall(args *CompleteUploadRPCArgs, fn func(disk *Disk, dataID DataID, uploadID UploadID, parts []Part) error) error {
+	return receiver.dataCall(&args.DataRPCArgs, func(disk *Disk, dataID DataID) error {
+		uploadID, err := dataspace.ParseUploadID(args.UploadID)
+		if err != nil {
+			return err
+		}
+
+		if err = checkPartNames(args.Parts); err != nil {
+			return err
+		}
+
+		return fn(disk, dataID, uploadID, args.Parts)
+	})
+}
+
+func (receiver *diskRPCReceiver) CompleteUpload(args *CompleteUploadRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.completeUploadCall(args, (*Disk).CompleteUpload)
+}
+
+func (receiver *diskRPCReceiver) RevertCompleteUpload(args *CompleteUploadRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.completeUploadCall(args, (*Disk).RevertCompleteUpload)
+}
+
+// CheckRange returns error if given range of data can not be read by Get().
+func (receiver *diskRPCReceiver) CheckRange(args *DataRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.dataCall(args, func(disk *Disk, dataID DataID) error {
+		rc, err := disk.Get(dataID, args.Offset, args.Length)
+		if err != nil {
+			return err
+		}
+
+		return rc.Close()
+	})
+}
+
+func (receiver *diskRPCReceiver) Get(args *DataRPCArgs, reader io.Reader, reply *locksys.VoidReply) (rc io.ReadCloser, err error) {
+	err = receiver.dataCall(args, func(disk *Disk, dataID DataID) (err error) {
+		rc, err = disk.Get(dataID, args.Offset, args.Length)
+		return err
+	})
+
+	return rc, err
+}
+
+func (receiver *diskRPCReceiver) GetMetadata(args *DataRPCArgs, reply *DataInfo) error {
+	return receiver.dataCall(args, func(disk *Disk, dataID DataID) error {
+		dataInfo, err := disk.GetMetadata(dataID)
+		if err != nil {
+			return err
+		}
+
+		*reply = *dataInfo
+		return nil
+	})
+}
+
+func (receiver *diskRPCReceiver) Delete(args *DataRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.dataCall(args, (*Disk).Delete)
+}
+
+func (receiver *diskRPCReceiver) RevertDelete(args *DataRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.dataCall(args, (*Disk).RevertDelete)
+}
+
+type CopyRPCArgs struct {
+	DataRPCArgs
+	SrcID string
+}
+
+func (receiver *diskRPCReceiver) Copy(args *CopyRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.dataCall(&args.DataRPCArgs, func(disk *Disk, dataID DataID) error {
+		srcID, err := dataspace.ParseDataID(args.SrcID)
+		if err != nil {
+			return err
+		}
+
+		return disk.Copy(dataID, srcID, args.Offset, args.Length)
+	})
+}
+
+type HealRPCArgs struct {
+	DataRPCArgs
+	DataInfo  DataInfo
+	TempFiles map[string]string
+}
+
+func (receiver *diskRPCReceiver) Heal(args *HealRPCArgs, reply *locksys.VoidReply) error {
+	return receiver.dataCall(&args.DataRPCArgs, func(disk *Disk, dataID DataID) error {
+		if err := checkPartNames(args.DataInfo.Parts); err != nil {
+			return err
+		}
+
+		for partID, tempFile := range args.TempFiles {
+			if err := checkNames(partID, tempFile); err != nil {
+				return err
+			}
+		}
+
+		return disk.Heal(dataID, &args.DataInfo, args.TempFiles)
+	})
+}
+
+// NewDiskRPCServer returns RPC server serving given disks to RemoteDisk clients, e.g. to be mounted on HTTP
+// server of the node having the disks.
+func NewDiskRPCServer(disks []*Disk) *xrpc.Server {
+	receiver := &diskRPCReceiver{disks: make(map[string]*Disk)}
+	for _, disk := range disks {
+		receiver.disks[disk.ID()] = disk
+	}
+
+	rpcServer := xrpc.NewServer()
+	if err := rpcServer.RegisterName(diskServiceName, receiver); err != nil {
+		panic(err)
+	}
+
+	return rpcServer
+}
@@ -0,0 +1,67 @@
+package disk
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+
+	"github.com/balamurugana/goat/datasys/dataspace"
+	"github.com/balamurugana/goat/datasys/scrub"
+	xos "github.com/balamurugana/goat/pkg/os"
+)
+
+// verifyData reads all part files of data of given ID through throttle so that every block is verified.
+// Data deleted in the middle is not reported as corrupt.
+func (disk *Disk) verifyData(name string, throttle func(io.Reader) io.Reader) (err error) {
+	dataDir := path.Join(disk.dataDir, getIndex(name), name)
+	defer func() {
+		if err != nil && !xos.Exist(dataDir) {
+			err = nil
+		}
+	}()
+
+	data, err := ioutil.ReadFile(path.Join(dataDir, "data.json"))
+	if err != nil {
+		return err
+	}
+
+	var dataInfo DataInfo
+	if err = json.Unmarshal(data, &dataInfo); err != nil {
+		return err
+	}
+
+	for _, part := range dataInfo.Parts {
+		rc, err := xos.OpenFile(path.Join(dataDir, part.ID+".part"), 0, part.Size, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(ioutil.Discard, throttle(rc))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewScrubber returns scrubber verifying every block of all data of this disk at most bytesPerSec bytes per
+// second. If onCorrupt is not nil, it is called with ID of each data failed verification and its error is
+// recorded by the scrubber. Progress is checkpointed in scrub.json of the disk.
+func (disk *Disk) NewScrubber(bytesPerSec uint64, onCorrupt func(dataID DataID, err error) error) *scrub.Scrubber {
+	var corruptFunc func(name string, err error) error
+	if onCorrupt != nil {
+		corruptFunc = func(name string, err error) error {
+			dataID, perr := dataspace.ParseDataID(name)
+			if perr != nil {
+				return perr
+			}
+
+			return onCorrupt(dataID, err)
+		}
+	}
+
+	return scrub.NewScrubber(disk.dataDir, path.Join(disk.storeDir, "scrub.json"), bytesPerSec, disk.verifyData, corruptFunc)
+}
@@ -4,14 +4,36 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
 	"github.com/balamurugana/goat/pkg/boundary"
 	"github.com/balamurugana/goat/pkg/erasure"
 )
 
+// GetShardReader function type returns reader of shard data on shard disk of shardID with limits of offset and length.
+type GetShardReader func(shardID string, offset, length int64) (disk.DataReader, error)
+
+// DataReader is reader returned by Get() and GetWithInfo(). ShardErrors() returns shard disks failed while
+// reading or closing, so that a degraded read can be logged, counted or queued for healing.
+type DataReader interface {
+	disk.DataReader
+	ShardErrors() []erasure.ShardError
+}
+
+// getShardPartSize returns size of part stored in each shard disk.
+func getShardPartSize(part Part) uint64 {
+	blockCount, _, _, lastShardSize := part.Compute()
+	return lastShardSize + (blockCount-1)*part.ShardSize
+}
+
 type dataReader struct {
-	getShardReader func(shardID string, offset, length int64) (io.ReadCloser, error)
+	getShardReader GetShardReader
+	dataInfo       *DataInfo
+	offset         int64
+	length         int64
+	pos            int64
 	shardPartsSize uint64
 	parts          []Part
 	index          int
@@ -20,12 +42,116 @@ type dataReader struct {
 
 	rcsMap      map[string]io.ReadCloser
 	rcsMapMutex sync.Mutex
-	reader      io.Reader
+	reader      erasure.Reader
 	shards      [][]byte
+	readAhead   int // Blocks prefetched by reader if positive.
+
+	// Shard readers and part decoders used by ReadAt() are kept until Close().
+	shardReaders      map[string]disk.DataReader
+	partReaders       map[int]erasure.ReaderAt
+	shardReadersMutex sync.Mutex
+
+	// First error of each shard failed in part decoders already released.
+	shardErrs      map[string]error
+	shardErrsMutex sync.Mutex
 
 	err error
 }
 
+// setPos sets parts to be read by Read() from pos of the range.
+func (dr *dataReader) setPos(pos int64) {
+	dr.pos = pos
+	dr.index = 0
+	dr.err = nil
+	if pos >= dr.length {
+		dr.parts = nil
+		dr.err = io.EOF
+		return
+	}
+
+	partSizes := make([]int64, len(dr.dataInfo.Parts))
+	for i, part := range dr.dataInfo.Parts {
+		partSizes[i] = int64(part.Size)
+	}
+	startPart, endPart, bytesToSkip, bytesToRead := boundary.CalcPartBoundaries(partSizes, dr.offset+pos, dr.length-pos)
+
+	dr.shardPartsSize = 0
+	for i := int64(0); i < startPart; i++ {
+		dr.shardPartsSize += getShardPartSize(dr.dataInfo.Parts[i])
+	}
+
+	dr.parts = dr.dataInfo.Parts[startPart:endPart]
+	dr.bytesToSkip = bytesToSkip
+	dr.bytesToRead = bytesToRead
+}
+
+// saveShardErrors records shard errors of a part decoder being released.
+func (dr *dataReader) saveShardErrors(shardErrs []erasure.ShardError) {
+	dr.shardErrsMutex.Lock()
+	defer dr.shardErrsMutex.Unlock()
+
+	for _, shardErr := range shardErrs {
+		if _, found := dr.shardErrs[shardErr.ShardID]; !found {
+			dr.shardErrs[shardErr.ShardID] = shardErr.Err
+		}
+	}
+}
+
+// ShardErrors returns shards failed while reading or closing, sorted by shard ID.
+func (dr *dataReader) ShardErrors() []erasure.ShardError {
+	if dr.reader != nil {
+		dr.saveShardErrors(dr.reader.ShardErrors())
+	}
+
+	dr.shardReadersMutex.Lock()
+	for _, readerAt := range dr.partReaders {
+		dr.saveShardErrors(readerAt.ShardErrors())
+	}
+	dr.shardReadersMutex.Unlock()
+
+	dr.shardErrsMutex.Lock()
+	defer dr.shardErrsMutex.Unlock()
+
+	shardErrs := make([]erasure.ShardError, 0, len(dr.shardErrs))
+	for shardID, err := range dr.shardErrs {
+		shardErrs = append(shardErrs, erasure.ShardError{ShardID: shardID, Err: err})
+	}
+
+	sort.Slice(shardErrs, func(i, j int) bool {
+		return shardErrs[i].ShardID < shardErrs[j].ShardID
+	})
+
+	return shardErrs
+}
+
+// onceReadCloser closes shard reader once and returns the same error on later Close(), as shard readers of
+// prefetching decoder are closed by the decoder to stop it and again by closeShardReaders().
+type onceReadCloser struct {
+	io.ReadCloser
+	once sync.Once
+	err  error
+}
+
+func (rc *onceReadCloser) Close() error {
+	rc.once.Do(func() {
+		rc.err = rc.ReadCloser.Close()
+	})
+
+	return rc.err
+}
+
+// releaseReader releases part decoder used by Read() after saving its shard errors. Prefetching decoder closes
+// its shard readers to stop promptly, then they are closed by closeShardReaders() to collect close errors.
+func (dr *dataReader) releaseReader() error {
+	if closer, ok := dr.reader.(io.Closer); ok {
+		closer.Close()
+	}
+
+	dr.saveShardErrors(dr.reader.ShardErrors())
+	dr.reader = nil
+	return dr.closeShardReaders()
+}
+
 func (dr *dataReader) closeShardReaders() error {
 	errs := make([]error, len(dr.rcsMap))
 	var i int
@@ -38,6 +164,7 @@ func (dr *dataReader) closeShardReaders() error {
 			go func(i int, id string, rc io.ReadCloser) {
 				defer wg.Done()
 				if errs[i] = rc.Close(); errs[i] != nil {
+					dr.saveShardErrors([]erasure.ShardError{{ShardID: id, Err: errs[i]}})
 					errs[i] = fmt.Errorf("%v: %v", id, errs[i])
 				}
 			}(i, id, rc)
@@ -62,24 +189,31 @@ func (dr *dataReader) closeShardReaders() error {
 	return nil
 }
 
-func (dr *dataReader) getReader(offset int64, length uint64) (io.Reader, error) {
+func (dr *dataReader) getReader(offset int64, length uint64) (erasure.Reader, error) {
 	dr.closeShardReaders()
 
 	shardPartsSize := dr.shardPartsSize
 	getShardReader := func(shardID string, offset, length int64) (io.Reader, error) {
 		rc, err := dr.getShardReader(shardID, int64(shardPartsSize)+offset, length)
-		if err == nil {
-			dr.rcsMapMutex.Lock()
-			dr.rcsMap[shardID] = rc
-			dr.rcsMapMutex.Unlock()
+		if err != nil {
+			return nil, err
 		}
-		return rc, err
+
+		orc := &onceReadCloser{ReadCloser: rc}
+		dr.rcsMapMutex.Lock()
+		dr.rcsMap[shardID] = orc
+		dr.rcsMapMutex.Unlock()
+		return orc, nil
 	}
 
 	for i := range dr.shards {
 		dr.shards[i] = dr.shards[i][:cap(dr.shards[i])]
 	}
 
+	if dr.readAhead > 0 {
+		return erasure.NewPrefetchReader(getShardReader, dr.shards, &dr.parts[dr.index].Info, offset, length, dr.readAhead)
+	}
+
 	return erasure.NewReader(getShardReader, dr.shards, &dr.parts[dr.index].Info, offset, length)
 }
 
@@ -114,17 +248,17 @@ func (dr *dataReader) Read(b []byte) (int, error) {
 			return 0, dr.err
 		}
 
-		blockCount, _, _, lastShardSize := dr.parts[dr.index].Compute()
-		dr.shardPartsSize += lastShardSize + (blockCount-1)*dr.parts[dr.index].ShardSize
+		dr.shardPartsSize += getShardPartSize(dr.parts[dr.index])
 
 		dr.index++
 	}
 
 	var n int
 
-	if n, dr.err = dr.reader.Read(b); dr.err != nil {
-		dr.closeShardReaders()
-		dr.reader = nil
+	n, dr.err = dr.reader.Read(b)
+	dr.pos += int64(n)
+	if dr.err != nil {
+		dr.releaseReader()
 
 		if errors.Is(dr.err, io.ErrUnexpectedEOF) || errors.Is(dr.err, io.EOF) {
 			if dr.index != len(dr.parts) {
@@ -136,17 +270,172 @@ func (dr *dataReader) Read(b []byte) (int, error) {
 	return n, dr.err
 }
 
-func (dr *dataReader) Close() error {
+// Seek sets position of next Read(). Shard readers being read are closed.
+func (dr *dataReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += dr.pos
+	case io.SeekEnd:
+		offset += dr.length
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
 	if dr.reader != nil {
-		err := dr.closeShardReaders()
-		dr.reader = nil
-		return err
+		dr.releaseReader()
 	}
 
-	return nil
+	dr.setPos(offset)
+	return offset, nil
+}
+
+func (dr *dataReader) getShardReaderAt(shardID string) (io.ReaderAt, error) {
+	dr.shardReadersMutex.Lock()
+	defer dr.shardReadersMutex.Unlock()
+
+	if sr, found := dr.shardReaders[shardID]; found {
+		return sr, nil
+	}
+
+	size := uint64(0)
+	for _, part := range dr.dataInfo.Parts {
+		size += getShardPartSize(part)
+	}
+
+	sr, err := dr.getShardReader(shardID, 0, int64(size))
+	if err != nil {
+		return nil, err
+	}
+
+	dr.shardReaders[shardID] = sr
+	return sr, nil
+}
+
+// getPartReaderAt returns erasure decoder of given part index of data.
+func (dr *dataReader) getPartReaderAt(index int) (erasure.ReaderAt, error) {
+	dr.shardReadersMutex.Lock()
+	defer dr.shardReadersMutex.Unlock()
+
+	if readerAt, found := dr.partReaders[index]; found {
+		return readerAt, nil
+	}
+
+	shardPartsSize := uint64(0)
+	for i := 0; i < index; i++ {
+		shardPartsSize += getShardPartSize(dr.dataInfo.Parts[i])
+	}
+	shardPartSize := getShardPartSize(dr.dataInfo.Parts[index])
+
+	getShardReaderAt := func(shardID string) (io.ReaderAt, error) {
+		sr, err := dr.getShardReaderAt(shardID)
+		if err != nil {
+			return nil, err
+		}
+
+		return io.NewSectionReader(sr, int64(shardPartsSize), int64(shardPartSize)), nil
+	}
+
+	readerAt, err := erasure.NewReaderAt(getShardReaderAt, &dr.dataInfo.Parts[index].Info)
+	if err != nil {
+		return nil, err
+	}
+
+	dr.partReaders[index] = readerAt
+	return readerAt, nil
 }
 
-func newDataReader(getShardReader func(shardID string, offset, length int64) (io.ReadCloser, error), dataInfo *DataInfo, offset int64, length uint64) (*dataReader, error) {
+// ReadAt reads len(b) bytes from offset off of the range. Only erasure blocks covering requested bytes are
+// read and decoded.
+func (dr *dataReader) ReadAt(b []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if off >= dr.length {
+		return 0, io.EOF
+	}
+
+	truncated := false
+	if remaining := dr.length - off; int64(len(b)) > remaining {
+		b = b[:remaining]
+		truncated = true
+	}
+
+	partSizes := make([]int64, len(dr.dataInfo.Parts))
+	for i, part := range dr.dataInfo.Parts {
+		partSizes[i] = int64(part.Size)
+	}
+	startPart, endPart, bytesToSkip, _ := boundary.CalcPartBoundaries(partSizes, dr.offset+off, int64(len(b)))
+
+	for i := startPart; i < endPart; i++ {
+		offset := int64(0)
+		if i == startPart {
+			offset = bytesToSkip
+		}
+
+		length := partSizes[i] - offset
+		if length > int64(len(b)-n) {
+			length = int64(len(b) - n)
+		}
+
+		var readerAt erasure.ReaderAt
+		if readerAt, err = dr.getPartReaderAt(int(i)); err != nil {
+			return n, err
+		}
+
+		var m int
+		m, err = readerAt.ReadAt(b[n:int64(n)+length], offset)
+		n += m
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return n, err
+		}
+	}
+
+	if n < len(b) {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	if truncated {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (dr *dataReader) Close() (err error) {
+	if dr.reader != nil {
+		err = dr.releaseReader()
+	}
+
+	dr.shardReadersMutex.Lock()
+	for shardID, sr := range dr.shardReaders {
+		if cerr := sr.Close(); cerr != nil {
+			dr.saveShardErrors([]erasure.ShardError{{ShardID: shardID, Err: cerr}})
+			if err == nil {
+				err = fmt.Errorf("%v: %v", shardID, cerr)
+			}
+		}
+		delete(dr.shardReaders, shardID)
+	}
+	for _, readerAt := range dr.partReaders {
+		dr.saveShardErrors(readerAt.ShardErrors())
+	}
+	dr.partReaders = make(map[int]erasure.ReaderAt)
+	dr.shardReadersMutex.Unlock()
+
+	return err
+}
+
+func newDataReader(getShardReader GetShardReader, dataInfo *DataInfo, offset int64, length uint64) (*dataReader, error) {
 	dataLength := int64(length)
 	size := int64(dataInfo.Size)
 
@@ -162,24 +451,22 @@ func newDataReader(getShardReader func(shardID string, offset, length int64) (io
 		return nil, errors.New("insufficient data")
 	}
 
-	partSizes := make([]int64, len(dataInfo.Parts))
-	for i, part := range dataInfo.Parts {
-		partSizes[i] = int64(part.Size)
+	// Part infos may be given by caller instead of GetMetadataWithInfo(), hence they are validated again.
+	if err := validateParts(dataInfo.Parts); err != nil {
+		return nil, err
 	}
-	startPart, endPart, bytesToSkip, bytesToRead := boundary.CalcPartBoundaries(partSizes, offset, dataLength)
 
-	shardPartsSize := uint64(0)
-	for i := int64(0); i < startPart; i++ {
-		blockCount, _, _, lastShardSize := dataInfo.Parts[i].Compute()
-		shardPartsSize += lastShardSize + (blockCount-1)*dataInfo.Parts[i].ShardSize
-	}
-
-	return &dataReader{
+	dr := &dataReader{
 		getShardReader: getShardReader,
-		shardPartsSize: shardPartsSize,
-		parts:          dataInfo.Parts[startPart:endPart],
-		bytesToSkip:    bytesToSkip,
-		bytesToRead:    bytesToRead,
+		dataInfo:       dataInfo,
+		offset:         offset,
+		length:         dataLength,
 		rcsMap:         make(map[string]io.ReadCloser),
-	}, nil
+		shardReaders:   make(map[string]disk.DataReader),
+		partReaders:    make(map[int]erasure.ReaderAt),
+		shardErrs:      make(map[string]error),
+	}
+	dr.setPos(0)
+
+	return dr, nil
 }
@@ -1,12 +1,18 @@
 package erasure
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sync"
 
+	"github.com/balamurugana/goat/datasys/dataspace"
 	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	xerrors "github.com/balamurugana/goat/datasys/errors"
 	"github.com/balamurugana/goat/pkg/boundary"
 	"github.com/balamurugana/goat/pkg/erasure"
 )
@@ -32,30 +38,229 @@ func (dataInfo DataInfo) getParts(offset, length int64) (requiredParts []Part, b
 	return dataInfo.Parts[startPart:endPart], bytesToSkip, bytesToRead
 }
 
+// Disagreement is a shard disk whose answer differs from the answer agreed by quorum of shard disks.
+type Disagreement struct {
+	ShardID string
+	Err     error // Error returned by the shard disk, or xerrors.ErrShardMismatch if it answered differently.
+}
+
+// validateParts returns *erasure.InfoError if erasure info of any part is invalid, e.g. read from corrupt
+// metadata, so that its layout is not computed.
+func validateParts(parts []Part) error {
+	for i := range parts {
+		if err := parts[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newParts returns parts of shard disk parts whose Meta holds erasure info saved by UploadPart().
+func newParts(diskParts []disk.Part) ([]Part, error) {
+	parts := make([]Part, len(diskParts))
+	for i := range diskParts {
+		if diskParts[i].Meta == nil {
+			return nil, fmt.Errorf("erasure info of part %v not found", diskParts[i].ID)
+		}
+
+		parts[i].ID = diskParts[i].ID
+		if err := json.Unmarshal(diskParts[i].Meta, &parts[i].Info); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateParts(parts); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+// newDiskParts returns parts stored in each shard disk with erasure info of each part in its Meta.
+func newDiskParts(parts []Part) ([]disk.Part, error) {
+	if err := validateParts(parts); err != nil {
+		return nil, err
+	}
+
+	diskParts := make([]disk.Part, len(parts))
+	for i := range parts {
+		meta, err := json.Marshal(&parts[i].Info)
+		if err != nil {
+			return nil, err
+		}
+
+		diskParts[i] = disk.Part{ID: parts[i].ID, Size: getShardPartSize(parts[i]), Meta: meta}
+	}
+
+	return diskParts, nil
+}
+
+// newDataSpaceParts returns parts with erasure info of each part in its Meta.
+func newDataSpaceParts(parts []Part) ([]dataspace.Part, error) {
+	dsParts := make([]dataspace.Part, len(parts))
+	for i := range parts {
+		meta, err := json.Marshal(&parts[i].Info)
+		if err != nil {
+			return nil, err
+		}
+
+		dsParts[i] = dataspace.Part{ID: parts[i].ID, Size: parts[i].Size, Meta: meta}
+	}
+
+	return dsParts, nil
+}
+
+// defaultShardSize is shard size of erasure layout used by DataSpace methods unless set by SetLayout().
+const defaultShardSize = 1024 * 1024
+
+// ShardDisk is disk storing one shard of each data of an erasure set. It is satisfied by local *disk.Disk and by
+// *disk.RemoteDisk whose disk is served by disk.NewDiskRPCServer() on another node.
+type ShardDisk interface {
+	ID() string
+	SetPosition(deploymentID string, setIndex, diskIndex int) error
+
+	SaveTempFile(filename string, data io.Reader, size uint64, bitrotProtection bool) (checksum string, err error)
+	GetTempFile(filename string, offset int64, length uint64, bitrotProtection bool) (io.ReadCloser, error)
+	RemoveTempFile(filename string, bitrotProtection bool) error
+
+	InitUpload(uploadID disk.UploadID) error
+	RevertInitUpload(uploadID disk.UploadID) error
+	UploadPartWithMeta(uploadID disk.UploadID, partID, tempFile string, meta json.RawMessage) error
+	RevertUploadPart(uploadID disk.UploadID, partID, tempFile string) error
+	AbortUpload(uploadID disk.UploadID) error
+	RevertAbortUpload(uploadID disk.UploadID) error
+	CompleteUpload(dataID disk.DataID, uploadID disk.UploadID, parts []disk.Part) error
+	RevertCompleteUpload(dataID disk.DataID, uploadID disk.UploadID, parts []disk.Part) error
+	ListParts(uploadID disk.UploadID) ([]disk.Part, error)
+
+	Get(dataID disk.DataID, offset int64, length uint64) (disk.DataReader, error)
+	GetMetadata(dataID disk.DataID) (*disk.DataInfo, error)
+	Delete(dataID disk.DataID) error
+	RevertDelete(dataID disk.DataID) error
+	Copy(dataID, srcID disk.DataID, offset int64, length uint64) error
+	Heal(dataID disk.DataID, dataInfo *disk.DataInfo, tempFiles map[string]string) error
+}
+
+var (
+	_ ShardDisk = (*disk.Disk)(nil)
+	_ ShardDisk = (*disk.RemoteDisk)(nil)
+)
+
 type Erasure struct {
-	shardDisks []*disk.Disk
+	shardDisks []ShardDisk
 	minSuccess uint64
+
+	dataCount   uint64
+	parityCount uint64
+	shardSize   uint64
+	placement   Placement
+	readAhead   int
+	pipeline    *erasure.PipelineOptions
+
+	// Erasure profiles by storage class name used by SaveTempFileWithClass().
+	storageClasses map[string]StorageClass
 }
 
-func NewErasure(shardDisks []*disk.Disk, minSuccess uint64) *Erasure {
+// NewErasure returns erasure dataspace on given shard disks. Its erasure layout has half of shard disks,
+// rounded down, as parity and is changed by SetLayout(). Shards are placed by HashPlacement unless changed by
+// SetPlacement().
+func NewErasure(shardDisks []ShardDisk, minSuccess uint64) *Erasure {
+	parityCount := uint64(len(shardDisks) / 2)
 	return &Erasure{
-		shardDisks: shardDisks,
-		minSuccess: minSuccess,
+		shardDisks:  shardDisks,
+		minSuccess:  minSuccess,
+		dataCount:   uint64(len(shardDisks)) - parityCount,
+		parityCount: parityCount,
+		shardSize:   defaultShardSize,
+		placement:   HashPlacement,
 	}
 }
 
-func (ds *Erasure) SaveTempFile(filename string, data io.Reader, bitrotProtection bool, info *erasure.Info) (checksum string, err error) {
+// SetLayout sets erasure layout used by SaveTempFile(), UploadPartCopy() and Copy(). Methods with info
+// argument use given info instead.
+func (ds *Erasure) SetLayout(dataCount, parityCount, shardSize uint64) {
+	ds.dataCount = dataCount
+	ds.parityCount = parityCount
+	ds.shardSize = shardSize
+}
+
+// SetPlacement sets placement of shards onto shard disks for data saved afterwards. Data already saved is read
+// by its stored info.ShardIDs.
+func (ds *Erasure) SetPlacement(placement Placement) {
+	ds.placement = placement
+}
+
+// SetReadAhead sets number of erasure blocks decoded in background ahead of sequential Read() of readers
+// returned afterwards by Get() and GetWithInfo(). Zero, the default, decodes a block only when it is read.
+func (ds *Erasure) SetReadAhead(blocks int) {
+	ds.readAhead = blocks
+}
+
+// SetPipeline sets data saved afterwards by SaveTempFile() and its variants to be encoded by
+// erasure.WritePipelined() with given options. Nil, the default, encodes by erasure.Write() one block at a time.
+func (ds *Erasure) SetPipeline(opts *erasure.PipelineOptions) {
+	ds.pipeline = opts
+}
+
+// newInfo returns erasure info of configured layout for data of given size.
+func (ds *Erasure) newInfo(size uint64) *erasure.Info {
+	return &erasure.Info{
+		DataCount:   ds.dataCount,
+		ParityCount: ds.parityCount,
+		Size:        size,
+		ShardSize:   ds.shardSize,
+	}
+}
+
+// rangeInfo returns erasure info for given range of data copied as single part. Data and parity count are of
+// source part at offset, e.g. of its storage class, if they match shard disks; else of configured layout.
+func (ds *Erasure) rangeInfo(srcDataInfo *DataInfo, offset int64, length uint64) *erasure.Info {
+	info := ds.newInfo(length)
+	for _, part := range srcDataInfo.Parts {
+		if offset < int64(part.Size) {
+			if part.DataCount+part.ParityCount == uint64(len(ds.shardDisks)) {
+				info.DataCount = part.DataCount
+				info.ParityCount = part.ParityCount
+			}
+			break
+		}
+
+		offset -= int64(part.Size)
+	}
+
+	return info
+}
+
+// SaveTempFile erasure encodes data of given size by configured layout into temporary file in each shard disk.
+func (ds *Erasure) SaveTempFile(filename string, data io.Reader, size uint64, bitrotProtection bool) (checksum string, err error) {
+	return ds.SaveTempFileWithInfo(filename, data, bitrotProtection, ds.newInfo(size))
+}
+
+// SaveTempFileWithInfo erasure encodes data by given info into temporary file in each shard disk. info.ShardIDs
+// must be an order of all shard disks e.g. by UploadShardIDs() of upload the temporary file is uploaded to; if it
+// is empty, it is populated by placement keyed by filename, i.e. each temporary file is placed on its own as its
+// upload is not known. If info.Size is erasure.UnknownSize, data is encoded
+// until EOF and info.Size is set to its size. info is saved next to the shards for UploadPart().
+func (ds *Erasure) SaveTempFileWithInfo(filename string, data io.Reader, bitrotProtection bool, info *erasure.Info) (checksum string, err error) {
 	count := info.DataCount + info.ParityCount
 	if count != uint64(len(ds.shardDisks)) {
 		return "", errors.New("info.DataCount+info.ParityCount != len(Erasure.shardDisks)")
 	}
 
+	if len(info.ShardIDs) == 0 {
+		info.ShardIDs = ds.placeShards(filename)
+	} else if err = ds.checkPlacement(info.ShardIDs); err != nil {
+		return "", err
+	}
+
+	if err = info.Validate(); err != nil {
+		return "", err
+	}
+
 	shardIDMap := make(map[string]int)
-	info.ShardIDs = make([]string, count)
 	for i := range ds.shardDisks {
-		shardID := ds.shardDisks[i].ID()
-		info.ShardIDs[i] = shardID
-		shardIDMap[shardID] = i
+		shardIDMap[ds.shardDisks[i].ID()] = i
 	}
 
 	pipeReaders := make([]*io.PipeReader, count)
@@ -74,8 +279,11 @@ func (ds *Erasure) SaveTempFile(filename string, data io.Reader, bitrotProtectio
 		return writers[i], nil
 	}
 
-	blockCount, _, _, lastShardSize := info.Compute()
-	shardFileSize := lastShardSize + (blockCount-1)*info.ShardSize
+	shardFileSize := uint64(erasure.UnknownSize)
+	if info.Size != erasure.UnknownSize {
+		blockCount, _, _, lastShardSize := info.Compute()
+		shardFileSize = lastShardSize + (blockCount-1)*info.ShardSize
+	}
 
 	checksums := make([]string, count)
 	errs := make([]error, count)
@@ -88,12 +296,18 @@ func (ds *Erasure) SaveTempFile(filename string, data io.Reader, bitrotProtectio
 		}(i)
 	}
 
-	shards := make([][]byte, count)
-	for i := range shards {
-		shards[i] = make([]byte, info.ShardSize)
-	}
+	var shardSums []string
+	var dataSum string
+	if ds.pipeline != nil {
+		shardSums, dataSum, err = erasure.WritePipelined(getShardWriter, info, data, ds.minSuccess, *ds.pipeline)
+	} else {
+		shards := make([][]byte, count)
+		for i := range shards {
+			shards[i] = make([]byte, info.ShardSize)
+		}
 
-	shardSums, dataSum, err := erasure.Write(getShardWriter, shards, info, data, ds.minSuccess)
+		shardSums, dataSum, err = erasure.Write(getShardWriter, shards, info, data, ds.minSuccess)
+	}
 
 	for i := range pipeWriters {
 		pipeWriters[i].Close()
@@ -105,8 +319,9 @@ func (ds *Erasure) SaveTempFile(filename string, data io.Reader, bitrotProtectio
 	}
 
 	successCount := uint64(len(shardSums))
-	for i := range shardSums {
-		if shardSums[i] == "" || shardSums[i] != checksums[i] {
+	for k := range shardSums {
+		i := shardIDMap[info.ShardIDs[k]]
+		if shardSums[k] == "" || shardSums[k] != checksums[i] {
 			ds.shardDisks[i].RemoveTempFile(filename, true)
 			successCount--
 		}
@@ -116,10 +331,176 @@ func (ds *Erasure) SaveTempFile(filename string, data io.Reader, bitrotProtectio
 		return "", fmt.Errorf("multiple checksum mismatch error; %v != %v", shardSums, checksums)
 	}
 
-	return dataSum, err
+	if err = ds.saveTempInfo(filename, info); err != nil {
+		ds.RemoveTempFile(filename, true)
+		return "", err
+	}
+
+	return dataSum, nil
+}
+
+// maxTempInfoSize is maximum length of JSON encoded erasure info of a temporary file.
+const maxTempInfoSize = 1024 * 1024
+
+// tempInfoFilename returns name of temporary file holding erasure info of given temporary file.
+func tempInfoFilename(filename string) string {
+	return filename + ".info"
+}
+
+// saveTempInfo saves erasure info of given temporary file into each shard disk next to its shard, hence it
+// survives restart of this node and is cleaned up along with the shard. Saved file is length of JSON encoded
+// info as 8 byte big endian followed by the JSON, as reading a temporary file requires its length.
+func (ds *Erasure) saveTempInfo(filename string, info *erasure.Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	data = append(binary.BigEndian.AppendUint64(nil, uint64(len(data))), data...)
+
+	errs := make([]error, len(ds.shardDisks))
+	var wg sync.WaitGroup
+	for i := range ds.shardDisks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = ds.shardDisks[i].SaveTempFile(tempInfoFilename(filename), bytes.NewReader(data), uint64(len(data)), true)
+		}(i)
+	}
+	wg.Wait()
+
+	successCount := uint64(0)
+	for i := range errs {
+		if errs[i] == nil {
+			successCount++
+		}
+	}
+
+	if successCount < ds.minSuccess {
+		ds.removeTempInfo(filename)
+		return fmt.Errorf("too many errors; %v", errs)
+	}
+
+	return nil
+}
+
+// readTempInfo returns erasure info of given temporary file as agreed by read quorum of shard disks.
+func (ds *Erasure) readTempInfo(filename string) (*erasure.Info, error) {
+	readFile := func(i int, offset int64, length uint64) ([]byte, error) {
+		rc, err := ds.shardDisks[i].GetTempFile(tempInfoFilename(filename), offset, length, true)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		data := make([]byte, length)
+		_, err = io.ReadFull(rc, data)
+		return data, err
+	}
+
+	infos := make([]*erasure.Info, len(ds.shardDisks))
+	index, _, err := ds.getQuorum(func(i int) (interface{}, error) {
+		data, err := readFile(i, 0, 8)
+		if err != nil {
+			return nil, err
+		}
+
+		length := binary.BigEndian.Uint64(data)
+		if length > maxTempInfoSize {
+			return nil, fmt.Errorf("erasure info length %v exceeds %v", length, maxTempInfoSize)
+		}
+
+		if data, err = readFile(i, 8, length); err != nil {
+			return nil, err
+		}
+
+		infos[i] = &erasure.Info{}
+		return infos[i], json.Unmarshal(data, infos[i])
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erasure info of temporary file %v not found; %v", filename, err)
+	}
+
+	if err = infos[index].Validate(); err != nil {
+		return nil, err
+	}
+
+	return infos[index], nil
+}
+
+// removeTempInfo removes erasure info of given temporary file from shard disks. Errors are ignored as leftover is
+// cleaned up along with other temporary files by disk recovery.
+func (ds *Erasure) removeTempInfo(filename string) {
+	var wg sync.WaitGroup
+	for i := range ds.shardDisks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ds.shardDisks[i].RemoveTempFile(tempInfoFilename(filename), true)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// getTempFile returns decoded reader of temporary file saved by SaveTempFile(), its erasure info and function to
+// close shard readers.
+func (ds *Erasure) getTempFile(filename string) (io.Reader, *erasure.Info, func(), error) {
+	info, err := ds.readTempInfo(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	shardIDMap := make(map[string]int)
+	for i := range ds.shardDisks {
+		shardIDMap[ds.shardDisks[i].ID()] = i
+	}
+
+	var closers []io.Closer
+	var mutex sync.Mutex
+	closeShardReaders := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, closer := range closers {
+			closer.Close()
+		}
+		closers = nil
+	}
+
+	getShardReader := func(shardID string, offset, length int64) (io.Reader, error) {
+		mutex.Lock()
+		i, found := shardIDMap[shardID]
+		mutex.Unlock()
+		if !found {
+			return nil, fmt.Errorf("shard disk of shard ID %v not found", shardID)
+		}
+
+		rc, err := ds.shardDisks[i].GetTempFile(filename, offset, uint64(length), true)
+		if err != nil {
+			return nil, err
+		}
+
+		mutex.Lock()
+		closers = append(closers, rc)
+		mutex.Unlock()
+		return rc, nil
+	}
+
+	shards := make([][]byte, info.DataCount+info.ParityCount)
+	for i := range shards {
+		shards[i] = make([]byte, info.ShardSize)
+	}
+
+	reader, err := erasure.NewReader(getShardReader, shards, info, 0, info.Size)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return reader, info, closeShardReaders, nil
 }
 
 func (ds *Erasure) RemoveTempFile(filename string, bitrotProtection bool) (err error) {
+	ds.removeTempInfo(filename)
+
 	errs := make([]error, len(ds.shardDisks))
 	var wg sync.WaitGroup
 	for i := range ds.shardDisks {
@@ -183,14 +564,36 @@ func (ds *Erasure) InitUpload(uploadID disk.UploadID) (err error) {
 	return fmt.Errorf("too many errors; %v", errs)
 }
 
+// UploadPart moves temporary file saved by SaveTempFile() as part of upload. Erasure info saved along with the
+// temporary file is read from shard disks.
 func (ds *Erasure) UploadPart(uploadID disk.UploadID, partID, tempFile string) (err error) {
+	info, err := ds.readTempInfo(tempFile)
+	if err != nil {
+		return err
+	}
+
+	return ds.UploadPartWithInfo(uploadID, partID, tempFile, info)
+}
+
+// UploadPartWithInfo moves temporary file saved by SaveTempFileWithInfo() as part of upload. Given info, as
+// populated by SaveTempFileWithInfo(), is saved along with the part in each shard disk for ListParts().
+func (ds *Erasure) UploadPartWithInfo(uploadID disk.UploadID, partID, tempFile string, info *erasure.Info) (err error) {
+	if err = info.Validate(); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
 	errs := make([]error, len(ds.shardDisks))
 	var wg sync.WaitGroup
 	for i := range ds.shardDisks {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			errs[i] = ds.shardDisks[i].UploadPart(uploadID, partID, tempFile)
+			errs[i] = ds.shardDisks[i].UploadPartWithMeta(uploadID, partID, tempFile, meta)
 		}(i)
 	}
 	wg.Wait()
@@ -203,6 +606,7 @@ func (ds *Erasure) UploadPart(uploadID disk.UploadID, partID, tempFile string) (
 	}
 
 	if successCount >= ds.minSuccess {
+		ds.removeTempInfo(tempFile)
 		return nil
 	}
 
@@ -221,9 +625,45 @@ func (ds *Erasure) UploadPart(uploadID disk.UploadID, partID, tempFile string) (
 	return fmt.Errorf("too many errors; %v", errs)
 }
 
-// func (ds *Erasure) UploadPartCopy(uploadID UploadID, partID string, srcID DataID, offset int64, length uint64) (etag string, err error) {
-// }
-//
+// UploadPartCopy copies given range of data of srcID as part of upload erasure encoded by configured layout.
+func (ds *Erasure) UploadPartCopy(uploadID disk.UploadID, partID string, srcID disk.DataID, offset int64, length uint64) (etag string, err error) {
+	srcDataInfo, _, err := ds.GetMetadataWithInfo(srcID)
+	if err != nil {
+		return "", err
+	}
+
+	return ds.UploadPartCopyWithInfo(uploadID, partID, srcID, srcDataInfo, offset, length, ds.rangeInfo(srcDataInfo, offset, length))
+}
+
+// UploadPartCopyWithInfo copies given range of data of srcID as part of upload. Data is read by GetWithInfo() so
+// that it is verified and decoded, then erasure encoded by given info like SaveTempFileWithInfo(); info.Size is
+// set to length.
+func (ds *Erasure) UploadPartCopyWithInfo(uploadID disk.UploadID, partID string, srcID disk.DataID, srcDataInfo *DataInfo, offset int64, length uint64, info *erasure.Info) (etag string, err error) {
+	rc, err := ds.GetWithInfo(srcID, srcDataInfo, offset, length)
+	if err != nil {
+		return "", err
+	}
+
+	defer rc.Close()
+
+	tempFile := disk.NewTempFilename()
+	info.Size = length
+	if len(info.ShardIDs) == 0 {
+		info.ShardIDs = ds.UploadShardIDs(uploadID)
+	}
+
+	if etag, err = ds.SaveTempFileWithInfo(tempFile, rc, true, info); err != nil {
+		ds.RemoveTempFile(tempFile, true)
+		return "", err
+	}
+
+	if err = ds.UploadPartWithInfo(uploadID, partID, tempFile, info); err != nil {
+		ds.RemoveTempFile(tempFile, true)
+		return "", err
+	}
+
+	return etag, nil
+}
 
 func (ds *Erasure) AbortUpload(uploadID disk.UploadID) (err error) {
 	errs := make([]error, len(ds.shardDisks))
@@ -263,16 +703,49 @@ func (ds *Erasure) AbortUpload(uploadID disk.UploadID) (err error) {
 	return fmt.Errorf("too many errors; %v", errs)
 }
 
-func (ds *Erasure) CompleteUpload(dataID disk.DataID, uploadID disk.UploadID, parts []Part) (*DataInfo, error) {
+// CompleteUpload makes given parts of upload available as data of dataID. Erasure info of each part is taken
+// from ListPartsWithInfo() and size of each given part must match.
+func (ds *Erasure) CompleteUpload(dataID disk.DataID, uploadID disk.UploadID, parts []dataspace.Part) error {
+	uploadedParts, _, err := ds.ListPartsWithInfo(uploadID)
+	if err != nil {
+		return err
+	}
+
+	infos := make(map[string]erasure.Info)
+	for _, part := range uploadedParts {
+		infos[part.ID] = part.Info
+	}
+
+	erasureParts := make([]Part, len(parts))
+	for i, part := range parts {
+		info, found := infos[part.ID]
+		if !found {
+			return xerrors.ErrPartNotFound
+		}
+
+		if info.Size != part.Size {
+			return fmt.Errorf("part %v: size mismatch; expected: %v, got: %v", part.ID, info.Size, part.Size)
+		}
+
+		erasureParts[i] = Part{Info: info, ID: part.ID}
+	}
+
+	_, err = ds.CompleteUploadWithInfo(dataID, uploadID, erasureParts)
+	return err
+}
+
+// CompleteUploadWithInfo makes given parts of upload available as data of dataID and returns its data info.
+func (ds *Erasure) CompleteUploadWithInfo(dataID disk.DataID, uploadID disk.UploadID, parts []Part) (*DataInfo, error) {
 	size := uint64(0)
-	diskParts := make([]disk.Part, len(parts))
 	for i := range parts {
-		diskParts[i].ID = parts[i].ID
-		blockCount, _, _, lastShardSize := parts[i].Info.Compute()
-		diskParts[i].Size = lastShardSize + (blockCount-1)*parts[i].ShardSize
 		size += parts[i].Size
 	}
 
+	diskParts, err := newDiskParts(parts)
+	if err != nil {
+		return nil, err
+	}
+
 	// NOTE: current assumption is all parts reside in same set of disks but may be in different order.
 
 	errs := make([]error, len(ds.shardDisks))
@@ -315,33 +788,403 @@ func (ds *Erasure) CompleteUpload(dataID disk.DataID, uploadID disk.UploadID, pa
 	return nil, fmt.Errorf("too many errors; %v", errs)
 }
 
-// func (ds *Erasure) ListParts(uploadID string) ([]Part, error) {
-// }
-//
+// revertCompleteUpload moves data of dataID back to upload of uploadID on each shard disk, i.e. undoes successful
+// CompleteUpload(). Shard disks not having the data are skipped.
+func (ds *Erasure) revertCompleteUpload(dataID disk.DataID, uploadID disk.UploadID) error {
+	dataInfo, _, err := ds.GetMetadataWithInfo(dataID)
+	if err != nil {
+		return err
+	}
+
+	diskParts, err := newDiskParts(dataInfo.Parts)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(ds.shardDisks))
+	var wg sync.WaitGroup
+	for i := range ds.shardDisks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if errs[i] = ds.shardDisks[i].RevertCompleteUpload(dataID, uploadID, diskParts); errors.Is(errs[i], xerrors.ErrDataIDNotFound) {
+				errs[i] = nil
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range errs {
+		if errs[i] != nil {
+			return fmt.Errorf("revert errors; %v", errs)
+		}
+	}
+
+	return nil
+}
+
+// readQuorum returns number of shard disks required to agree on an answer of read operations. A write succeeds on
+// minSuccess shard disks and may miss others, hence more than len(shardDisks)-minSuccess shard disks must agree so
+// that a stale answer never wins. If minSuccess is not a majority, it is capped by minSuccess, i.e. by shard disks
+// guaranteed to have the written answer.
+func (ds *Erasure) readQuorum() int {
+	writeQuorum := int(ds.minSuccess)
+	quorum := len(ds.shardDisks) - writeQuorum + 1
+	if quorum > writeQuorum {
+		quorum = writeQuorum
+	}
+
+	if quorum < 1 {
+		quorum = 1
+	}
+
+	return quorum
+}
+
+// quorumErrors are errors on which shard disks can agree. Other errors, e.g. I/O errors, are specific to a shard
+// disk and never agree.
+var quorumErrors = []error{
+	xerrors.ErrUploadIDNotFound,
+	xerrors.ErrPartNotFound,
+	xerrors.ErrPartChecksumNotFound,
+	xerrors.ErrDataIDNotFound,
+	xerrors.ErrInvalidName,
+	os.ErrNotExist,
+}
+
+// getQuorum calls get on each shard disk and returns index of a shard disk whose answer is agreed by read quorum
+// along with shard disks disagreed. Answers are compared by their JSON encoding, and errors by errors.Is() against
+// quorumErrors. If the quorum agrees on an error, that error is returned. An answer agreed by as many shard disks
+// as another answer has no quorum.
+func (ds *Erasure) getQuorum(get func(i int) (interface{}, error)) (index int, disagreements []Disagreement, err error) {
+	answers := make([]string, len(ds.shardDisks))
+	errs := make([]error, len(ds.shardDisks))
+	var wg sync.WaitGroup
+	for i := range ds.shardDisks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			answer, err := get(i)
+			if err == nil {
+				var data []byte
+				if data, err = json.Marshal(answer); err == nil {
+					answers[i] = string(data)
+				}
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	counts := make(map[string]int)
+	for i := range answers {
+		if errs[i] != nil {
+			answers[i] = fmt.Sprintf("error: %v", i)
+			for _, quorumErr := range quorumErrors {
+				if errors.Is(errs[i], quorumErr) {
+					answers[i] = "error: " + quorumErr.Error()
+					break
+				}
+			}
+		}
+		counts[answers[i]]++
+	}
 
-func (ds *Erasure) Get(dataID disk.DataID, dataInfo *DataInfo, offset int64, length uint64) (rc io.ReadCloser, err error) {
+	index = 0
+	for i := range answers {
+		if counts[answers[i]] > counts[answers[index]] {
+			index = i
+		}
+	}
+
+	if counts[answers[index]] < ds.readQuorum() {
+		return -1, nil, fmt.Errorf("no read quorum; %v", errs)
+	}
+
+	for answer, count := range counts {
+		if count == counts[answers[index]] && answer != answers[index] {
+			return -1, nil, fmt.Errorf("no read quorum; split answers; %v", errs)
+		}
+	}
+
+	if errs[index] != nil {
+		return -1, nil, errs[index]
+	}
+
+	for i := range answers {
+		if answers[i] != answers[index] {
+			err := errs[i]
+			if err == nil {
+				err = xerrors.ErrShardMismatch
+			}
+			disagreements = append(disagreements, Disagreement{ShardID: ds.shardDisks[i].ID(), Err: err})
+		}
+	}
+
+	return index, disagreements, nil
+}
+
+// ListParts returns parts uploaded so far to given upload ID. Meta of each part holds its erasure info.
+func (ds *Erasure) ListParts(uploadID disk.UploadID) ([]dataspace.Part, error) {
+	parts, _, err := ds.ListPartsWithInfo(uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDataSpaceParts(parts)
+}
+
+// ListPartsWithInfo returns parts uploaded so far to given upload ID as agreed by read quorum of shard disks.
+// Shard disks failed or answered differently are returned as disagreements.
+func (ds *Erasure) ListPartsWithInfo(uploadID disk.UploadID) ([]Part, []Disagreement, error) {
+	diskParts := make([][]disk.Part, len(ds.shardDisks))
+	index, disagreements, err := ds.getQuorum(func(i int) (interface{}, error) {
+		var err error
+		diskParts[i], err = ds.shardDisks[i].ListParts(uploadID)
+		return diskParts[i], err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parts, err := newParts(diskParts[index])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parts, disagreements, nil
+}
+
+// Get returns reader of given range of data. Data info is read by GetMetadataWithInfo(). Returned reader is
+// DataReader to report shard disks failed while reading.
+func (ds *Erasure) Get(dataID disk.DataID, offset int64, length uint64) (disk.DataReader, error) {
+	dataInfo, _, err := ds.GetMetadataWithInfo(dataID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ds.GetWithInfo(dataID, dataInfo, offset, length)
+}
+
+// GetWithInfo returns reader of given range of data. Returned reader also supports random access within the range
+// and reports shard disks failed while reading by ShardErrors().
+func (ds *Erasure) GetWithInfo(dataID disk.DataID, dataInfo *DataInfo, offset int64, length uint64) (rc DataReader, err error) {
 	shardIDMap := make(map[string]int)
 	for i := range ds.shardDisks {
 		shardIDMap[ds.shardDisks[i].ID()] = i
 	}
 
 	var mutex sync.Mutex
-	getShardReader := func(shardID string, offset, length int64) (io.ReadCloser, error) {
+	getShardReader := func(shardID string, offset, length int64) (disk.DataReader, error) {
 		mutex.Lock()
 		i := shardIDMap[shardID]
 		mutex.Unlock()
 		return ds.shardDisks[i].Get(dataID, offset, uint64(length))
 	}
 
-	return newDataReader(getShardReader, dataInfo, offset, length)
+	dr, err := newDataReader(getShardReader, dataInfo, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	dr.readAhead = ds.readAhead
+	return dr, nil
+}
+
+// GetMetadata returns data info of given data ID. Meta of each part holds its erasure info.
+func (ds *Erasure) GetMetadata(dataID disk.DataID) (*dataspace.DataInfo, error) {
+	dataInfo, _, err := ds.GetMetadataWithInfo(dataID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := newDataSpaceParts(dataInfo.Parts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataspace.DataInfo{
+		Parts: parts,
+		Size:  dataInfo.Size,
+	}, nil
 }
 
-// func (ds *Erasure) GetMetadata(ID string) (map[string][]string, error) {
-// }
-//
-// func (ds *Erasure) Delete(ID string) error {
-// }
-//
-// func (ds *Erasure) Copy(ID, srcID string, offset, length uint64, metadata map[string][]string) error {
-// }
-//
+// GetMetadataWithInfo returns data info of given data ID as agreed by read quorum of shard disks. Shard disks
+// failed or answered differently are returned as disagreements.
+func (ds *Erasure) GetMetadataWithInfo(dataID disk.DataID) (*DataInfo, []Disagreement, error) {
+	diskDataInfos := make([]*disk.DataInfo, len(ds.shardDisks))
+	index, disagreements, err := ds.getQuorum(func(i int) (interface{}, error) {
+		var err error
+		diskDataInfos[i], err = ds.shardDisks[i].GetMetadata(dataID)
+		return diskDataInfos[i], err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parts, err := newParts(diskDataInfos[index].Parts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := uint64(0)
+	for i := range parts {
+		size += parts[i].Size
+	}
+
+	return &DataInfo{
+		Parts: parts,
+		Size:  size,
+	}, disagreements, nil
+}
+
+// Delete removes data of given data ID from shard disks. If it is not removed from minSuccess shard disks,
+// removed data is restored.
+func (ds *Erasure) Delete(dataID disk.DataID) (err error) {
+	errs := make([]error, len(ds.shardDisks))
+	var wg sync.WaitGroup
+	for i := range ds.shardDisks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ds.shardDisks[i].Delete(dataID)
+		}(i)
+	}
+	wg.Wait()
+
+	successCount := uint64(0)
+	for i := range errs {
+		if errs[i] == nil {
+			successCount++
+		}
+	}
+
+	if successCount >= ds.minSuccess {
+		return nil
+	}
+
+	for i := range errs {
+		if errs[i] == nil {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ds.shardDisks[i].RevertDelete(dataID)
+			}(i)
+		}
+	}
+	wg.Wait()
+
+	return fmt.Errorf("too many errors; %v", errs)
+}
+
+// Copy creates data of dataID from given range of data of srcID. Partially covered parts are erasure encoded
+// by data and parity count of source part at offset.
+func (ds *Erasure) Copy(dataID, srcID disk.DataID, offset int64, length uint64) error {
+	srcDataInfo, _, err := ds.GetMetadataWithInfo(srcID)
+	if err != nil {
+		return err
+	}
+
+	_, err = ds.CopyWithInfo(dataID, srcID, srcDataInfo, offset, length, ds.rangeInfo(srcDataInfo, offset, length))
+	return err
+}
+
+// CopyWithInfo creates data of dataID from given range of data of srcID. If the range covers whole parts, each
+// shard disk hard links its shards of those parts; else the range is copied as a single part erasure encoded
+// by info.
+func (ds *Erasure) CopyWithInfo(dataID, srcID disk.DataID, srcDataInfo *DataInfo, offset int64, length uint64, info *erasure.Info) (*DataInfo, error) {
+	if err := validateParts(srcDataInfo.Parts); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || uint64(offset)+length > srcDataInfo.Size {
+		return nil, errors.New("insufficient data")
+	}
+
+	partSizes := make([]int64, len(srcDataInfo.Parts))
+	for i, part := range srcDataInfo.Parts {
+		partSizes[i] = int64(part.Size)
+	}
+
+	var startPart, endPart, bytesToSkip, bytesToRead int64
+	if length > 0 {
+		startPart, endPart, bytesToSkip, bytesToRead = boundary.CalcPartBoundaries(partSizes, offset, int64(length))
+	}
+
+	if endPart == startPart || bytesToSkip != 0 || bytesToRead != partSizes[endPart-1] {
+		return ds.copyRange(dataID, srcID, srcDataInfo, offset, length, info)
+	}
+
+	var shardOffset, shardLength uint64
+	for i := int64(0); i < endPart; i++ {
+		if i < startPart {
+			shardOffset += getShardPartSize(srcDataInfo.Parts[i])
+		} else {
+			shardLength += getShardPartSize(srcDataInfo.Parts[i])
+		}
+	}
+
+	errs := make([]error, len(ds.shardDisks))
+	var wg sync.WaitGroup
+	for i := range ds.shardDisks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ds.shardDisks[i].Copy(dataID, srcID, int64(shardOffset), shardLength)
+		}(i)
+	}
+	wg.Wait()
+
+	successCount := uint64(0)
+	for i := range errs {
+		if errs[i] == nil {
+			successCount++
+		}
+	}
+
+	if successCount >= ds.minSuccess {
+		parts := make([]Part, endPart-startPart)
+		copy(parts, srcDataInfo.Parts[startPart:endPart])
+		return &DataInfo{
+			Parts: parts,
+			Size:  length,
+		}, nil
+	}
+
+	for i := range errs {
+		if errs[i] == nil {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ds.shardDisks[i].Delete(dataID)
+			}(i)
+		}
+	}
+	wg.Wait()
+
+	return nil, fmt.Errorf("too many errors; %v", errs)
+}
+
+// copyRange copies given range of data of srcID by UploadPartCopyWithInfo() into new upload of single part.
+func (ds *Erasure) copyRange(dataID, srcID disk.DataID, srcDataInfo *DataInfo, offset int64, length uint64, info *erasure.Info) (*DataInfo, error) {
+	uploadID := disk.NewUploadID()
+	if err := ds.InitUpload(uploadID); err != nil {
+		return nil, err
+	}
+
+	if len(info.ShardIDs) == 0 {
+		info.ShardIDs = ds.ShardIDs(dataID)
+	}
+
+	if _, err := ds.UploadPartCopyWithInfo(uploadID, "1", srcID, srcDataInfo, offset, length, info); err != nil {
+		ds.AbortUpload(uploadID)
+		return nil, err
+	}
+
+	dataInfo, err := ds.CompleteUploadWithInfo(dataID, uploadID, []Part{{Info: *info, ID: "1"}})
+	if err != nil {
+		ds.AbortUpload(uploadID)
+	}
+
+	return dataInfo, err
+}
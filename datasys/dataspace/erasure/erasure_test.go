@@ -1,14 +1,20 @@
 package erasure
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"reflect"
+	"strings"
 	"testing"
 
-	"github.com/balamurugana/goat/datasys/disk"
+	"github.com/balamurugana/goat/datasys/dataspace"
+	"github.com/balamurugana/goat/datasys/dataspace/dataspacetest"
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	xerrors "github.com/balamurugana/goat/datasys/errors"
 	"github.com/balamurugana/goat/pkg/erasure"
 	xhash "github.com/balamurugana/goat/pkg/hash"
 	xrand "github.com/balamurugana/goat/pkg/rand"
@@ -119,7 +125,7 @@ func TestSaveTempFile(t *testing.T) {
 				}()
 
 				count := testCase.info.DataCount + testCase.info.ParityCount
-				shardDisks := make([]*disk.Disk, count)
+				shardDisks := make([]ShardDisk, count)
 				var err error
 				for j := uint64(0); j < count; j++ {
 					id := fmt.Sprintf("d%v", j)
@@ -137,7 +143,7 @@ func TestSaveTempFile(t *testing.T) {
 				erasureDisk := NewErasure(shardDisks, count)
 
 				tempFilename := disk.NewTempFilename()
-				checksum, err := erasureDisk.SaveTempFile(tempFilename, randReader(), true, testCase.info)
+				checksum, err := erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, testCase.info)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -254,7 +260,7 @@ func TestRemoveTempFile(t *testing.T) {
 				}()
 
 				count := testCase.info.DataCount + testCase.info.ParityCount
-				shardDisks := make([]*disk.Disk, count)
+				shardDisks := make([]ShardDisk, count)
 				var err error
 				for j := uint64(0); j < count; j++ {
 					id := fmt.Sprintf("d%v", j)
@@ -272,7 +278,7 @@ func TestRemoveTempFile(t *testing.T) {
 				erasureDisk := NewErasure(shardDisks, count)
 
 				tempFilename := disk.NewTempFilename()
-				checksum, err := erasureDisk.SaveTempFile(tempFilename, randReader(), true, testCase.info)
+				checksum, err := erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, testCase.info)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -308,7 +314,7 @@ func TestInitUpload(t *testing.T) {
 			}()
 
 			count := uint64(7)
-			shardDisks := make([]*disk.Disk, count)
+			shardDisks := make([]ShardDisk, count)
 			var err error
 			for j := uint64(0); j < count; j++ {
 				id := fmt.Sprintf("d%v", j)
@@ -441,7 +447,7 @@ func TestUploadPart(t *testing.T) {
 				}()
 
 				count := testCase.info.DataCount + testCase.info.ParityCount
-				shardDisks := make([]*disk.Disk, count)
+				shardDisks := make([]ShardDisk, count)
 				var err error
 				for j := uint64(0); j < count; j++ {
 					id := fmt.Sprintf("d%v", j)
@@ -459,7 +465,7 @@ func TestUploadPart(t *testing.T) {
 				erasureDisk := NewErasure(shardDisks, count)
 
 				tempFilename := disk.NewTempFilename()
-				checksum, err := erasureDisk.SaveTempFile(tempFilename, randReader(), true, testCase.info)
+				checksum, err := erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, testCase.info)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -470,7 +476,7 @@ func TestUploadPart(t *testing.T) {
 
 				uploadID := disk.NewUploadID()
 
-				if err = erasureDisk.UploadPart(uploadID, "211", tempFilename); err == nil {
+				if err = erasureDisk.UploadPartWithInfo(uploadID, "211", tempFilename, testCase.info); err == nil {
 					t.Fatalf("mismatch: expected: <error>, got: <nil>")
 				}
 
@@ -478,11 +484,11 @@ func TestUploadPart(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				if err = erasureDisk.UploadPart(uploadID, "211", tempFilename); err != nil {
+				if err = erasureDisk.UploadPartWithInfo(uploadID, "211", tempFilename, testCase.info); err != nil {
 					t.Fatal(err)
 				}
 
-				if err = erasureDisk.UploadPart(uploadID, "211", tempFilename); err == nil {
+				if err = erasureDisk.UploadPartWithInfo(uploadID, "211", tempFilename, testCase.info); err == nil {
 					t.Fatalf("mismatch: expected: <error>, got: <nil>")
 				}
 			},
@@ -490,6 +496,99 @@ func TestUploadPart(t *testing.T) {
 	}
 }
 
+func TestUploadPartCopy(t *testing.T) {
+	id := xrand.NewID(8).String()
+	workDir := id
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	count := uint64(6)
+	shardDisks := make([]ShardDisk, count)
+	var err error
+	for j := uint64(0); j < count; j++ {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		shardDisks[j], err = disk.NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	erasureDisk := NewErasure(shardDisks, count)
+
+	uploadID := disk.NewUploadID()
+	if err = erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []Part{newPart("3", 16279), newPart("8", 10992)}
+	for j := range parts {
+		tempFilename := disk.NewTempFilename()
+		info := &erasure.Info{DataCount: 4, ParityCount: 2, Size: parts[j].Size, ShardSize: MiB}
+		if _, err = erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = erasureDisk.UploadPartWithInfo(uploadID, parts[j].ID, tempFilename, info); err != nil {
+			t.Fatal(err)
+		}
+
+		parts[j].Info = *info
+	}
+
+	srcID := disk.NewDataID()
+	srcDataInfo, err := erasureDisk.CompleteUploadWithInfo(srcID, uploadID, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadID = disk.NewUploadID()
+	if err = erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	info := &erasure.Info{DataCount: 2, ParityCount: 4, ShardSize: MiB}
+	if _, err = erasureDisk.UploadPartCopyWithInfo(uploadID, "1", srcID, srcDataInfo, 12958, 10992, info); err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size != 10992 {
+		t.Fatalf("mismatch: size: expected: 10992, got: %v", info.Size)
+	}
+
+	dataID := disk.NewDataID()
+	dataInfo, err := erasureDisk.CompleteUploadWithInfo(dataID, uploadID, []Part{{Info: *info, ID: "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := erasureDisk.GetWithInfo(dataID, dataInfo, 0, 10992)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rc.Close()
+
+	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+	if _, err = io.Copy(hasher, rc); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedChecksum := "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"
+	if checksum := hasher.HexSum(nil); checksum != expectedChecksum {
+		t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+	}
+}
+
 func TestAbortUpload(t *testing.T) {
 	testCases := []struct {
 		parts []Part
@@ -509,7 +608,7 @@ func TestAbortUpload(t *testing.T) {
 	}()
 
 	count := uint64(7)
-	shardDisks := make([]*disk.Disk, count)
+	shardDisks := make([]ShardDisk, count)
 	var err error
 	for j := uint64(0); j < count; j++ {
 		id := fmt.Sprintf("d%v", j)
@@ -545,11 +644,11 @@ func TestAbortUpload(t *testing.T) {
 						ShardSize:   MiB,
 					}
 
-					if _, err := erasureDisk.SaveTempFile(tempFilename, randReader(), true, info); err != nil {
+					if _, err := erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info); err != nil {
 						t.Fatalf("%v: %v", testCase.parts[j].ID, err)
 					}
 
-					if err := erasureDisk.UploadPart(uploadID, testCase.parts[j].ID, tempFilename); err != nil {
+					if err := erasureDisk.UploadPartWithInfo(uploadID, testCase.parts[j].ID, tempFilename, info); err != nil {
 						t.Fatalf("%v: %v", testCase.parts[j].ID, err)
 					}
 				}
@@ -566,6 +665,90 @@ func TestAbortUpload(t *testing.T) {
 	}
 }
 
+func TestListParts(t *testing.T) {
+	id := xrand.NewID(8).String()
+	workDir := id
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	count := uint64(6)
+	shardDisks := make([]ShardDisk, count)
+	var err error
+	for j := uint64(0); j < count; j++ {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		shardDisks[j], err = disk.NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	erasureDisk := NewErasure(shardDisks, count)
+
+	uploadID := disk.NewUploadID()
+	if _, _, err = erasureDisk.ListPartsWithInfo(uploadID); err != xerrors.ErrUploadIDNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrUploadIDNotFound, err)
+	}
+
+	if err = erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []Part{newPart("3", 16279), newPart("8", 10992)}
+	for j := range parts {
+		tempFilename := disk.NewTempFilename()
+		info := &erasure.Info{DataCount: 4, ParityCount: 2, Size: parts[j].Size, ShardSize: MiB}
+		if _, err = erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = erasureDisk.UploadPartWithInfo(uploadID, parts[j].ID, tempFilename, info); err != nil {
+			t.Fatal(err)
+		}
+
+		parts[j].Info = *info
+	}
+
+	listedParts, disagreements, err := erasureDisk.ListPartsWithInfo(uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(listedParts, parts) {
+		t.Fatalf("mismatch: expected: %+v, got: %+v", parts, listedParts)
+	}
+
+	if disagreements != nil {
+		t.Fatalf("mismatch: disagreements: expected: <nil>, got: %v", disagreements)
+	}
+
+	if err = shardDisks[0].AbortUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	if listedParts, disagreements, err = erasureDisk.ListPartsWithInfo(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(listedParts, parts) {
+		t.Fatalf("mismatch: expected: %+v, got: %+v", parts, listedParts)
+	}
+
+	expectedDisagreements := []Disagreement{{ShardID: "d0", Err: xerrors.ErrUploadIDNotFound}}
+	if !reflect.DeepEqual(disagreements, expectedDisagreements) {
+		t.Fatalf("mismatch: disagreements: expected: %v, got: %v", expectedDisagreements, disagreements)
+	}
+}
+
 func TestCompleteUpload(t *testing.T) {
 	testCases := []struct {
 		parts    []Part
@@ -640,7 +823,7 @@ func TestCompleteUpload(t *testing.T) {
 	}()
 
 	count := uint64(7)
-	shardDisks := make([]*disk.Disk, count)
+	shardDisks := make([]ShardDisk, count)
 	var err error
 	for j := uint64(0); j < count; j++ {
 		id := fmt.Sprintf("d%v", j)
@@ -656,6 +839,7 @@ func TestCompleteUpload(t *testing.T) {
 	}
 
 	erasureDisk := NewErasure(shardDisks, count)
+	erasureDisk.SetPlacement(OrderedPlacement)
 
 	for i, testCase := range testCases {
 		t.Run(
@@ -676,11 +860,11 @@ func TestCompleteUpload(t *testing.T) {
 						ShardSize:   MiB,
 					}
 
-					if _, err := erasureDisk.SaveTempFile(tempFilename, randReader(), true, info); err != nil {
+					if _, err := erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info); err != nil {
 						t.Fatalf("%v: %v", testCase.parts[j].ID, err)
 					}
 
-					if err := erasureDisk.UploadPart(uploadID, testCase.parts[j].ID, tempFilename); err != nil {
+					if err := erasureDisk.UploadPartWithInfo(uploadID, testCase.parts[j].ID, tempFilename, info); err != nil {
 						t.Fatalf("%v: %v", testCase.parts[j].ID, err)
 					}
 
@@ -688,7 +872,7 @@ func TestCompleteUpload(t *testing.T) {
 				}
 
 				dataID := disk.NewDataID()
-				dataInfo, err := erasureDisk.CompleteUpload(dataID, uploadID, testCase.parts)
+				dataInfo, err := erasureDisk.CompleteUploadWithInfo(dataID, uploadID, testCase.parts)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -730,7 +914,7 @@ func TestGet(t *testing.T) {
 	}()
 
 	count := uint64(7)
-	shardDisks := make([]*disk.Disk, count)
+	shardDisks := make([]ShardDisk, count)
 	var err error
 	for j := uint64(0); j < count; j++ {
 		id := fmt.Sprintf("d%v", j)
@@ -766,11 +950,11 @@ func TestGet(t *testing.T) {
 						ShardSize:   MiB,
 					}
 
-					if _, err := erasureDisk.SaveTempFile(tempFilename, randReader(), true, info); err != nil {
+					if _, err := erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info); err != nil {
 						t.Fatalf("%v: %v", testCase.parts[j].ID, err)
 					}
 
-					if err := erasureDisk.UploadPart(uploadID, testCase.parts[j].ID, tempFilename); err != nil {
+					if err := erasureDisk.UploadPartWithInfo(uploadID, testCase.parts[j].ID, tempFilename, info); err != nil {
 						t.Fatalf("%v: %v", testCase.parts[j].ID, err)
 					}
 
@@ -778,12 +962,12 @@ func TestGet(t *testing.T) {
 				}
 
 				dataID := disk.NewDataID()
-				dataInfo, err := erasureDisk.CompleteUpload(dataID, uploadID, testCase.parts)
+				dataInfo, err := erasureDisk.CompleteUploadWithInfo(dataID, uploadID, testCase.parts)
 				if err != nil {
 					t.Fatal(err)
 				}
 
-				rc, err := erasureDisk.Get(dataID, dataInfo, testCase.offset, testCase.length)
+				rc, err := erasureDisk.GetWithInfo(dataID, dataInfo, testCase.offset, testCase.length)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -805,7 +989,774 @@ func TestGet(t *testing.T) {
 				if testCase.checksum != checksum {
 					t.Fatalf("expected: %v, got: %v", testCase.checksum, checksum)
 				}
+
+				// Random access and seek within the range.
+				hasher.Reset()
+				if _, err = io.Copy(hasher, io.NewSectionReader(rc, 0, int64(testCase.length))); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum = hasher.HexSum(nil); testCase.checksum != checksum {
+					t.Fatalf("ReadAt: expected: %v, got: %v", testCase.checksum, checksum)
+				}
+
+				if _, err = rc.Seek(0, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+
+				hasher.Reset()
+				if _, err = io.Copy(hasher, rc); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum = hasher.HexSum(nil); testCase.checksum != checksum {
+					t.Fatalf("Seek: expected: %v, got: %v", testCase.checksum, checksum)
+				}
+
+				// Sequential read with blocks decoded ahead.
+				erasureDisk.SetReadAhead(2)
+				defer erasureDisk.SetReadAhead(0)
+
+				prc, err := erasureDisk.GetWithInfo(dataID, dataInfo, testCase.offset, testCase.length)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer prc.Close()
+
+				hasher.Reset()
+				if _, err = io.Copy(hasher, prc); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum = hasher.HexSum(nil); testCase.checksum != checksum {
+					t.Fatalf("ReadAhead: expected: %v, got: %v", testCase.checksum, checksum)
+				}
 			},
 		)
 	}
 }
+
+func TestGetShardErrors(t *testing.T) {
+	id := xrand.NewID(8).String()
+	workDir := id
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	count := uint64(6)
+	shardDisks := make([]ShardDisk, count)
+	var err error
+	for j := uint64(0); j < count; j++ {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		shardDisks[j], err = disk.NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	erasureDisk := NewErasure(shardDisks, count)
+	erasureDisk.SetLayout(4, 2, 4096)
+
+	uploadID := disk.NewUploadID()
+	if err = erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []disk.Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}
+	for _, part := range parts {
+		tempFilename := disk.NewTempFilename()
+		if _, err = erasureDisk.SaveTempFile(tempFilename, randReader(), part.Size, true); err != nil {
+			t.Fatalf("%v: %v", part.ID, err)
+		}
+
+		if err = erasureDisk.UploadPart(uploadID, part.ID, tempFilename); err != nil {
+			t.Fatalf("%v: %v", part.ID, err)
+		}
+	}
+
+	dataID := disk.NewDataID()
+	if err = erasureDisk.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	dataInfo, _, err := erasureDisk.GetMetadataWithInfo(dataID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(random bool) []erasure.ShardError {
+		rc, err := erasureDisk.Get(dataID, 12958, 10992)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var reader io.Reader = rc
+		if random {
+			reader = io.NewSectionReader(rc, 0, 10992)
+		}
+
+		hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+		if _, err = io.Copy(hasher, reader); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"
+		if checksum := hasher.HexSum(nil); checksum != expected {
+			t.Fatalf("mismatch: expected: %v, got: %v", expected, checksum)
+		}
+
+		if err = rc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		return rc.(DataReader).ShardErrors()
+	}
+
+	for _, random := range []bool{false, true} {
+		if shardErrs := read(random); len(shardErrs) != 0 {
+			t.Fatalf("random %v: mismatch: expected: <nil>, got: %v", random, shardErrs)
+		}
+	}
+
+	// Remove shard of part 8 on a data shard disk to force degraded read.
+	shardID := dataInfo.Parts[1].ShardIDs[0]
+	partFile := path.Join(workDir, shardID, "data", dataID.String()[:2], dataID.String(), "8.part")
+	if err = os.Remove(partFile); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, random := range []bool{false, true} {
+		shardErrs := read(random)
+		if len(shardErrs) != 1 || shardErrs[0].ShardID != shardID || shardErrs[0].Err == nil {
+			t.Fatalf("random %v: mismatch: expected: %v, got: %v", random, shardID, shardErrs)
+		}
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	id := xrand.NewID(8).String()
+	workDir := id
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	count := uint64(6)
+	shardDisks := make([]ShardDisk, count)
+	var err error
+	for j := uint64(0); j < count; j++ {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		shardDisks[j], err = disk.NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	erasureDisk := NewErasure(shardDisks, count)
+
+	uploadID := disk.NewUploadID()
+	if err = erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []Part{newPart("3", 16279), newPart("8", 10992)}
+	for j := range parts {
+		tempFilename := disk.NewTempFilename()
+		info := &erasure.Info{DataCount: 4, ParityCount: 2, Size: parts[j].Size, ShardSize: MiB}
+		if _, err = erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = erasureDisk.UploadPartWithInfo(uploadID, parts[j].ID, tempFilename, info); err != nil {
+			t.Fatal(err)
+		}
+
+		parts[j].Info = *info
+	}
+
+	dataID := disk.NewDataID()
+	if _, _, err = erasureDisk.GetMetadataWithInfo(dataID); err != xerrors.ErrDataIDNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+
+	expectedDataInfo, err := erasureDisk.CompleteUploadWithInfo(dataID, uploadID, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shard disk d1 lost the data and d2 has different data info.
+	if err = shardDisks[1].Delete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	dataJSONFile := path.Join(workDir, "d2", "data", dataID.String()[:2], dataID.String(), "data.json")
+	file, err := os.Create(dataJSONFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.NewEncoder(file).Encode(&disk.DataInfo{Parts: []disk.Part{{ID: "3", Size: 4096}}, Size: 4096}); err != nil {
+		file.Close()
+		t.Fatal(err)
+	}
+	file.Close()
+
+	dataInfo, disagreements, err := erasureDisk.GetMetadataWithInfo(dataID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(dataInfo, expectedDataInfo) {
+		t.Fatalf("mismatch: expected: %+v, got: %+v", expectedDataInfo, dataInfo)
+	}
+
+	expectedDisagreements := []Disagreement{
+		{ShardID: "d1", Err: xerrors.ErrDataIDNotFound},
+		{ShardID: "d2", Err: xerrors.ErrShardMismatch},
+	}
+	if !reflect.DeepEqual(disagreements, expectedDisagreements) {
+		t.Fatalf("mismatch: disagreements: expected: %v, got: %v", expectedDisagreements, disagreements)
+	}
+
+	// As data is written to all shard disks, answer agreed by most shard disks wins.
+	if err = shardDisks[3].Delete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, disagreements, err = erasureDisk.GetMetadataWithInfo(dataID); err != nil || len(disagreements) != 3 {
+		t.Fatalf("mismatch: expected: 3 disagreements, <nil>, got: %v, %v", disagreements, err)
+	}
+
+	if err = shardDisks[4].Delete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = erasureDisk.GetMetadataWithInfo(dataID); err != xerrors.ErrDataIDNotFound {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+}
+
+func TestGetQuorum(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	shardDisks := newTestDisks(t, workDir, 4)
+	pathErr := func(i int) error {
+		return &os.PathError{Op: "open", Path: shardDisks[i].ID(), Err: os.ErrNotExist}
+	}
+
+	testCases := []struct {
+		minSuccess       uint64
+		answers          []interface{}
+		expectedIndex    int
+		expectedDisagree int
+		expectedErr      error
+	}{
+		{4, []interface{}{"a", errors.New("x"), errors.New("x"), "b"}, -1, 0, errors.New("no read quorum")},
+		{4, []interface{}{"a", errors.New("x"), errors.New("x"), "a"}, 0, 2, nil},
+		{3, []interface{}{"a", "a", "b", errors.New("x")}, 0, 2, nil},
+		{3, []interface{}{"a", "b", "c", errors.New("x")}, -1, 0, errors.New("no read quorum")},
+		{3, []interface{}{"a", "a", "b", "b"}, -1, 0, errors.New("no read quorum")},
+		{3, []interface{}{pathErr(0), pathErr(1), "a", pathErr(3)}, -1, 0, os.ErrNotExist},
+		{2, []interface{}{"a", xerrors.ErrDataIDNotFound, xerrors.ErrDataIDNotFound, xerrors.ErrDataIDNotFound}, -1, 0, xerrors.ErrDataIDNotFound},
+		{2, []interface{}{"a", "b", xerrors.ErrDataIDNotFound, "b"}, 1, 2, nil},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				erasureDisk := NewErasure(shardDisks, testCase.minSuccess)
+				index, disagreements, err := erasureDisk.getQuorum(func(j int) (interface{}, error) {
+					if err, ok := testCase.answers[j].(error); ok {
+						return nil, err
+					}
+					return testCase.answers[j], nil
+				})
+
+				switch {
+				case testCase.expectedErr == nil:
+					if err != nil {
+						t.Fatalf("mismatch: expected: <nil>, got: %v", err)
+					}
+				case errors.Is(err, testCase.expectedErr):
+				case err == nil || !strings.HasPrefix(err.Error(), testCase.expectedErr.Error()):
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.expectedErr, err)
+				}
+
+				if index != testCase.expectedIndex || len(disagreements) != testCase.expectedDisagree {
+					t.Fatalf("mismatch: expected: %v, %v, got: %v, %v", testCase.expectedIndex, testCase.expectedDisagree, index, len(disagreements))
+				}
+			},
+		)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	testCases := []struct {
+		offset   int64
+		length   uint64
+		checksum string
+		linked   bool
+	}{
+		{16279, 10992, "60c7436deea126319878ecbf43b853f39f3451dccacde02b4e6a66082e9d168a", true},
+		{12958, 17343, "f76f77b058eb962e5099062cccfcf5e9363cdb533a86563680f8488ee99f0cfa", false},
+		{27271, 70, "f9b63a4a399ca9f26b15f7dc5987b1644b6054ac9c34f6c136c6576eb77d9956", false},
+	}
+
+	id := xrand.NewID(8).String()
+	workDir := id
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	count := uint64(6)
+	shardDisks := make([]ShardDisk, count)
+	var err error
+	for j := uint64(0); j < count; j++ {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		shardDisks[j], err = disk.NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	erasureDisk := NewErasure(shardDisks, count)
+
+	uploadID := disk.NewUploadID()
+	if err = erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []Part{newPart("3", 16279), newPart("8", 10992), newPart("1", 25489)}
+	for j := range parts {
+		tempFilename := disk.NewTempFilename()
+		info := &erasure.Info{DataCount: 4, ParityCount: 2, Size: parts[j].Size, ShardSize: MiB}
+		if _, err = erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = erasureDisk.UploadPartWithInfo(uploadID, parts[j].ID, tempFilename, info); err != nil {
+			t.Fatal(err)
+		}
+
+		parts[j].Info = *info
+	}
+
+	srcID := disk.NewDataID()
+	srcDataInfo, err := erasureDisk.CompleteUploadWithInfo(srcID, uploadID, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getPartFile := func(dataID disk.DataID, partID string) string {
+		return path.Join(workDir, "d0", "data", dataID.String()[:2], dataID.String(), partID+".part")
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				dataID := disk.NewDataID()
+				info := &erasure.Info{DataCount: 3, ParityCount: 3, ShardSize: MiB}
+				dataInfo, err := erasureDisk.CopyWithInfo(dataID, srcID, srcDataInfo, testCase.offset, testCase.length, info)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if dataInfo.Size != testCase.length {
+					t.Fatalf("mismatch: size: expected: %v, got: %v", testCase.length, dataInfo.Size)
+				}
+
+				if testCase.linked {
+					srcInfo, err := os.Stat(getPartFile(srcID, dataInfo.Parts[0].ID))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					info, err := os.Stat(getPartFile(dataID, dataInfo.Parts[0].ID))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !os.SameFile(srcInfo, info) {
+						t.Fatalf("expected: <hard link>, got: <copy>")
+					}
+				}
+
+				rc, err := erasureDisk.GetWithInfo(dataID, dataInfo, 0, testCase.length)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer rc.Close()
+
+				hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+				if _, err = io.Copy(hasher, rc); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum := hasher.HexSum(nil); checksum != testCase.checksum {
+					t.Fatalf("mismatch: checksum: expected: %v, got: %v", testCase.checksum, checksum)
+				}
+			},
+		)
+	}
+}
+
+func TestDataSpace(t *testing.T) {
+	dataspacetest.Run(t, func(t *testing.T) (dataspace.DataSpace, func()) {
+		workDir := xrand.NewID(8).String()
+		if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		count := uint64(6)
+		shardDisks := make([]ShardDisk, count)
+		for j := uint64(0); j < count; j++ {
+			id := fmt.Sprintf("d%v", j)
+			dataDir := path.Join(workDir, id)
+			if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+				os.RemoveAll(workDir)
+				t.Fatalf("%v: %v", id, err)
+			}
+
+			var err error
+			if shardDisks[j], err = disk.NewDisk(id, dataDir); err != nil {
+				os.RemoveAll(workDir)
+				t.Fatalf("%v: %v", id, err)
+			}
+		}
+
+		erasureDisk := NewErasure(shardDisks, count)
+		erasureDisk.SetLayout(4, 2, 4096)
+
+		return erasureDisk, func() {
+			os.RemoveAll(workDir)
+		}
+	})
+}
+
+func TestSaveTempFileUnknownSize(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	erasureDisk := NewErasure(newTestDisks(t, workDir, 6), 6)
+	erasureDisk.SetLayout(4, 2, 1024)
+
+	uploadID := disk.NewUploadID()
+	if err := erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFilename := disk.NewTempFilename()
+	checksum, err := erasureDisk.SaveTempFile(tempFilename, io.LimitReader(randReader(), 16279), dataspace.UnknownSize, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedChecksum := "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"
+	if checksum != expectedChecksum {
+		t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+	}
+
+	if err = erasureDisk.UploadPart(uploadID, "1", tempFilename); err != nil {
+		t.Fatal(err)
+	}
+
+	parts, err := erasureDisk.ListParts(uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) != 1 || parts[0].Size != 16279 {
+		t.Fatalf("mismatch: expected: [{1 16279}], got: %+v", parts)
+	}
+
+	dataID := disk.NewDataID()
+	if err = erasureDisk.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	if checksum = checksumData(t, erasureDisk, dataID, 0, 16279); checksum != expectedChecksum {
+		t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+	}
+}
+
+func TestSaveTempFilePipelined(t *testing.T) {
+	testCases := []struct {
+		data io.Reader
+		size uint64
+	}{
+		{randReader(), 16279},
+		{io.LimitReader(randReader(), 16279), erasure.UnknownSize},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				workDir := xrand.NewID(8).String()
+				if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+
+				defer func() {
+					os.RemoveAll(workDir)
+				}()
+
+				erasureDisk := NewErasure(newTestDisks(t, workDir, 6), 6)
+				erasureDisk.SetPipeline(&erasure.PipelineOptions{QueueLength: 2, Encoders: 2, Verify: true})
+
+				uploadID := disk.NewUploadID()
+				if err := erasureDisk.InitUpload(uploadID); err != nil {
+					t.Fatal(err)
+				}
+
+				info := &erasure.Info{DataCount: 4, ParityCount: 2, Size: testCase.size, ShardSize: 1024}
+				tempFilename := disk.NewTempFilename()
+				checksum, err := erasureDisk.SaveTempFileWithInfo(tempFilename, testCase.data, true, info)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				expectedChecksum := "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"
+				if checksum != expectedChecksum {
+					t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+				}
+
+				if info.Size != 16279 {
+					t.Fatalf("mismatch: size: expected: 16279, got: %v", info.Size)
+				}
+
+				if err = erasureDisk.UploadPart(uploadID, "1", tempFilename); err != nil {
+					t.Fatal(err)
+				}
+
+				dataID := disk.NewDataID()
+				if err = erasureDisk.CompleteUpload(dataID, uploadID, []disk.Part{{ID: "1", Size: 16279}}); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum = checksumData(t, erasureDisk, dataID, 0, 16279); checksum != expectedChecksum {
+					t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+				}
+			},
+		)
+	}
+}
+
+func TestTempInfo(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	shardDisks := newTestDisks(t, workDir, 4)
+	erasureDisk := NewErasure(shardDisks, 4)
+	erasureDisk.SetLayout(2, 2, 1024)
+
+	uploadID := disk.NewUploadID()
+	if err := erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFilenames := []string{disk.NewTempFilename(), disk.NewTempFilename()}
+	for _, tempFilename := range tempFilenames {
+		if _, err := erasureDisk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Erasure info of temporary files is read from shard disks by new instance e.g. after restart.
+	erasureDisk = NewErasure(shardDisks, 4)
+	if err := erasureDisk.UploadPart(uploadID, "1", tempFilenames[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := erasureDisk.RemoveTempFile(tempFilenames[1], true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := erasureDisk.UploadPart(uploadID, "2", tempFilenames[1]); err == nil {
+		t.Fatalf("mismatch: expected: <error>, got: <nil>")
+	}
+
+	for i := range shardDisks {
+		tmpDir := path.Join(workDir, shardDisks[i].ID(), "tmp")
+		if entries, err := os.ReadDir(tmpDir); err != nil || len(entries) != 0 {
+			t.Fatalf("%v: mismatch: expected: 0 entries, got: %v, %v", tmpDir, len(entries), err)
+		}
+	}
+
+	parts, err := erasureDisk.ListParts(uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataID := disk.NewDataID()
+	if err = erasureDisk.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedChecksum := "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"
+	if checksum := checksumData(t, erasureDisk, dataID, 0, 16279); checksum != expectedChecksum {
+		t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+	}
+}
+
+func TestInvalidInfo(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	shardDisks := newTestDisks(t, workDir, 4)
+	erasureDisk := NewErasure(shardDisks, 4)
+
+	info := &erasure.Info{DataCount: 2, ParityCount: 2, Size: 5, ShardSize: 0}
+	if _, err := erasureDisk.SaveTempFileWithInfo(disk.NewTempFilename(), randReader(), true, info); !errors.Is(err, erasure.ErrZeroShardSize) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroShardSize, err)
+	}
+
+	erasureDisk.SetLayout(2, 2, 0)
+	if _, err := erasureDisk.SaveTempFile(disk.NewTempFilename(), randReader(), 5, true); !errors.Is(err, erasure.ErrZeroShardSize) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroShardSize, err)
+	}
+	erasureDisk.SetLayout(2, 2, 4096)
+
+	// Data whose part metadata in shard disks is corrupt.
+	meta, err := json.Marshal(&erasure.Info{DataCount: 0, ParityCount: 4, Size: 100, ShardSize: 4096, ShardIDs: []string{"d0", "d1", "d2", "d3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadID := disk.NewUploadID()
+	dataID := disk.NewDataID()
+	for _, shardDisk := range shardDisks {
+		tempFile := disk.NewTempFilename()
+		if _, err = shardDisk.SaveTempFile(tempFile, randReader(), 100, true); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = shardDisk.InitUpload(uploadID); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = shardDisk.UploadPartWithMeta(uploadID, "1", tempFile, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err = erasureDisk.ListPartsWithInfo(uploadID); !errors.Is(err, erasure.ErrZeroDataCount) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroDataCount, err)
+	}
+
+	parts := []Part{{ID: "1"}}
+	if err = json.Unmarshal(meta, &parts[0].Info); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = erasureDisk.CompleteUploadWithInfo(dataID, uploadID, parts); !errors.Is(err, erasure.ErrZeroDataCount) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroDataCount, err)
+	}
+
+	for _, shardDisk := range shardDisks {
+		if err = shardDisk.CompleteUpload(dataID, uploadID, []disk.Part{{ID: "1", Size: 100, Meta: meta}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err = erasureDisk.GetMetadataWithInfo(dataID); !errors.Is(err, erasure.ErrZeroDataCount) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroDataCount, err)
+	}
+
+	if _, err = erasureDisk.Get(dataID, 0, 10); !errors.Is(err, erasure.ErrZeroDataCount) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroDataCount, err)
+	}
+
+	if _, err = erasureDisk.Heal(dataID); !errors.Is(err, erasure.ErrZeroDataCount) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroDataCount, err)
+	}
+
+	if err = erasureDisk.Copy(disk.NewDataID(), dataID, 0, 100); !errors.Is(err, erasure.ErrZeroDataCount) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroDataCount, err)
+	}
+
+	if _, err = erasureDisk.CopyWithInfo(disk.NewDataID(), dataID, &DataInfo{Parts: parts, Size: 100}, 0, 100, erasureDisk.newInfo(100)); !errors.Is(err, erasure.ErrZeroDataCount) {
+		t.Fatalf("mismatch: expected: %v, got: %v", erasure.ErrZeroDataCount, err)
+	}
+}
+
+// FuzzPartMeta checks that erasure info of parts read from metadata of shard disks is validated before its
+// layout is computed.
+func FuzzPartMeta(f *testing.F) {
+	f.Add([]byte(`{"dataCount":2,"parityCount":2,"size":100,"shardSize":4096,"shardIDs":["0","1","2","3"]}`))
+	f.Add([]byte(`{"dataCount":0,"parityCount":2,"size":5,"shardSize":4096,"shardIDs":["0","1"]}`))
+	f.Add([]byte(`{"dataCount":2,"parityCount":2,"size":5,"shardSize":0,"shardIDs":["0","1","2","3"]}`))
+
+	f.Fuzz(func(t *testing.T, meta []byte) {
+		parts, err := newParts([]disk.Part{{ID: "1", Meta: meta}})
+		if err != nil {
+			return
+		}
+
+		getShardPartSize(parts[0])
+		if _, err = newDiskParts(parts); err != nil {
+			t.Fatal(err)
+		}
+
+		getShardReader := func(shardID string, offset, length int64) (disk.DataReader, error) {
+			return nil, errors.New("not found")
+		}
+
+		if dr, err := newDataReader(getShardReader, &DataInfo{Parts: parts, Size: parts[0].Size}, 0, parts[0].Size); err == nil {
+			dr.Close()
+		}
+	})
+}
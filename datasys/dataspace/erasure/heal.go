@@ -0,0 +1,308 @@
+package erasure
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sync"
+
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	"github.com/balamurugana/goat/datasys/scrub"
+	"github.com/klauspost/reedsolomon"
+)
+
+// ShardHealResult is result of healing data on a shard disk.
+type ShardHealResult struct {
+	ShardID     string
+	HealedParts []string // IDs of parts whose shards are rebuilt onto the shard disk.
+	Err         error    // Error on rebuilding shards onto the shard disk.
+}
+
+// verifyShard reads given range of shard data on shard disk of index i so that missing part files and
+// bitrot are detected by checksum verification.
+func (ds *Erasure) verifyShard(i int, dataID disk.DataID, offset int64, length uint64) error {
+	rc, err := ds.shardDisks[i].Get(dataID, offset, length)
+	if err != nil {
+		return err
+	}
+
+	defer rc.Close()
+
+	_, err = io.Copy(ioutil.Discard, rc)
+	return err
+}
+
+// getStaleParts returns IDs of parts whose shards on shard disk of index i are missing or fail verification.
+// All parts are stale if data info on the shard disk differs from diskParts.
+func (ds *Erasure) getStaleParts(i int, dataID disk.DataID, diskParts []disk.Part) map[string]bool {
+	staleParts := make(map[string]bool)
+	if dataInfo, err := ds.shardDisks[i].GetMetadata(dataID); err != nil || !reflect.DeepEqual(dataInfo.Parts, diskParts) {
+		for _, part := range diskParts {
+			staleParts[part.ID] = true
+		}
+
+		return staleParts
+	}
+
+	offset := int64(0)
+	for _, part := range diskParts {
+		if err := ds.verifyShard(i, dataID, offset, part.Size); err != nil {
+			staleParts[part.ID] = true
+		}
+
+		offset += int64(part.Size)
+	}
+
+	return staleParts
+}
+
+// healPart rebuilds stale shards of part at shardOffset of shard data by reedsolomon.Reconstruct() from
+// healthy shards and saves them as tempFile in their shard disks. healthy and stale are indices of
+// part.ShardIDs and returned errors are indexed likewise.
+func (ds *Erasure) healPart(dataID disk.DataID, part Part, shardOffset int64, diskIndex map[string]int, healthy, stale []int, tempFile string) []error {
+	errs := make([]error, len(part.ShardIDs))
+	fail := func(err error) []error {
+		for _, k := range stale {
+			errs[k] = err
+		}
+
+		return errs
+	}
+
+	if uint64(len(healthy)) < part.DataCount {
+		return fail(fmt.Errorf("insufficient healthy shards; %v < %v", len(healthy), part.DataCount))
+	}
+	healthy = healthy[:part.DataCount]
+
+	decoder, err := reedsolomon.New(int(part.DataCount), int(part.ParityCount))
+	if err != nil {
+		return fail(err)
+	}
+
+	shardPartSize := getShardPartSize(part)
+	readers := make([]io.ReadCloser, len(part.ShardIDs))
+	defer func() {
+		for _, rc := range readers {
+			if rc != nil {
+				rc.Close()
+			}
+		}
+	}()
+
+	for _, k := range healthy {
+		if readers[k], err = ds.shardDisks[diskIndex[part.ShardIDs[k]]].Get(dataID, shardOffset, shardPartSize); err != nil {
+			return fail(err)
+		}
+	}
+
+	pipeWriters := make([]*io.PipeWriter, len(part.ShardIDs))
+	saveErrs := make([]error, len(part.ShardIDs))
+	var wg sync.WaitGroup
+	for _, k := range stale {
+		var pipeReader *io.PipeReader
+		pipeReader, pipeWriters[k] = io.Pipe()
+		wg.Add(1)
+		go func(k int, pipeReader *io.PipeReader) {
+			defer wg.Done()
+			_, saveErrs[k] = ds.shardDisks[diskIndex[part.ShardIDs[k]]].SaveTempFile(tempFile, pipeReader, shardPartSize, true)
+			pipeReader.CloseWithError(saveErrs[k])
+		}(k, pipeReader)
+	}
+
+	shards := make([][]byte, len(part.ShardIDs))
+	for k := range shards {
+		shards[k] = make([]byte, 0, part.ShardSize)
+	}
+
+	blockCount, _, _, lastShardSize := part.Compute()
+	for index := uint64(0); index < blockCount && err == nil; index++ {
+		shardSize := part.ShardSize
+		if index == blockCount-1 {
+			shardSize = lastShardSize
+		}
+
+		for k := range shards {
+			shards[k] = shards[k][:0]
+		}
+
+		for _, k := range healthy {
+			shards[k] = shards[k][:shardSize]
+			if _, err = io.ReadFull(readers[k], shards[k]); err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			err = decoder.Reconstruct(shards)
+		}
+
+		if err == nil {
+			for _, k := range stale {
+				if errs[k] == nil {
+					_, errs[k] = pipeWriters[k].Write(shards[k])
+				}
+			}
+		}
+	}
+
+	for _, k := range stale {
+		pipeWriters[k].CloseWithError(err)
+	}
+	wg.Wait()
+
+	for _, k := range stale {
+		switch {
+		case err != nil:
+			errs[k] = err
+		case saveErrs[k] != nil:
+			errs[k] = saveErrs[k]
+		}
+
+		if errs[k] != nil {
+			ds.shardDisks[diskIndex[part.ShardIDs[k]]].RemoveTempFile(tempFile, true)
+		}
+	}
+
+	return errs
+}
+
+// Heal verifies shards of data of dataID on each shard disk and rebuilds missing or bitrot failed shards onto
+// their shard disks. Data info agreed by read quorum is used, so a shard disk missing the data or having
+// different data info gets all its shards rebuilt. Returned results are of each shard disk.
+func (ds *Erasure) Heal(dataID disk.DataID) ([]ShardHealResult, error) {
+	dataInfo, _, err := ds.GetMetadataWithInfo(dataID)
+	if err != nil {
+		return nil, err
+	}
+
+	diskParts, err := newDiskParts(dataInfo.Parts)
+	if err != nil {
+		return nil, err
+	}
+
+	diskIndex := make(map[string]int)
+	for i := range ds.shardDisks {
+		diskIndex[ds.shardDisks[i].ID()] = i
+	}
+
+	results := make([]ShardHealResult, len(ds.shardDisks))
+	staleParts := make([]map[string]bool, len(ds.shardDisks))
+	var wg sync.WaitGroup
+	for i := range ds.shardDisks {
+		results[i].ShardID = ds.shardDisks[i].ID()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			staleParts[i] = ds.getStaleParts(i, dataID, diskParts)
+		}(i)
+	}
+	wg.Wait()
+
+	tempFiles := make([]map[string]string, len(ds.shardDisks))
+	shardOffset := int64(0)
+	for j, part := range dataInfo.Parts {
+		var healthy, stale []int
+		for k, shardID := range part.ShardIDs {
+			i, found := diskIndex[shardID]
+			if !found {
+				return nil, fmt.Errorf("shard disk of shard ID %v not found", shardID)
+			}
+
+			if staleParts[i][part.ID] {
+				stale = append(stale, k)
+			} else {
+				healthy = append(healthy, k)
+			}
+		}
+
+		if len(stale) > 0 {
+			tempFile := disk.NewTempFilename()
+			errs := ds.healPart(dataID, part, shardOffset, diskIndex, healthy, stale, tempFile)
+			for _, k := range stale {
+				i := diskIndex[part.ShardIDs[k]]
+				if errs[k] != nil {
+					if results[i].Err == nil {
+						results[i].Err = errs[k]
+					}
+					continue
+				}
+
+				if tempFiles[i] == nil {
+					tempFiles[i] = make(map[string]string)
+				}
+				tempFiles[i][part.ID] = tempFile
+			}
+		}
+
+		shardOffset += int64(diskParts[j].Size)
+	}
+
+	diskDataInfo := &disk.DataInfo{Parts: diskParts, Size: uint64(shardOffset)}
+	for i := range ds.shardDisks {
+		if len(staleParts[i]) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if results[i].Err == nil {
+				results[i].Err = ds.shardDisks[i].Heal(dataID, diskDataInfo, tempFiles[i])
+			}
+
+			if results[i].Err != nil {
+				for _, tempFile := range tempFiles[i] {
+					ds.shardDisks[i].RemoveTempFile(tempFile, true)
+				}
+				return
+			}
+
+			for _, part := range dataInfo.Parts {
+				if staleParts[i][part.ID] {
+					results[i].HealedParts = append(results[i].HealedParts, part.ID)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// NewScrubbers returns scrubber of each local shard disk verifying its shards at most bytesPerSec bytes per
+// second. If heal is set, data found corrupt on a shard disk is healed by Heal() and data failed to heal is
+// returned by Unrepaired() of the scrubber. Remote shard disks are scrubbed on their own node.
+func (ds *Erasure) NewScrubbers(bytesPerSec uint64, heal bool) []*scrub.Scrubber {
+	var onCorrupt func(dataID disk.DataID, err error) error
+	if heal {
+		onCorrupt = func(dataID disk.DataID, err error) error {
+			results, err := ds.Heal(dataID)
+			if err != nil {
+				return err
+			}
+
+			var errs []error
+			for _, result := range results {
+				if result.Err != nil {
+					errs = append(errs, fmt.Errorf("%v: %v", result.ShardID, result.Err))
+				}
+			}
+
+			if errs != nil {
+				return fmt.Errorf("unable to heal shards; %v", errs)
+			}
+
+			return nil
+		}
+	}
+
+	var scrubbers []*scrub.Scrubber
+	for i := range ds.shardDisks {
+		if localDisk, ok := ds.shardDisks[i].(*disk.Disk); ok {
+			scrubbers = append(scrubbers, localDisk.NewScrubber(bytesPerSec, onCorrupt))
+		}
+	}
+
+	return scrubbers
+}
@@ -0,0 +1,217 @@
+package erasure
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	"github.com/balamurugana/goat/pkg/erasure"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestHeal(t *testing.T) {
+	id := xrand.NewID(8).String()
+	workDir := id
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	count := uint64(6)
+	shardDisks := make([]ShardDisk, count)
+	var err error
+	for j := uint64(0); j < count; j++ {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		shardDisks[j], err = disk.NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	erasureDisk := NewErasure(shardDisks, count)
+
+	uploadID := disk.NewUploadID()
+	if err = erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []Part{newPart("3", 16279), newPart("8", 10992)}
+	for j := range parts {
+		tempFilename := disk.NewTempFilename()
+		info := &erasure.Info{DataCount: 4, ParityCount: 2, Size: parts[j].Size, ShardSize: 1024}
+		if _, err = erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = erasureDisk.UploadPartWithInfo(uploadID, parts[j].ID, tempFilename, info); err != nil {
+			t.Fatal(err)
+		}
+
+		parts[j].Info = *info
+	}
+
+	dataID := disk.NewDataID()
+	if _, err = erasureDisk.CompleteUploadWithInfo(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	partFile := func(diskID, partID string) string {
+		return path.Join(workDir, diskID, "data", dataID.String()[:2], dataID.String(), partID+".part")
+	}
+
+	shards := make(map[string][]byte)
+	for _, name := range []string{"d0/3", "d0/8", "d1/8"} {
+		if shards[name], err = ioutil.ReadFile(partFile(path.Split(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Shard disk d0 lost the data and d1 has bitrot in shard of part 8.
+	if err = shardDisks[0].Delete(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(partFile("d1", "8"), os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.WriteAt([]byte{0, 0, 0, 0}, 100); err != nil {
+		file.Close()
+		t.Fatal(err)
+	}
+	file.Close()
+
+	results, err := erasureDisk.Heal(dataID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedResults := []ShardHealResult{
+		{ShardID: "d0", HealedParts: []string{"3", "8"}},
+		{ShardID: "d1", HealedParts: []string{"8"}},
+		{ShardID: "d2"},
+		{ShardID: "d3"},
+		{ShardID: "d4"},
+		{ShardID: "d5"},
+	}
+	if !reflect.DeepEqual(results, expectedResults) {
+		t.Fatalf("mismatch: expected: %+v, got: %+v", expectedResults, results)
+	}
+
+	for name, expectedShard := range shards {
+		shard, err := ioutil.ReadFile(partFile(path.Split(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(shard, expectedShard) {
+			t.Fatalf("%v: mismatch: shard differs after heal", name)
+		}
+	}
+
+	if results, err = erasureDisk.Heal(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range results {
+		if result.HealedParts != nil || result.Err != nil {
+			t.Fatalf("mismatch: expected: <healthy>, got: %+v", result)
+		}
+	}
+}
+
+func TestNewScrubbers(t *testing.T) {
+	id := xrand.NewID(8).String()
+	workDir := id
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	count := uint64(6)
+	shardDisks := make([]ShardDisk, count)
+	var err error
+	for j := uint64(0); j < count; j++ {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		shardDisks[j], err = disk.NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	erasureDisk := NewErasure(shardDisks, count)
+	erasureDisk.SetLayout(4, 2, 1024)
+
+	uploadID := disk.NewUploadID()
+	if err = erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFilename := disk.NewTempFilename()
+	if _, err = erasureDisk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = erasureDisk.UploadPart(uploadID, "1", tempFilename); err != nil {
+		t.Fatal(err)
+	}
+
+	dataID := disk.NewDataID()
+	if err = erasureDisk.CompleteUpload(dataID, uploadID, []disk.Part{{ID: "1", Size: 16279}}); err != nil {
+		t.Fatal(err)
+	}
+
+	partFile := path.Join(workDir, "d2", "data", dataID.String()[:2], dataID.String(), "1.part")
+	file, err := os.OpenFile(partFile, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.WriteAt([]byte{0, 0, 0, 0}, 100); err != nil {
+		file.Close()
+		t.Fatal(err)
+	}
+	file.Close()
+
+	for i, scrubber := range erasureDisk.NewScrubbers(0, true) {
+		if _, _, err = scrubber.Scrub(); err != nil {
+			t.Fatalf("d%v: %v", i, err)
+		}
+
+		if unrepaired := scrubber.Unrepaired(); len(unrepaired) != 0 {
+			t.Fatalf("d%v: mismatch: expected: <healed>, got: %v", i, unrepaired)
+		}
+	}
+
+	// Corrupt shard is healed by scrubber of d2.
+	results, err := erasureDisk.Heal(dataID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range results {
+		if result.HealedParts != nil || result.Err != nil {
+			t.Fatalf("mismatch: expected: <healthy>, got: %+v", result)
+		}
+	}
+}
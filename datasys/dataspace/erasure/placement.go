@@ -0,0 +1,71 @@
+package erasure
+
+import (
+	"fmt"
+	"hash/crc32"
+
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+)
+
+// Placement returns order of shard disk IDs for data of given key. i-th shard of erasure info is stored on i-th
+// returned shard disk, i.e. first DataCount shard disks hold data shards and the rest hold parity shards.
+type Placement func(key string, shardIDs []string) []string
+
+// OrderedPlacement places shards in order of shard disks, so that data shards always land on first shard disks.
+func OrderedPlacement(key string, shardIDs []string) []string {
+	return append([]string{}, shardIDs...)
+}
+
+// HashPlacement rotates order of shard disks by hash of key, so that data and parity roles, hence load and wear,
+// are spread evenly across shard disks. Same key always gets same order.
+func HashPlacement(key string, shardIDs []string) []string {
+	if len(shardIDs) == 0 {
+		return []string{}
+	}
+
+	offset := int(crc32.ChecksumIEEE([]byte(key)) % uint32(len(shardIDs)))
+	return append(append([]string{}, shardIDs[offset:]...), shardIDs[:offset]...)
+}
+
+// checkPlacement returns error if shardIDs is not an order of all shard disks.
+func (ds *Erasure) checkPlacement(shardIDs []string) error {
+	if len(shardIDs) != len(ds.shardDisks) {
+		return fmt.Errorf("shard IDs %v do not match shard disks", shardIDs)
+	}
+
+	found := make(map[string]bool)
+	for i := range ds.shardDisks {
+		found[ds.shardDisks[i].ID()] = false
+	}
+
+	for _, shardID := range shardIDs {
+		if done, ok := found[shardID]; !ok || done {
+			return fmt.Errorf("shard IDs %v do not match shard disks", shardIDs)
+		}
+		found[shardID] = true
+	}
+
+	return nil
+}
+
+// ShardIDs returns order of shard disk IDs by placement for data of given data ID. It may be set to
+// info.ShardIDs before SaveTempFileWithInfo() by caller knowing data ID before upload.
+func (ds *Erasure) ShardIDs(dataID disk.DataID) []string {
+	return ds.placeShards(dataID.String())
+}
+
+// UploadShardIDs returns order of shard disk IDs by placement for parts of given upload ID. As data ID is not
+// known until CompleteUpload(), placement of an object is keyed by its upload ID; setting it to info.ShardIDs
+// before SaveTempFileWithInfo() of each part places all parts of the object alike.
+func (ds *Erasure) UploadShardIDs(uploadID disk.UploadID) []string {
+	return ds.placeShards(uploadID.String())
+}
+
+func (ds *Erasure) placeShards(key string) []string {
+	shardIDs := make([]string, len(ds.shardDisks))
+	for i := range ds.shardDisks {
+		shardIDs[i] = ds.shardDisks[i].ID()
+	}
+
+	return ds.placement(key, shardIDs)
+}
@@ -0,0 +1,237 @@
+package erasure
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	"github.com/balamurugana/goat/pkg/erasure"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestHashPlacement(t *testing.T) {
+	shardIDs := []string{"d0", "d1", "d2", "d3", "d4", "d5"}
+
+	firstShardCounts := make(map[string]int)
+	for i := 0; i < 600; i++ {
+		key := disk.NewDataID().String()
+		placed := HashPlacement(key, shardIDs)
+		if !reflect.DeepEqual(placed, HashPlacement(key, shardIDs)) {
+			t.Fatalf("%v: mismatch: expected: <same order>, got: %v", key, HashPlacement(key, shardIDs))
+		}
+
+		// Placement is a rotation of shard disks.
+		offset := 0
+		for offset < len(shardIDs) && shardIDs[offset] != placed[0] {
+			offset++
+		}
+		expected := append(append([]string{}, shardIDs[offset:]...), shardIDs[:offset]...)
+		if !reflect.DeepEqual(placed, expected) {
+			t.Fatalf("%v: mismatch: expected: %v, got: %v", key, expected, placed)
+		}
+
+		firstShardCounts[placed[0]]++
+	}
+
+	for _, shardID := range shardIDs {
+		if firstShardCounts[shardID] < 50 {
+			t.Fatalf("%v: mismatch: first shard count: expected: >= 50, got: %v", shardID, firstShardCounts[shardID])
+		}
+	}
+
+	if placed := OrderedPlacement("any", shardIDs); !reflect.DeepEqual(placed, shardIDs) {
+		t.Fatalf("mismatch: expected: %v, got: %v", shardIDs, placed)
+	}
+}
+
+func TestShardIDs(t *testing.T) {
+	id := xrand.NewID(8).String()
+	workDir := id
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	count := uint64(6)
+	shardDisks := make([]ShardDisk, count)
+	var err error
+	for j := uint64(0); j < count; j++ {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		shardDisks[j], err = disk.NewDisk(id, dataDir)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	erasureDisk := NewErasure(shardDisks, count)
+
+	testCases := []struct {
+		shardIDs  []string
+		expectErr bool
+	}{
+		{nil, false},
+		{erasureDisk.ShardIDs(disk.NewDataID()), false},
+		{[]string{"d5", "d4", "d3", "d2", "d1", "d0"}, false},
+		{[]string{"d0", "d1", "d2", "d3", "d4"}, true},
+		{[]string{"d0", "d1", "d2", "d3", "d4", "d4"}, true},
+		{[]string{"d0", "d1", "d2", "d3", "d4", "d6"}, true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				tempFilename := disk.NewTempFilename()
+				info := &erasure.Info{
+					DataCount:   4,
+					ParityCount: 2,
+					Size:        16279,
+					ShardSize:   4096,
+					ShardIDs:    testCase.shardIDs,
+				}
+
+				_, err := erasureDisk.SaveTempFileWithInfo(tempFilename, randReader(), true, info)
+				if testCase.expectErr {
+					if err == nil {
+						t.Fatalf("expected: <error>, got: <nil>")
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer erasureDisk.RemoveTempFile(tempFilename, true)
+
+				expected := testCase.shardIDs
+				if expected == nil {
+					expected = HashPlacement(tempFilename, []string{"d0", "d1", "d2", "d3", "d4", "d5"})
+				}
+
+				if !reflect.DeepEqual(info.ShardIDs, expected) {
+					t.Fatalf("mismatch: expected: %v, got: %v", expected, info.ShardIDs)
+				}
+
+				uploadID := disk.NewUploadID()
+				if err = erasureDisk.InitUpload(uploadID); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = erasureDisk.UploadPart(uploadID, "1", tempFilename); err != nil {
+					t.Fatal(err)
+				}
+
+				dataID := disk.NewDataID()
+				if err = erasureDisk.CompleteUpload(dataID, uploadID, []disk.Part{{ID: "1", Size: 16279}}); err != nil {
+					t.Fatal(err)
+				}
+
+				dataInfo, _, err := erasureDisk.GetMetadataWithInfo(dataID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(dataInfo.Parts[0].ShardIDs, expected) {
+					t.Fatalf("mismatch: expected: %v, got: %v", expected, dataInfo.Parts[0].ShardIDs)
+				}
+
+				// Stored order is honoured by read path even after placement is changed.
+				erasureDisk.SetPlacement(OrderedPlacement)
+				defer erasureDisk.SetPlacement(HashPlacement)
+
+				rc, err := erasureDisk.Get(dataID, 0, 16279)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer rc.Close()
+
+				data, err := ioutil.ReadAll(rc)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				expectedData, err := ioutil.ReadAll(io.LimitReader(randReader(), 16279))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(data, expectedData) {
+					t.Fatalf("mismatch: data differs")
+				}
+			},
+		)
+	}
+}
+
+func TestUploadShardIDs(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	erasureDisk := NewErasure(newTestDisks(t, workDir, 6), 6)
+	erasureDisk.SetLayout(4, 2, 4096)
+
+	srcUploadID := disk.NewUploadID()
+	if err := erasureDisk.InitUpload(srcUploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFilename := disk.NewTempFilename()
+	if _, err := erasureDisk.SaveTempFile(tempFilename, randReader(), 16279, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := erasureDisk.UploadPart(srcUploadID, "1", tempFilename); err != nil {
+		t.Fatal(err)
+	}
+
+	srcID := disk.NewDataID()
+	if err := erasureDisk.CompleteUpload(srcID, srcUploadID, []disk.Part{{ID: "1", Size: 16279}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Parts copied into an upload are placed alike by its upload ID.
+	uploadID := disk.NewUploadID()
+	if err := erasureDisk.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, partID := range []string{"1", "2", "3"} {
+		if _, err := erasureDisk.UploadPartCopy(uploadID, partID, srcID, int64(i*4096), 4096); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parts, _, err := erasureDisk.ListPartsWithInfo(uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := erasureDisk.UploadShardIDs(uploadID)
+	for _, part := range parts {
+		if !reflect.DeepEqual(part.ShardIDs, expected) {
+			t.Fatalf("%v: mismatch: expected: %v, got: %v", part.ID, expected, part.ShardIDs)
+		}
+	}
+}
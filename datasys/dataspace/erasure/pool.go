@@ -0,0 +1,417 @@
+package erasure
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"sort"
+
+	"github.com/balamurugana/goat/datasys/dataspace"
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	"github.com/balamurugana/goat/pkg/boundary"
+	"github.com/balamurugana/goat/pkg/erasure"
+)
+
+// ringReplicas is number of points of each erasure set on hash ring.
+const ringReplicas = 128
+
+type ringPoint struct {
+	hash     uint32
+	setIndex int
+}
+
+// Pool groups disks into fixed-size erasure sets and routes each temporary file, upload and data to a set by
+// consistent hashing of its filename, upload ID and data ID respectively. Pool implements DataSpace; when an
+// upload and its temporary file or data ID are routed to different sets, data is moved across sets.
+type Pool struct {
+	sets []*Erasure
+	ring []ringPoint
+}
+
+// NewPool returns pool of erasure sets of setSize disks each. Disk position in deployment is assigned to, or
+// verified against, format of each disk by disk.SetPosition().
+func NewPool(deploymentID string, disks []ShardDisk, setSize int, minSuccess uint64) (*Pool, error) {
+	if setSize <= 0 || len(disks) == 0 || len(disks)%setSize != 0 {
+		return nil, fmt.Errorf("%v disks can not be grouped into sets of %v disks", len(disks), setSize)
+	}
+
+	pool := &Pool{
+		sets: make([]*Erasure, len(disks)/setSize),
+	}
+
+	for i := range pool.sets {
+		setDisks := disks[i*setSize : (i+1)*setSize]
+		for j := range setDisks {
+			if err := setDisks[j].SetPosition(deploymentID, i, j); err != nil {
+				return nil, err
+			}
+		}
+
+		pool.sets[i] = NewErasure(setDisks, minSuccess)
+
+		for r := 0; r < ringReplicas; r++ {
+			pool.ring = append(pool.ring, ringPoint{
+				hash:     crc32.ChecksumIEEE([]byte(fmt.Sprintf("%v/%v/%v", deploymentID, i, r))),
+				setIndex: i,
+			})
+		}
+	}
+
+	sort.Slice(pool.ring, func(i, j int) bool {
+		return pool.ring[i].hash < pool.ring[j].hash
+	})
+
+	return pool, nil
+}
+
+// Sets returns erasure sets of the pool, e.g. to heal or scrub them.
+func (pool *Pool) Sets() []*Erasure {
+	return pool.sets
+}
+
+// SetLayout sets erasure layout of every erasure set.
+func (pool *Pool) SetLayout(dataCount, parityCount, shardSize uint64) {
+	for _, set := range pool.sets {
+		set.SetLayout(dataCount, parityCount, shardSize)
+	}
+}
+
+// SetPlacement sets shard placement of every erasure set.
+func (pool *Pool) SetPlacement(placement Placement) {
+	for _, set := range pool.sets {
+		set.SetPlacement(placement)
+	}
+}
+
+// SetStorageClasses sets storage classes of every erasure set.
+func (pool *Pool) SetStorageClasses(classes map[string]StorageClass) error {
+	for _, set := range pool.sets {
+		if err := set.SetStorageClasses(classes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetSetIndex returns index of erasure set of given key by consistent hashing.
+func (pool *Pool) GetSetIndex(key string) int {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(pool.ring), func(i int) bool {
+		return pool.ring[i].hash >= hash
+	})
+
+	if i == len(pool.ring) {
+		i = 0
+	}
+
+	return pool.ring[i].setIndex
+}
+
+func (pool *Pool) getSet(key string) *Erasure {
+	return pool.sets[pool.GetSetIndex(key)]
+}
+
+// onDataSet calls fn with erasure set of given data ID. If data is not found there, e.g. it was stored before
+// sets were added or moving it across sets failed, other sets are tried in order.
+func (pool *Pool) onDataSet(dataID disk.DataID, fn func(set *Erasure) error) (*Erasure, error) {
+	index := pool.GetSetIndex(dataID.String())
+	err := fn(pool.sets[index])
+	if !errors.Is(err, xerrors.ErrDataIDNotFound) {
+		return pool.sets[index], err
+	}
+
+	for i := range pool.sets {
+		if i != index {
+			if ferr := fn(pool.sets[i]); !errors.Is(ferr, xerrors.ErrDataIDNotFound) {
+				return pool.sets[i], ferr
+			}
+		}
+	}
+
+	return nil, err
+}
+
+// NewDataID returns new data ID routed to same erasure set of given upload ID so that CompleteUpload() does not
+// move data across sets.
+func (pool *Pool) NewDataID(uploadID disk.UploadID) disk.DataID {
+	index := pool.GetSetIndex(uploadID.String())
+	for {
+		if dataID := disk.NewDataID(); pool.GetSetIndex(dataID.String()) == index {
+			return dataID
+		}
+	}
+}
+
+// NewTempFilename returns new temporary filename routed to same erasure set of given upload ID so that
+// UploadPart() does not move temporary file across sets.
+func (pool *Pool) NewTempFilename(uploadID disk.UploadID) string {
+	index := pool.GetSetIndex(uploadID.String())
+	for {
+		if filename := disk.NewTempFilename(); pool.GetSetIndex(filename) == index {
+			return filename
+		}
+	}
+}
+
+func (pool *Pool) SaveTempFile(filename string, data io.Reader, size uint64, bitrotProtection bool) (checksum string, err error) {
+	return pool.getSet(filename).SaveTempFile(filename, data, size, bitrotProtection)
+}
+
+// SaveTempFileWithClass erasure encodes data by erasure profile of given storage class into temporary file.
+func (pool *Pool) SaveTempFileWithClass(filename string, data io.Reader, size uint64, bitrotProtection bool, storageClass string) (checksum string, err error) {
+	return pool.getSet(filename).SaveTempFileWithClass(filename, data, size, bitrotProtection, storageClass)
+}
+
+func (pool *Pool) RemoveTempFile(filename string, bitrotProtection bool) error {
+	return pool.getSet(filename).RemoveTempFile(filename, bitrotProtection)
+}
+
+func (pool *Pool) InitUpload(uploadID disk.UploadID) error {
+	return pool.getSet(uploadID.String()).InitUpload(uploadID)
+}
+
+// UploadPart moves temporary file as part of upload. Temporary file in other erasure set is decoded and saved
+// into set of upload first; it is removed from its set only after part is uploaded.
+func (pool *Pool) UploadPart(uploadID disk.UploadID, partID, tempFile string) error {
+	src := pool.getSet(tempFile)
+	dst := pool.getSet(uploadID.String())
+	if src == dst {
+		return dst.UploadPart(uploadID, partID, tempFile)
+	}
+
+	reader, info, closeShardReaders, err := src.getTempFile(tempFile)
+	if err != nil {
+		return err
+	}
+
+	layout := &erasure.Info{
+		DataCount:   info.DataCount,
+		ParityCount: info.ParityCount,
+		Size:        info.Size,
+		ShardSize:   info.ShardSize,
+		ShardIDs:    dst.UploadShardIDs(uploadID),
+	}
+	_, err = dst.SaveTempFileWithInfo(tempFile, reader, true, layout)
+	closeShardReaders()
+	if err != nil {
+		dst.RemoveTempFile(tempFile, true)
+		return err
+	}
+
+	if err = dst.UploadPart(uploadID, partID, tempFile); err != nil {
+		dst.RemoveTempFile(tempFile, true)
+		return err
+	}
+
+	src.RemoveTempFile(tempFile, true)
+	return nil
+}
+
+// UploadPartCopy copies given range of data of srcID as part of upload. Data in other erasure set is read and
+// erasure encoded into set of upload.
+func (pool *Pool) UploadPartCopy(uploadID disk.UploadID, partID string, srcID disk.DataID, offset int64, length uint64) (etag string, err error) {
+	dst := pool.getSet(uploadID.String())
+	var srcDataInfo *DataInfo
+	src, err := pool.onDataSet(srcID, func(set *Erasure) (err error) {
+		srcDataInfo, _, err = set.GetMetadataWithInfo(srcID)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if src == dst {
+		return dst.UploadPartCopyWithInfo(uploadID, partID, srcID, srcDataInfo, offset, length, dst.rangeInfo(srcDataInfo, offset, length))
+	}
+
+	return pool.transferPart(src, srcID, dst, uploadID, partID, offset, dst.rangeInfo(srcDataInfo, offset, length))
+}
+
+func (pool *Pool) AbortUpload(uploadID disk.UploadID) error {
+	return pool.getSet(uploadID.String()).AbortUpload(uploadID)
+}
+
+// CompleteUpload makes given parts of upload available as data of dataID. If dataID is routed to other erasure
+// set than upload, upload is completed under temporary data ID and moved to set of dataID. If moving fails, the
+// upload is restored so that CompleteUpload() can be retried or upload aborted, and the error is returned.
+// Failure to remove temporary data ID is logged only; deleted data is removed by trash reaper of shard disks.
+func (pool *Pool) CompleteUpload(dataID disk.DataID, uploadID disk.UploadID, parts []dataspace.Part) error {
+	src := pool.getSet(uploadID.String())
+	dst := pool.getSet(dataID.String())
+	if src == dst {
+		return dst.CompleteUpload(dataID, uploadID, parts)
+	}
+
+	tempID := disk.NewDataID()
+	if err := src.CompleteUpload(tempID, uploadID, parts); err != nil {
+		return err
+	}
+
+	size := uint64(0)
+	for _, part := range parts {
+		size += part.Size
+	}
+
+	if err := pool.transfer(src, tempID, dst, dataID, 0, size); err != nil {
+		if rerr := src.revertCompleteUpload(tempID, uploadID); rerr != nil {
+			log.Println("Pool.CompleteUpload(): unable to restore upload", uploadID, "from", tempID, rerr)
+			if derr := src.Delete(tempID); derr != nil {
+				log.Println("Pool.CompleteUpload(): unable to delete", tempID, derr)
+			}
+		}
+
+		return err
+	}
+
+	if err := src.Delete(tempID); err != nil {
+		log.Println("Pool.CompleteUpload(): unable to delete", tempID, err)
+	}
+
+	return nil
+}
+
+func (pool *Pool) ListParts(uploadID disk.UploadID) ([]dataspace.Part, error) {
+	return pool.getSet(uploadID.String()).ListParts(uploadID)
+}
+
+func (pool *Pool) Get(dataID disk.DataID, offset int64, length uint64) (rc disk.DataReader, err error) {
+	_, err = pool.onDataSet(dataID, func(set *Erasure) error {
+		rc, err = set.Get(dataID, offset, length)
+		return err
+	})
+
+	return rc, err
+}
+
+func (pool *Pool) GetMetadata(dataID disk.DataID) (dataInfo *dataspace.DataInfo, err error) {
+	_, err = pool.onDataSet(dataID, func(set *Erasure) error {
+		dataInfo, err = set.GetMetadata(dataID)
+		return err
+	})
+
+	return dataInfo, err
+}
+
+func (pool *Pool) Delete(dataID disk.DataID) error {
+	set, err := pool.onDataSet(dataID, func(set *Erasure) error {
+		_, err := set.GetMetadata(dataID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return set.Delete(dataID)
+}
+
+// Copy creates data of dataID from given range of data of srcID. Within an erasure set, Erasure.Copy() is used;
+// else data is read and erasure encoded into set of dataID.
+func (pool *Pool) Copy(dataID, srcID disk.DataID, offset int64, length uint64) error {
+	dst := pool.getSet(dataID.String())
+	src, err := pool.onDataSet(srcID, func(set *Erasure) error {
+		_, err := set.GetMetadata(srcID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if src == dst {
+		return dst.Copy(dataID, srcID, offset, length)
+	}
+
+	return pool.transfer(src, srcID, dst, dataID, offset, length)
+}
+
+// transfer creates data of dataID in dst set from given range of data of srcID in src set. Like Erasure.Copy(),
+// whole parts in the range are kept as parts; else the range becomes single part.
+func (pool *Pool) transfer(src *Erasure, srcID disk.DataID, dst *Erasure, dataID disk.DataID, offset int64, length uint64) error {
+	dataInfo, _, err := src.GetMetadataWithInfo(srcID)
+	if err != nil {
+		return err
+	}
+
+	if offset < 0 || uint64(offset)+length > dataInfo.Size {
+		return errors.New("insufficient data")
+	}
+
+	partSizes := make([]int64, len(dataInfo.Parts))
+	for i, part := range dataInfo.Parts {
+		partSizes[i] = int64(part.Size)
+	}
+
+	var startPart, endPart, bytesToSkip, bytesToRead int64
+	if length > 0 {
+		startPart, endPart, bytesToSkip, bytesToRead = boundary.CalcPartBoundaries(partSizes, offset, int64(length))
+	}
+
+	// Whole parts keep their erasure layout, e.g. of storage class.
+	parts := []dataspace.Part{{ID: "1", Size: length}}
+	infos := []*erasure.Info{dst.rangeInfo(dataInfo, offset, length)}
+	if endPart != startPart && bytesToSkip == 0 && bytesToRead == partSizes[endPart-1] {
+		parts = make([]dataspace.Part, endPart-startPart)
+		infos = make([]*erasure.Info, endPart-startPart)
+		for i := range parts {
+			part := dataInfo.Parts[startPart+int64(i)]
+			parts[i] = dataspace.Part{ID: part.ID, Size: part.Size}
+			infos[i] = &erasure.Info{DataCount: part.DataCount, ParityCount: part.ParityCount, Size: part.Size, ShardSize: part.ShardSize}
+		}
+	}
+
+	// Data ID is known, hence parts are placed by it like Copy().
+	for i := range infos {
+		infos[i].ShardIDs = dst.ShardIDs(dataID)
+	}
+
+	uploadID := disk.NewUploadID()
+	if err = dst.InitUpload(uploadID); err != nil {
+		return err
+	}
+
+	for i, part := range parts {
+		if _, err = pool.transferPart(src, srcID, dst, uploadID, part.ID, offset, infos[i]); err != nil {
+			dst.AbortUpload(uploadID)
+			return err
+		}
+
+		offset += int64(part.Size)
+	}
+
+	if err = dst.CompleteUpload(dataID, uploadID, parts); err != nil {
+		dst.AbortUpload(uploadID)
+	}
+
+	return err
+}
+
+// transferPart uploads info.Size bytes of data of srcID at offset in src set as part of upload in dst set. Part is
+// erasure encoded by info and placed by upload ID unless info.ShardIDs is set.
+func (pool *Pool) transferPart(src *Erasure, srcID disk.DataID, dst *Erasure, uploadID disk.UploadID, partID string, offset int64, info *erasure.Info) (etag string, err error) {
+	rc, err := src.Get(srcID, offset, info.Size)
+	if err != nil {
+		return "", err
+	}
+
+	defer rc.Close()
+
+	tempFile := disk.NewTempFilename()
+	if len(info.ShardIDs) == 0 {
+		info.ShardIDs = dst.UploadShardIDs(uploadID)
+	}
+
+	if etag, err = dst.SaveTempFileWithInfo(tempFile, rc, true, info); err != nil {
+		dst.RemoveTempFile(tempFile, true)
+		return "", err
+	}
+
+	if err = dst.UploadPart(uploadID, partID, tempFile); err != nil {
+		dst.RemoveTempFile(tempFile, true)
+		return "", err
+	}
+
+	return etag, nil
+}
@@ -0,0 +1,414 @@
+package erasure
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/balamurugana/goat/datasys/dataspace"
+	"github.com/balamurugana/goat/datasys/dataspace/dataspacetest"
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	xhash "github.com/balamurugana/goat/pkg/hash"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func newTestDisks(t *testing.T, workDir string, count int) []ShardDisk {
+	disks := make([]ShardDisk, count)
+	for j := range disks {
+		id := fmt.Sprintf("d%v", j)
+		dataDir := path.Join(workDir, id)
+		if err := os.Mkdir(dataDir, os.ModePerm); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+
+		var err error
+		if disks[j], err = disk.NewDisk(id, dataDir); err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+	}
+
+	return disks
+}
+
+func checksumData(t *testing.T, ds dataspace.DataSpace, dataID disk.DataID, offset int64, length uint64) string {
+	rc, err := ds.Get(dataID, offset, length)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rc.Close()
+
+	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+	if _, err = io.Copy(hasher, rc); err != nil {
+		t.Fatal(err)
+	}
+
+	return hasher.HexSum(nil)
+}
+
+func TestPoolDataSpace(t *testing.T) {
+	dataspacetest.Run(t, func(t *testing.T) (dataspace.DataSpace, func()) {
+		workDir := xrand.NewID(8).String()
+		if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		pool, err := NewPool("deployment", newTestDisks(t, workDir, 12), 6, 6)
+		if err != nil {
+			os.RemoveAll(workDir)
+			t.Fatal(err)
+		}
+		pool.SetLayout(4, 2, 4096)
+
+		return pool, func() {
+			os.RemoveAll(workDir)
+		}
+	})
+}
+
+func TestNewPool(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	disks := newTestDisks(t, workDir, 12)
+
+	for _, setSize := range []int{0, 5, 24} {
+		if _, err := NewPool("deployment", disks, setSize, 4); err == nil {
+			t.Fatalf("set size %v: expected: <error>, got: <nil>", setSize)
+		}
+	}
+
+	pool, err := NewPool("deployment", disks, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pool.Sets()) != 3 {
+		t.Fatalf("mismatch: sets: expected: 3, got: %v", len(pool.Sets()))
+	}
+
+	for j, d := range disks {
+		if format := d.(*disk.Disk).Format(); format.SetIndex != j/4 || format.DiskIndex != j%4 {
+			t.Fatalf("%v: mismatch: expected: %v/%v, got: %v/%v", d.ID(), j/4, j%4, format.SetIndex, format.DiskIndex)
+		}
+	}
+
+	if _, err = NewPool("deployment", disks, 6, 6); !errors.Is(err, xerrors.ErrDiskPositionMismatch) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDiskPositionMismatch, err)
+	}
+
+	counts := make([]int, len(pool.Sets()))
+	for i := 0; i < 3000; i++ {
+		dataID := disk.NewDataID()
+		index := pool.GetSetIndex(dataID.String())
+		if index != pool.GetSetIndex(dataID.String()) {
+			t.Fatalf("%v: mismatch: expected: <same set>, got: <different set>", dataID)
+		}
+		counts[index]++
+	}
+
+	for i, count := range counts {
+		if count < 500 {
+			t.Fatalf("set %v: mismatch: count: expected: >= 500, got: %v", i, count)
+		}
+	}
+}
+
+func TestPoolRouting(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	pool, err := NewPool("deployment", newTestDisks(t, workDir, 12), 6, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetLayout(4, 2, 4096)
+
+	checkData := func(dataID disk.DataID) {
+		expected := "6b3559a522b87e0a9bbe0b74ace31a83dedc0d46f3eee2b1aea0b88fb312f883"
+		if checksum := checksumData(t, pool, dataID, 12958, 10992); checksum != expected {
+			t.Fatalf("mismatch: expected: %v, got: %v", expected, checksum)
+		}
+	}
+
+	parts := []dataspace.Part{{ID: "3", Size: 16279}, {ID: "8", Size: 10992}}
+	upload := func(uploadID disk.UploadID, newTempFilename func() string) {
+		if err := pool.InitUpload(uploadID); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, part := range parts {
+			tempFile := newTempFilename()
+			if _, err := pool.SaveTempFile(tempFile, randReader(), part.Size, true); err != nil {
+				t.Fatalf("%v: %v", part.ID, err)
+			}
+
+			if err := pool.UploadPart(uploadID, part.ID, tempFile); err != nil {
+				t.Fatalf("%v: %v", part.ID, err)
+			}
+		}
+	}
+
+	t.Run("same-set", func(t *testing.T) {
+		uploadID := disk.NewUploadID()
+		upload(uploadID, func() string { return pool.NewTempFilename(uploadID) })
+
+		dataID := pool.NewDataID(uploadID)
+		if err := pool.CompleteUpload(dataID, uploadID, parts); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := pool.Sets()[pool.GetSetIndex(dataID.String())].GetMetadata(dataID); err != nil {
+			t.Fatal(err)
+		}
+
+		checkData(dataID)
+	})
+
+	t.Run("cross-set", func(t *testing.T) {
+		uploadID := disk.NewUploadID()
+		uploadSet := pool.GetSetIndex(uploadID.String())
+		otherSetFilename := func() string {
+			for {
+				if filename := disk.NewTempFilename(); pool.GetSetIndex(filename) != uploadSet {
+					return filename
+				}
+			}
+		}
+		upload(uploadID, otherSetFilename)
+
+		var dataID disk.DataID
+		for dataID = disk.NewDataID(); pool.GetSetIndex(dataID.String()) == uploadSet; dataID = disk.NewDataID() {
+		}
+
+		if err := pool.CompleteUpload(dataID, uploadID, parts); err != nil {
+			t.Fatal(err)
+		}
+
+		dataInfo, err := pool.Sets()[pool.GetSetIndex(dataID.String())].GetMetadata(dataID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(dataInfo.Parts) != 2 || dataInfo.Parts[0].ID != "3" || dataInfo.Parts[1].ID != "8" {
+			t.Fatalf("mismatch: parts: expected: %v, got: %v", parts, dataInfo.Parts)
+		}
+
+		if _, err = pool.Sets()[uploadSet].GetMetadata(dataID); !errors.Is(err, xerrors.ErrDataIDNotFound) {
+			t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+		}
+
+		checkData(dataID)
+
+		copyID := disk.NewDataID()
+		if err = pool.Copy(copyID, dataID, 0, 27271); err != nil {
+			t.Fatal(err)
+		}
+
+		checkData(copyID)
+	})
+
+	t.Run("unrouted-set", func(t *testing.T) {
+		// Data stored in other set than routed one, e.g. before sets were added, is still found.
+		dataID := disk.NewDataID()
+		set := pool.Sets()[(pool.GetSetIndex(dataID.String())+1)%len(pool.Sets())]
+
+		uploadID := disk.NewUploadID()
+		if err := set.InitUpload(uploadID); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, part := range parts {
+			tempFile := disk.NewTempFilename()
+			if _, err := set.SaveTempFile(tempFile, randReader(), part.Size, true); err != nil {
+				t.Fatalf("%v: %v", part.ID, err)
+			}
+
+			if err := set.UploadPart(uploadID, part.ID, tempFile); err != nil {
+				t.Fatalf("%v: %v", part.ID, err)
+			}
+		}
+
+		if err := set.CompleteUpload(dataID, uploadID, parts); err != nil {
+			t.Fatal(err)
+		}
+
+		checkData(dataID)
+
+		if err := pool.Delete(dataID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := pool.GetMetadata(dataID); !errors.Is(err, xerrors.ErrDataIDNotFound) {
+			t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+		}
+	})
+}
+
+// completeFailingDisk is shard disk failing CompleteUpload() while fail is set.
+type completeFailingDisk struct {
+	ShardDisk
+	fail bool
+}
+
+func (d *completeFailingDisk) CompleteUpload(dataID disk.DataID, uploadID disk.UploadID, parts []disk.Part) error {
+	if d.fail {
+		return errors.New("complete upload failed")
+	}
+
+	return d.ShardDisk.CompleteUpload(dataID, uploadID, parts)
+}
+
+func TestPoolCompleteUploadTransferFailure(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	disks := newTestDisks(t, workDir, 12)
+	failingDisks := make([]*completeFailingDisk, len(disks))
+	for j := range disks {
+		failingDisks[j] = &completeFailingDisk{ShardDisk: disks[j]}
+		disks[j] = failingDisks[j]
+	}
+
+	pool, err := NewPool("deployment", disks, 6, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetLayout(4, 2, 4096)
+
+	uploadID := disk.NewUploadID()
+	uploadSet := pool.GetSetIndex(uploadID.String())
+	if err = pool.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFile := pool.NewTempFilename(uploadID)
+	if _, err = pool.SaveTempFile(tempFile, randReader(), 16279, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.UploadPart(uploadID, "1", tempFile); err != nil {
+		t.Fatal(err)
+	}
+
+	var dataID disk.DataID
+	for dataID = disk.NewDataID(); pool.GetSetIndex(dataID.String()) == uploadSet; dataID = disk.NewDataID() {
+	}
+
+	dataSet := pool.GetSetIndex(dataID.String())
+	for j := dataSet * 6; j < (dataSet+1)*6; j++ {
+		failingDisks[j].fail = true
+	}
+
+	parts := []dataspace.Part{{ID: "1", Size: 16279}}
+	if err = pool.CompleteUpload(dataID, uploadID, parts); err == nil {
+		t.Fatalf("expected: <error>, got: <nil>")
+	}
+
+	// Data is neither in set of upload nor in set of data ID, no data is left in set of upload and no upload is left
+	// in set of data ID.
+	if _, err = pool.GetMetadata(dataID); !errors.Is(err, xerrors.ErrDataIDNotFound) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDataIDNotFound, err)
+	}
+
+	checkEmpty := func(set int, dir string) {
+		for j := set * 6; j < (set+1)*6; j++ {
+			indices, err := ioutil.ReadDir(path.Join(workDir, fmt.Sprintf("d%v", j), dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, index := range indices {
+				if names, err := ioutil.ReadDir(path.Join(workDir, fmt.Sprintf("d%v", j), dir, index.Name())); err != nil || len(names) != 0 {
+					t.Fatalf("d%v: %v: mismatch: expected: <empty>, got: %v, %v", j, dir, len(names), err)
+				}
+			}
+		}
+	}
+	checkEmpty(uploadSet, "data")
+	checkEmpty(dataSet, "uploads")
+
+	// Upload is restored and completed by retry.
+	for j := dataSet * 6; j < (dataSet+1)*6; j++ {
+		failingDisks[j].fail = false
+	}
+
+	if err = pool.CompleteUpload(dataID, uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = pool.Sets()[dataSet].GetMetadata(dataID); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"
+	if checksum := checksumData(t, pool, dataID, 0, 16279); checksum != expected {
+		t.Fatalf("mismatch: expected: %v, got: %v", expected, checksum)
+	}
+}
+
+func TestRemoteDataSpace(t *testing.T) {
+	dataspacetest.Run(t, func(t *testing.T) (dataspace.DataSpace, func()) {
+		workDir := xrand.NewID(8).String()
+		if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		// Disks are served by two nodes; each erasure set has shards on both of them.
+		localDisks := newTestDisks(t, workDir, 12)
+		nodeDisks := make([][]*disk.Disk, 2)
+		for j := range localDisks {
+			nodeDisks[j%2] = append(nodeDisks[j%2], localDisks[j].(*disk.Disk))
+		}
+
+		servers := make([]*httptest.Server, len(nodeDisks))
+		for i := range nodeDisks {
+			servers[i] = httptest.NewServer(disk.NewDiskRPCServer(nodeDisks[i]))
+		}
+
+		cleanup := func() {
+			for _, server := range servers {
+				server.Close()
+			}
+			os.RemoveAll(workDir)
+		}
+
+		disks := make([]ShardDisk, len(localDisks))
+		for j := range localDisks {
+			disks[j] = disk.NewRemoteDisk(localDisks[j].ID(), servers[j%2].URL, nil)
+		}
+
+		pool, err := NewPool("deployment", disks, 6, 6)
+		if err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+		pool.SetLayout(4, 2, 4096)
+
+		return pool, cleanup
+	})
+}
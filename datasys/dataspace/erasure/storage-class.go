@@ -0,0 +1,100 @@
+package erasure
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	"github.com/klauspost/reedsolomon"
+)
+
+// StandardStorageClass is storage class of data saved without storage class. Unless configured by
+// SetStorageClasses(), it uses layout set by SetLayout().
+const StandardStorageClass = "STANDARD"
+
+// StorageClass is erasure profile of a named storage class.
+type StorageClass struct {
+	DataCount   uint64
+	ParityCount uint64
+}
+
+func (class StorageClass) String() string {
+	return fmt.Sprintf("%v+%v", class.DataCount, class.ParityCount)
+}
+
+// ParseStorageClasses parses storage classes configured per deployment in the form of
+// "STANDARD=8+4,REDUCED_REDUNDANCY=10+2".
+func ParseStorageClasses(s string) (map[string]StorageClass, error) {
+	classes := make(map[string]StorageClass)
+	for _, token := range strings.Split(s, ",") {
+		if token = strings.TrimSpace(token); token == "" {
+			continue
+		}
+
+		tokens := strings.SplitN(token, "=", 2)
+		if len(tokens) != 2 || tokens[0] == "" {
+			return nil, fmt.Errorf("invalid storage class %v", token)
+		}
+
+		counts := strings.SplitN(tokens[1], "+", 2)
+		if len(counts) != 2 {
+			return nil, fmt.Errorf("invalid erasure profile %v of storage class %v", tokens[1], tokens[0])
+		}
+
+		dataCount, err := strconv.ParseUint(counts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data count of storage class %v; %v", tokens[0], err)
+		}
+
+		parityCount, err := strconv.ParseUint(counts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parity count of storage class %v; %v", tokens[0], err)
+		}
+
+		if _, found := classes[tokens[0]]; found {
+			return nil, fmt.Errorf("duplicate storage class %v", tokens[0])
+		}
+
+		classes[tokens[0]] = StorageClass{DataCount: dataCount, ParityCount: parityCount}
+	}
+
+	return classes, nil
+}
+
+// SetStorageClasses sets storage classes usable by SaveTempFileWithClass(). Data and parity count of each class
+// must add up to number of shard disks.
+func (ds *Erasure) SetStorageClasses(classes map[string]StorageClass) error {
+	for name, class := range classes {
+		if class.DataCount+class.ParityCount != uint64(len(ds.shardDisks)) {
+			return fmt.Errorf("storage class %v: %v does not match %v shard disks", name, class, len(ds.shardDisks))
+		}
+
+		if _, err := reedsolomon.New(int(class.DataCount), int(class.ParityCount)); err != nil {
+			return fmt.Errorf("storage class %v: %v", name, err)
+		}
+	}
+
+	ds.storageClasses = classes
+	return nil
+}
+
+// SaveTempFileWithClass erasure encodes data of given size by erasure profile of given storage class into
+// temporary file in each shard disk. Empty storage class is StandardStorageClass; storage class not configured
+// is ErrInvalidStorageClass.
+func (ds *Erasure) SaveTempFileWithClass(filename string, data io.Reader, size uint64, bitrotProtection bool, storageClass string) (checksum string, err error) {
+	if storageClass == "" {
+		storageClass = StandardStorageClass
+	}
+
+	info := ds.newInfo(size)
+	if class, found := ds.storageClasses[storageClass]; found {
+		info.DataCount = class.DataCount
+		info.ParityCount = class.ParityCount
+	} else if storageClass != StandardStorageClass {
+		return "", xerrors.ErrInvalidStorageClass
+	}
+
+	return ds.SaveTempFileWithInfo(filename, data, bitrotProtection, info)
+}
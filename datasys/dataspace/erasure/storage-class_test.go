@@ -0,0 +1,239 @@
+package erasure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/balamurugana/goat/datasys/dataspace"
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestParseStorageClasses(t *testing.T) {
+	testCases := []struct {
+		s         string
+		classes   map[string]StorageClass
+		expectErr bool
+	}{
+		{"", map[string]StorageClass{}, false},
+		{"STANDARD=8+4", map[string]StorageClass{"STANDARD": {8, 4}}, false},
+		{"STANDARD=8+4, REDUCED_REDUNDANCY=10+2", map[string]StorageClass{"STANDARD": {8, 4}, "REDUCED_REDUNDANCY": {10, 2}}, false},
+		{"STANDARD", nil, true},
+		{"=8+4", nil, true},
+		{"STANDARD=8", nil, true},
+		{"STANDARD=a+4", nil, true},
+		{"STANDARD=8+-4", nil, true},
+		{"STANDARD=8+4,STANDARD=10+2", nil, true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				classes, err := ParseStorageClasses(testCase.s)
+				if testCase.expectErr {
+					if err == nil {
+						t.Fatalf("expected: <error>, got: <nil>")
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(classes, testCase.classes) {
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.classes, classes)
+				}
+			},
+		)
+	}
+}
+
+func TestSaveTempFileWithClass(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	pool, err := NewPool("deployment", newTestDisks(t, workDir, 12), 6, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetLayout(3, 3, 4096)
+
+	if err = pool.SetStorageClasses(map[string]StorageClass{"STANDARD": {4, 4}}); err == nil {
+		t.Fatalf("expected: <error>, got: <nil>")
+	}
+
+	classes, err := ParseStorageClasses("STANDARD=4+2,REDUCED_REDUNDANCY=5+1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.SetStorageClasses(classes); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		storageClass string
+		dataCount    uint64
+		parityCount  uint64
+		err          error
+	}{
+		{"", 4, 2, nil},
+		{"STANDARD", 4, 2, nil},
+		{"REDUCED_REDUNDANCY", 5, 1, nil},
+		{"GLACIER", 0, 0, xerrors.ErrInvalidStorageClass},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				uploadID := disk.NewUploadID()
+				if err := pool.InitUpload(uploadID); err != nil {
+					t.Fatal(err)
+				}
+
+				// Temporary file in other set than upload keeps its profile when moved.
+				uploadSet := pool.GetSetIndex(uploadID.String())
+				tempFile := disk.NewTempFilename()
+				for pool.GetSetIndex(tempFile) == uploadSet {
+					tempFile = disk.NewTempFilename()
+				}
+
+				_, err := pool.SaveTempFileWithClass(tempFile, randReader(), 16279, true, testCase.storageClass)
+				if !errors.Is(err, testCase.err) {
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.err, err)
+				}
+
+				if err != nil {
+					return
+				}
+
+				if err = pool.UploadPart(uploadID, "1", tempFile); err != nil {
+					t.Fatal(err)
+				}
+
+				dataID := pool.NewDataID(uploadID)
+				if err = pool.CompleteUpload(dataID, uploadID, []dataspace.Part{{ID: "1", Size: 16279}}); err != nil {
+					t.Fatal(err)
+				}
+
+				dataInfo, _, err := pool.Sets()[uploadSet].GetMetadataWithInfo(dataID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				part := dataInfo.Parts[0]
+				if part.DataCount != testCase.dataCount || part.ParityCount != testCase.parityCount {
+					t.Fatalf("mismatch: expected: %v+%v, got: %v+%v", testCase.dataCount, testCase.parityCount, part.DataCount, part.ParityCount)
+				}
+
+				checksum := checksumData(t, pool, dataID, 0, 16279)
+				if expected := "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"; checksum != expected {
+					t.Fatalf("mismatch: expected: %v, got: %v", expected, checksum)
+				}
+			},
+		)
+	}
+}
+
+func TestCopyRangeWithClass(t *testing.T) {
+	workDir := xrand.NewID(8).String()
+	if err := os.Mkdir(workDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(workDir)
+	}()
+
+	pool, err := NewPool("deployment", newTestDisks(t, workDir, 12), 6, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetLayout(4, 2, 4096)
+
+	if err = pool.SetStorageClasses(map[string]StorageClass{"REDUCED_REDUNDANCY": {5, 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	uploadID := disk.NewUploadID()
+	if err = pool.InitUpload(uploadID); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFile := disk.NewTempFilename()
+	if _, err = pool.SaveTempFileWithClass(tempFile, randReader(), 16279, true, "REDUCED_REDUNDANCY"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.UploadPart(uploadID, "1", tempFile); err != nil {
+		t.Fatal(err)
+	}
+
+	srcID := pool.NewDataID(uploadID)
+	if err = pool.CompleteUpload(srcID, uploadID, []dataspace.Part{{ID: "1", Size: 16279}}); err != nil {
+		t.Fatal(err)
+	}
+
+	srcSet := pool.GetSetIndex(srcID.String())
+	expectedChecksum := checksumData(t, pool, srcID, 100, 1000)
+
+	checkPart := func(dataInfo *DataInfo) {
+		if part := dataInfo.Parts[0]; part.DataCount != 5 || part.ParityCount != 1 {
+			t.Fatalf("mismatch: expected: 5+1, got: %v+%v", part.DataCount, part.ParityCount)
+		}
+	}
+
+	// Partial range copied within set of source and into other set keeps storage class of source.
+	for _, sameSet := range []bool{true, false} {
+		dataID := disk.NewDataID()
+		for (pool.GetSetIndex(dataID.String()) == srcSet) != sameSet {
+			dataID = disk.NewDataID()
+		}
+
+		if err = pool.Copy(dataID, srcID, 100, 1000); err != nil {
+			t.Fatal(err)
+		}
+
+		dataInfo, _, err := pool.Sets()[pool.GetSetIndex(dataID.String())].GetMetadataWithInfo(dataID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPart(dataInfo)
+
+		if checksum := checksumData(t, pool, dataID, 0, 1000); checksum != expectedChecksum {
+			t.Fatalf("mismatch: expected: %v, got: %v", expectedChecksum, checksum)
+		}
+
+		copyUploadID := disk.NewUploadID()
+		for (pool.GetSetIndex(copyUploadID.String()) == srcSet) != sameSet {
+			copyUploadID = disk.NewUploadID()
+		}
+
+		if err = pool.InitUpload(copyUploadID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = pool.UploadPartCopy(copyUploadID, "1", srcID, 100, 1000); err != nil {
+			t.Fatal(err)
+		}
+
+		parts, _, err := pool.Sets()[pool.GetSetIndex(copyUploadID.String())].ListPartsWithInfo(copyUploadID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPart(&DataInfo{Parts: parts})
+	}
+}
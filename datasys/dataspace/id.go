@@ -0,0 +1,70 @@
+package dataspace
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/balamurugana/goat/pkg/rand"
+)
+
+// idLength is number of random bytes of temporary filenames, data and upload IDs.
+const idLength = 128
+
+// parseID returns ID of given string which must be created by rand.NewID(idLength). IDs are received from
+// network and used in paths, hence IDs of other length are rejected.
+func parseID(s string) (*rand.ID, error) {
+	if len(s) != base64.RawURLEncoding.EncodedLen(idLength) {
+		return nil, fmt.Errorf("invalid ID length %v", len(s))
+	}
+
+	return rand.ParseID(s)
+}
+
+// NewTempFilename returns new temporary filename.
+func NewTempFilename() string {
+	return rand.NewID(idLength).String()
+}
+
+type DataID struct {
+	*rand.ID
+}
+
+func NewDataID() DataID {
+	return DataID{rand.NewID(idLength)}
+}
+
+// ParseDataID returns data ID of given string.
+func ParseDataID(s string) (DataID, error) {
+	id, err := parseID(s)
+	if err != nil {
+		return DataID{}, err
+	}
+
+	return DataID{id}, nil
+}
+
+type UploadID struct {
+	*rand.ID
+}
+
+func NewUploadID() UploadID {
+	return UploadID{rand.NewID(idLength)}
+}
+
+// ParseUploadID returns upload ID of given string.
+func ParseUploadID(s string) (UploadID, error) {
+	id, err := parseID(s)
+	if err != nil {
+		return UploadID{}, err
+	}
+
+	return UploadID{id}, nil
+}
+
+type VersionID struct {
+	*rand.ID
+}
+
+func NewVersionID() VersionID {
+	return VersionID{rand.NewID(idLength)}
+}
@@ -0,0 +1,39 @@
+package dataspace
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseID(t *testing.T) {
+	dataID := NewDataID()
+	uploadID := NewUploadID()
+
+	testCases := []struct {
+		s         string
+		expectErr bool
+	}{
+		{dataID.String(), false},
+		{uploadID.String(), false},
+		{"", true},
+		{"a", true},
+		{dataID.String()[:170], true},
+		{dataID.String() + "A", true},
+		{dataID.String()[:169] + "/.", true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				if _, err := ParseDataID(testCase.s); (err != nil) != testCase.expectErr {
+					t.Fatalf("ParseDataID: mismatch: expectErr: %v, got: %v", testCase.expectErr, err)
+				}
+
+				if _, err := ParseUploadID(testCase.s); (err != nil) != testCase.expectErr {
+					t.Fatalf("ParseUploadID: mismatch: expectErr: %v, got: %v", testCase.expectErr, err)
+				}
+			},
+		)
+	}
+}
@@ -9,6 +9,9 @@ var (
 	ErrPartNotFound         = errors.New("part file not found")
 	ErrDataIDAlreadyExist   = errors.New("data ID already exist")
 	ErrDataIDNotFound       = errors.New("data ID not found")
+	ErrShardMismatch        = errors.New("shard differs from quorum")
+	ErrInvalidStorageClass  = errors.New("invalid storage class")
+	ErrInvalidName          = errors.New("invalid file or part name")
 )
 
 var (
@@ -16,3 +19,10 @@ var (
 	ErrBucketNotFound     = errors.New("bucket not found")
 	ErrBucketNotEmpty     = errors.New("bucket not empty")
 )
+
+var (
+	ErrDiskIDMismatch       = errors.New("disk ID mismatch")
+	ErrDiskTypeMismatch     = errors.New("disk type mismatch")
+	ErrDiskPositionMismatch = errors.New("disk position mismatch")
+	ErrUnsupportedFormat    = errors.New("unsupported disk format")
+)
@@ -0,0 +1,140 @@
+package format
+
+import (
+	"errors"
+	"os"
+	"path"
+
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	xos "github.com/balamurugana/goat/pkg/os"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+const (
+	// DataSpaceType denotes disk used by dataspace.
+	DataSpaceType = "dataspace"
+
+	// NameSpaceType denotes disk used by namespace.
+	NameSpaceType = "namespace"
+
+	formatFile     = "format.json"
+	tempFormatFile = "format.json.tmp"
+)
+
+// Migration migrates disk layout in store directory from one format version to next.
+// Migration may be interrupted by crash and run again, hence it must be idempotent.
+type Migration func(storeDir string) error
+
+// Format is disk identity and layout version stored in <STORE_DIR>/format.json.
+type Format struct {
+	Version      int    `json:"version"`
+	Type         string `json:"type"`
+	ID           string `json:"id"`
+	UUID         string `json:"uuid"`
+	DeploymentID string `json:"deploymentID,omitempty"`
+	SetIndex     int    `json:"setIndex"`
+	DiskIndex    int    `json:"diskIndex"`
+}
+
+// Save writes format to store directory atomically and durably, i.e. temporary file is synced before it is
+// renamed to format.json and store directory is synced after the rename.
+func (format *Format) Save(storeDir string) error {
+	tempFile := path.Join(storeDir, tempFormatFile)
+	if err := os.Remove(tempFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	fs := xos.FS{Sync: true}
+	if err := fs.WriteJSONFile(tempFile, format); err != nil {
+		return err
+	}
+
+	return fs.Rename(tempFile, path.Join(storeDir, formatFile))
+}
+
+// Verify checks whether format belongs to disk of given type and ID.
+func (format *Format) Verify(diskType, id string) error {
+	if format.Type != diskType {
+		return xerrors.ErrDiskTypeMismatch
+	}
+
+	if format.ID != id {
+		return xerrors.ErrDiskIDMismatch
+	}
+
+	return nil
+}
+
+// SetPosition assigns deployment ID and position in erasure set to the disk on first call.
+// Later calls verify given values against assigned ones.
+func (format *Format) SetPosition(storeDir, deploymentID string, setIndex, diskIndex int) error {
+	if format.DeploymentID != "" {
+		if format.DeploymentID != deploymentID || format.SetIndex != setIndex || format.DiskIndex != diskIndex {
+			return xerrors.ErrDiskPositionMismatch
+		}
+
+		return nil
+	}
+
+	newFormat := *format
+	newFormat.DeploymentID = deploymentID
+	newFormat.SetIndex = setIndex
+	newFormat.DiskIndex = diskIndex
+	if err := newFormat.Save(storeDir); err != nil {
+		return err
+	}
+
+	*format = newFormat
+	return nil
+}
+
+// Load reads format of store directory. Store directory without format.json is treated as version 0.
+func Load(storeDir string) (*Format, error) {
+	var format Format
+	err := xos.ReadJSONFile(path.Join(storeDir, formatFile), 1024*1024, &format)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Format{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &format, nil
+}
+
+// Open loads format of store directory, creates it on first use and migrates older layout to
+// latest version which is len(migrations). migrations[i] migrates version i to i+1.
+func Open(storeDir, diskType, id string, migrations []Migration) (*Format, error) {
+	format, err := Load(storeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if format.Version > len(migrations) {
+		return nil, xerrors.ErrUnsupportedFormat
+	}
+
+	if format.Version == 0 {
+		format.Type = diskType
+		format.ID = id
+		format.UUID = xrand.NewUUID().String()
+	}
+
+	if err = format.Verify(diskType, id); err != nil {
+		return nil, err
+	}
+
+	for format.Version < len(migrations) {
+		if err = migrations[format.Version](storeDir); err != nil {
+			return nil, err
+		}
+
+		format.Version++
+		if err = format.Save(storeDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return format, nil
+}
@@ -0,0 +1,114 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestOpen(t *testing.T) {
+	storeDir := xrand.NewID(8).String()
+	if err := os.Mkdir(storeDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	var applied []int
+	migration := func(version int) Migration {
+		return func(dir string) error {
+			if dir != storeDir {
+				return fmt.Errorf("unexpected store directory %v", dir)
+			}
+
+			applied = append(applied, version)
+			return nil
+		}
+	}
+
+	format, err := Open(storeDir, DataSpaceType, "disk1", []Migration{migration(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if format.Version != 1 || format.ID != "disk1" || format.Type != DataSpaceType || len(format.UUID) != 32 {
+		t.Fatalf("unexpected format %+v", format)
+	}
+
+	if _, err = Open(storeDir, DataSpaceType, "disk2", []Migration{migration(0)}); !errors.Is(err, xerrors.ErrDiskIDMismatch) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDiskIDMismatch, err)
+	}
+
+	if _, err = Open(storeDir, NameSpaceType, "disk1", []Migration{migration(0)}); !errors.Is(err, xerrors.ErrDiskTypeMismatch) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrDiskTypeMismatch, err)
+	}
+
+	if _, err = Open(storeDir, DataSpaceType, "disk1", nil); !errors.Is(err, xerrors.ErrUnsupportedFormat) {
+		t.Fatalf("mismatch: expected: %v, got: %v", xerrors.ErrUnsupportedFormat, err)
+	}
+
+	newFormat, err := Open(storeDir, DataSpaceType, "disk1", []Migration{migration(0), migration(1), migration(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []int{0, 1, 2}; !reflect.DeepEqual(applied, expected) {
+		t.Fatalf("applied migrations: expected: %v, got: %v", expected, applied)
+	}
+
+	if newFormat.Version != 3 || newFormat.UUID != format.UUID {
+		t.Fatalf("unexpected format %+v", newFormat)
+	}
+
+	if _, err = os.Stat(path.Join(storeDir, tempFormatFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("mismatch: expected: %v, got: %v", os.ErrNotExist, err)
+	}
+}
+
+func TestSetPosition(t *testing.T) {
+	storeDir := xrand.NewID(8).String()
+	if err := os.Mkdir(storeDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	migrations := []Migration{func(string) error { return nil }}
+	format, err := Open(storeDir, NameSpaceType, "disk1", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = format.SetPosition(storeDir, "deployment1", 2, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if format, err = Open(storeDir, NameSpaceType, "disk1", migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		deploymentID string
+		setIndex     int
+		diskIndex    int
+		expectedErr  error
+	}{
+		{"deployment1", 2, 5, nil},
+		{"deployment2", 2, 5, xerrors.ErrDiskPositionMismatch},
+		{"deployment1", 1, 5, xerrors.ErrDiskPositionMismatch},
+		{"deployment1", 2, 4, xerrors.ErrDiskPositionMismatch},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test%v", i), func(t *testing.T) {
+			err := format.SetPosition(storeDir, testCase.deploymentID, testCase.setIndex, testCase.diskIndex)
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("mismatch: expected: %v, got: %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
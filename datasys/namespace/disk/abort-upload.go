@@ -24,7 +24,7 @@ func (disk *Disk) AbortUpload(bucketName, objectName string, uploadID disk.Uploa
 	}
 
 	trashUploadIDDir := path.Join(disk.trashDir, objectNameHash+"."+uploadID.String())
-	if err := os.Rename(uploadIDDir, trashUploadIDDir); err != nil {
+	if err := disk.fs.Rename(uploadIDDir, trashUploadIDDir); err != nil {
 		return err
 	}
 
@@ -38,7 +38,7 @@ func (disk *Disk) AbortUpload(bucketName, objectName string, uploadID disk.Uploa
 	if strings.HasSuffix(objectName, "/") {
 		trashMetaDataFile = path.Join(disk.trashDir, uploadID.String()+"."+slashObjectID)
 	}
-	if err := os.Rename(metaDataFile, trashMetaDataFile); err != nil {
+	if err := disk.fs.Rename(metaDataFile, trashMetaDataFile); err != nil {
 		os.Rename(trashUploadIDDir, uploadIDDir)
 		return err
 	}
@@ -50,8 +50,6 @@ func (disk *Disk) AbortUpload(bucketName, objectName string, uploadID disk.Uploa
 		return err
 	}
 
-	// FIXME: cleanup trash dir
-
 	return nil
 }
 
@@ -71,17 +71,21 @@ func (disk *Disk) CreateBucket(bucketName string, bucketInfo *s3.Bucket, metaDat
 	}
 
 	for filename, data := range metaDataFiles {
-		if err := ioutil.WriteFile(path.Join(tempBucketDir, filename), data, 0644); err != nil {
+		if err := disk.fs.WriteBytes(path.Join(tempBucketDir, filename), data); err != nil {
 			return err
 		}
 	}
 
-	if err := xos.WriteJSONFile(path.Join(tempBucketDir, "bucket.json"), bucketInfo); err != nil {
+	if err := disk.fs.WriteJSONFile(path.Join(tempBucketDir, "bucket.json"), bucketInfo); err != nil {
+		return err
+	}
+
+	if err := disk.fs.SyncDir(tempBucketDir); err != nil {
 		return err
 	}
 
 	bucketDir := path.Join(disk.bucketsDir, bucketName)
-	if err := os.Rename(tempBucketDir, bucketDir); err != nil {
+	if err := disk.fs.Rename(tempBucketDir, bucketDir); err != nil {
 		if errors.Is(err, os.ErrExist) {
 			err = xerrors.ErrBucketAlreadyExist
 		}
@@ -191,8 +195,6 @@ func (disk *Disk) DeleteBucket(bucketName string) error {
 		err = xerrors.ErrBucketNotFound
 	}
 
-	// FIXME: cleanup trash dir
-
 	return err
 }
 
@@ -208,12 +210,12 @@ func (disk *Disk) BucketExist(bucketName string) bool {
 
 func (disk *Disk) SetBucketMetaData(bucketName string, name string, data []byte) (err error) {
 	tempMetaDataFile := path.Join(disk.tmpDir, bucketName+"."+name+"."+newTempName())
-	if err = ioutil.WriteFile(tempMetaDataFile, data, 0644); err != nil {
+	if err = disk.fs.WriteBytes(tempMetaDataFile, data); err != nil {
 		return err
 	}
 
 	metaDataFile := path.Join(disk.bucketsDir, bucketName, name)
-	if err = os.Rename(tempMetaDataFile, metaDataFile); errors.Is(err, os.ErrNotExist) {
+	if err = disk.fs.Rename(tempMetaDataFile, metaDataFile); errors.Is(err, os.ErrNotExist) {
 		err = xerrors.ErrBucketNotFound
 	}
 
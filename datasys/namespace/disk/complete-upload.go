@@ -3,7 +3,6 @@ package disk
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
 	"strings"
@@ -27,30 +26,42 @@ func (disk *Disk) CompleteUpload(bucketName, objectName string, uploadID disk.Up
 		return false, xerrors.ErrUploadIDNotFound
 	}
 
+	// Object is stored by storage class of upload unless given.
+	if objectInfo.StorageClass == "" {
+		uploadInfo, err := disk.GetUpload(bucketName, objectName, uploadID)
+		if err != nil {
+			return false, err
+		}
+
+		info := *objectInfo
+		info.StorageClass = uploadInfo.Object.StorageClass
+		objectInfo = &info
+	}
+
 	tempVersionFile := path.Join(disk.tmpDir, fmt.Sprintf("%v.%v.%v", objectNameHash, versionID, newTempName()))
-	if err := xos.WriteJSONFile(tempVersionFile, objectInfo); err != nil {
+	if err := disk.fs.WriteJSONFile(tempVersionFile, objectInfo); err != nil {
 		return false, err
 	}
 
 	tempDataInfoFile := path.Join(disk.tmpDir, fmt.Sprintf("%v.%v.datainfo.%v", objectNameHash, versionID, newTempName()))
-	if err := ioutil.WriteFile(tempDataInfoFile, dataInfo, 0644); err != nil {
+	if err := disk.fs.WriteBytes(tempDataInfoFile, dataInfo); err != nil {
 		return false, err
 	}
 
 	tempDefaultVersionFile := path.Join(disk.tmpDir, fmt.Sprintf("%v.default.%v", objectNameHash, newTempName()))
-	if err := ioutil.WriteFile(tempDefaultVersionFile, []byte(versionID.String()), 0644); err != nil {
+	if err := disk.fs.WriteBytes(tempDefaultVersionFile, []byte(versionID.String())); err != nil {
 		return false, err
 	}
 
 	objectDir := path.Join(bucketDir, "objects", objectName)
 
 	versionFile := path.Join(objectDir, versionID.String())
-	if err := xos.CreatePath(versionFile, tempVersionFile, false); err != nil {
+	if err := disk.fs.CreatePath(versionFile, tempVersionFile, false); err != nil {
 		return false, err
 	}
 
 	dataInfoFile := path.Join(objectDir, versionID.String()+".datainfo")
-	if err := xos.CreatePath(dataInfoFile, tempDataInfoFile, false); err != nil {
+	if err := disk.fs.CreatePath(dataInfoFile, tempDataInfoFile, false); err != nil {
 		xos.RemovePath(versionFile, path.Join(bucketDir, "objects"), false)
 		return false, err
 	}
@@ -64,7 +75,7 @@ func (disk *Disk) CompleteUpload(bucketName, objectName string, uploadID disk.Up
 	defaultExists := xos.Exist(defaultFile)
 
 	if isDefault || !defaultExists {
-		if err := os.Rename(defaultFile, trashDefaultFile); err != nil {
+		if err := disk.fs.Rename(defaultFile, trashDefaultFile); err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
 				xos.RemovePath(versionFile, path.Join(bucketDir, "objects"), false)
 				xos.RemovePath(dataInfoFile, path.Join(bucketDir, "objects"), false)
@@ -72,7 +83,7 @@ func (disk *Disk) CompleteUpload(bucketName, objectName string, uploadID disk.Up
 			}
 		}
 
-		if err := os.Rename(tempDefaultVersionFile, defaultFile); err != nil {
+		if err := disk.fs.Rename(tempDefaultVersionFile, defaultFile); err != nil {
 			if !defaultExists {
 				xos.RemovePath(defaultFile, path.Join(bucketDir, "objects"), false)
 			}
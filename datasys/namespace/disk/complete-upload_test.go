@@ -0,0 +1,52 @@
+package disk
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	"github.com/balamurugana/goat/datasys/namespace/s3"
+	xos "github.com/balamurugana/goat/pkg/os"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestCompleteUploadStorageClass(t *testing.T) {
+	id := xrand.NewID(8).String()
+	storeDir := id
+	if err := os.Mkdir(storeDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	nsDisk, err := NewDisk(id, storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = nsDisk.CreateBucket("bucket", &s3.Bucket{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	uploadInfo := &s3.Upload{}
+	uploadInfo.Object.StorageClass = s3.ReducedRedundancyStorageClass
+	uploadID := disk.NewUploadID()
+	if err = nsDisk.CreateUpload("bucket", "object", uploadID, uploadInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	versionID := disk.NewVersionID()
+	if _, err = nsDisk.CompleteUpload("bucket", "object", uploadID, &s3.Object{Size: 10}, []byte("{}"), versionID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var objectInfo s3.Object
+	versionFile := path.Join(storeDir, "buckets", "bucket", "objects", "object", versionID.String())
+	if err = xos.ReadJSONFile(versionFile, -1, &objectInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	if objectInfo.StorageClass != s3.ReducedRedundancyStorageClass {
+		t.Fatalf("mismatch: expected: %v, got: %v", s3.ReducedRedundancyStorageClass, objectInfo.StorageClass)
+	}
+}
@@ -25,7 +25,7 @@ func (disk *Disk) CreateUpload(bucketName, objectName string, uploadID disk.Uplo
 
 	objectNameHash := xhash.SumInBase64(objectName)
 	uploadIDDir := path.Join(bucketDir, "uploadids", objectNameHash, uploadID.String())
-	if err := xos.CreatePath(uploadIDDir, "", true); err != nil {
+	if err := disk.fs.CreatePath(uploadIDDir, "", true); err != nil {
 		if errors.Is(err, os.ErrExist) {
 			err = xerrors.ErrUploadIDAlreadyExist
 		}
@@ -34,7 +34,7 @@ func (disk *Disk) CreateUpload(bucketName, objectName string, uploadID disk.Uplo
 	}
 
 	tempMetaDataFile := path.Join(disk.tmpDir, fmt.Sprintf("%v.%v.%v", objectNameHash, uploadID.String(), newTempName()))
-	if err := xos.WriteJSONFile(tempMetaDataFile, uploadInfo); err != nil {
+	if err := disk.fs.WriteJSONFile(tempMetaDataFile, uploadInfo); err != nil {
 		xos.RemovePath(uploadIDDir, path.Join(bucketDir, "uploadids"), false)
 		return err
 	}
@@ -43,7 +43,7 @@ func (disk *Disk) CreateUpload(bucketName, objectName string, uploadID disk.Uplo
 	if strings.HasSuffix(objectName, "/") {
 		metaDataFile = path.Join(bucketDir, "multipart", objectName, uploadID.String()+"."+slashObjectID)
 	}
-	if err := xos.CreatePath(metaDataFile, tempMetaDataFile, false); err != nil {
+	if err := disk.fs.CreatePath(metaDataFile, tempMetaDataFile, false); err != nil {
 		xos.RemovePath(uploadIDDir, path.Join(bucketDir, "uploadids"), false)
 		xos.RemovePath(metaDataFile, path.Join(bucketDir, "multipart"), false)
 		return err
@@ -5,7 +5,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/balamurugana/goat/datasys/format"
+	"github.com/balamurugana/goat/datasys/recovery"
+	"github.com/balamurugana/goat/datasys/trash"
+	xos "github.com/balamurugana/goat/pkg/os"
 	xrand "github.com/balamurugana/goat/pkg/rand"
 )
 
@@ -20,6 +26,14 @@ type Disk struct {
 	bucketsDir string
 	tmpDir     string
 	trashDir   string
+
+	fs xos.FS
+
+	reaper      *trash.Reaper
+	reaperMutex sync.Mutex
+
+	format         *format.Format
+	recoveryReport *recovery.Report
 }
 
 func NewDisk(id, dir string) (*Disk, error) {
@@ -49,6 +63,11 @@ func NewDisk(id, dir string) (*Disk, error) {
 		return nil, err
 	}
 
+	diskFormat, err := format.Open(storeDir, format.NameSpaceType, id, migrations)
+	if err != nil {
+		return nil, err
+	}
+
 	bucketsDir := path.Join(storeDir, "buckets")
 	if err := os.Mkdir(bucketsDir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
 		return nil, err
@@ -64,19 +83,77 @@ func NewDisk(id, dir string) (*Disk, error) {
 		return nil, err
 	}
 
-	return &Disk{
+	disk := &Disk{
 		id:         id,
 		storeDir:   storeDir,
 		bucketsDir: bucketsDir,
 		tmpDir:     tmpDir,
 		trashDir:   trashDir,
-	}, nil
+		format:     diskFormat,
+	}
+
+	if disk.recoveryReport, err = disk.recoverStore(); err != nil {
+		return nil, err
+	}
+
+	return disk, nil
 }
 
 func (disk *Disk) ID() string {
 	return disk.id
 }
 
+// SetSync enables or disables syncing file contents and directory entries to stable storage on every write.
+func (disk *Disk) SetSync(sync bool) {
+	disk.fs.Sync = sync
+}
+
+// Format returns format of this disk.
+func (disk *Disk) Format() format.Format {
+	return *disk.format
+}
+
+// SetPosition assigns deployment ID and position in erasure set to this disk on first use and
+// verifies them on later use.
+func (disk *Disk) SetPosition(deploymentID string, setIndex, diskIndex int) error {
+	return disk.format.SetPosition(disk.storeDir, deploymentID, setIndex, diskIndex)
+}
+
+// RecoveryReport returns leftovers of interrupted operations repaired by NewDisk().
+func (disk *Disk) RecoveryReport() *recovery.Report {
+	return disk.recoveryReport
+}
+
+// StartTrashReaper starts background removal of trash entries older than grace period at random
+// intervals between minInterval and maxInterval.
+func (disk *Disk) StartTrashReaper(gracePeriod, minInterval, maxInterval time.Duration) *trash.Reaper {
+	reaper := trash.NewReaper(disk.trashDir, gracePeriod, nil)
+
+	disk.reaperMutex.Lock()
+	oldReaper := disk.reaper
+	disk.reaper = reaper
+	disk.reaperMutex.Unlock()
+
+	if oldReaper != nil {
+		oldReaper.Stop()
+	}
+
+	reaper.Start(minInterval, maxInterval)
+	return reaper
+}
+
+// StopTrashReaper stops background trash reaper started by StartTrashReaper().
+func (disk *Disk) StopTrashReaper() {
+	disk.reaperMutex.Lock()
+	reaper := disk.reaper
+	disk.reaper = nil
+	disk.reaperMutex.Unlock()
+
+	if reaper != nil {
+		reaper.Stop()
+	}
+}
+
 // Uploads CRUD
 // * CreateUpload/RevertCreateUpload
 // * UploadPart/RevertUploadPart
@@ -0,0 +1,9 @@
+package disk
+
+import "github.com/balamurugana/goat/datasys/format"
+
+// migrations migrate disk layout; migrations[i] migrates format version i to i+1.
+var migrations = []format.Migration{
+	// Version 0 is the layout before format.json is introduced which is same as version 1.
+	func(storeDir string) error { return nil },
+}
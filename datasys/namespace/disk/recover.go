@@ -0,0 +1,393 @@
+package disk
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/balamurugana/goat/datasys/recovery"
+	xhash "github.com/balamurugana/goat/pkg/hash"
+	xos "github.com/balamurugana/goat/pkg/os"
+)
+
+const objectNameHashLength = 43 // Length of base64 encoded Highway256 hash.
+
+// readdir returns sorted entry names of given directory and their modes.
+func readdir(dir string) ([]string, map[string]os.FileMode, error) {
+	modes := make(map[string]os.FileMode)
+	picker := func(name string, mode os.FileMode) (stop bool) {
+		modes[name] = mode
+		return false
+	}
+
+	if err := xos.Readdirnames(dir, picker); err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(modes))
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, modes, nil
+}
+
+// walkdir calls fn for every directory under rootDir with its path relative to rootDir and its regular files.
+func walkdir(rootDir, dirName string, fn func(dirName string, filenames []string)) error {
+	names, modes, err := readdir(path.Join(rootDir, dirName))
+	if err != nil {
+		return err
+	}
+
+	filenames := []string{}
+	for _, name := range names {
+		switch {
+		case modes[name].IsDir():
+			if err = walkdir(rootDir, path.Join(dirName, name), fn); err != nil {
+				return err
+			}
+		case modes[name].IsRegular():
+			filenames = append(filenames, name)
+		}
+	}
+
+	fn(dirName, filenames)
+	return nil
+}
+
+// parseDefaultName parses <OBJECTHASH>.default.<SUFFIX> name used by CompleteUpload() for default file in tmp and trash.
+func parseDefaultName(name string) (objectNameHash, suffix string, ok bool) {
+	tokens := strings.SplitN(name, ".", 3)
+	if len(tokens) != 3 || len(tokens[0]) != objectNameHashLength || tokens[1] != "default" {
+		return "", "", false
+	}
+
+	return tokens[0], tokens[2], true
+}
+
+func getDefaultFile(objectDir, objectName string) string {
+	if strings.HasSuffix(objectName, "/") {
+		return path.Join(objectDir, slashObjectID)
+	}
+
+	return path.Join(objectDir, objectID)
+}
+
+func (disk *Disk) relPath(name string) string {
+	return strings.TrimPrefix(name, disk.storeDir+"/")
+}
+
+// recoverStore repairs leftovers of operations interrupted by crash.
+// * Default file half renamed by CompleteUpload() is rolled forward if its version is complete, else rolled back.
+// * Any other file in tmp directory is rolled back.
+// * Upload ID directory without multipart metadata (interrupted CreateUpload()) is rolled back.
+// * Multipart metadata without upload ID directory (interrupted AbortUpload()) is rolled forward.
+// Rolled back leftovers are moved to trash.
+func (disk *Disk) recoverStore() (*recovery.Report, error) {
+	report := &recovery.Report{}
+
+	if err := disk.recoverDefaults(report); err != nil {
+		return nil, err
+	}
+
+	if err := disk.recoverTmp(report); err != nil {
+		return nil, err
+	}
+
+	if err := disk.recoverUploads(report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+type objectVersion struct {
+	bucketName string
+	dirName    string
+}
+
+// findVersions returns object directories of given version IDs.
+func (disk *Disk) findVersions(versionIDs map[string]struct{}) (map[string][]objectVersion, error) {
+	versions := make(map[string][]objectVersion)
+	if len(versionIDs) == 0 {
+		return versions, nil
+	}
+
+	bucketNames, modes, err := readdir(disk.bucketsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bucketName := range bucketNames {
+		if !modes[bucketName].IsDir() {
+			continue
+		}
+
+		fn := func(dirName string, filenames []string) {
+			for _, filename := range filenames {
+				if _, found := versionIDs[filename]; found {
+					versions[filename] = append(versions[filename], objectVersion{bucketName, dirName})
+				}
+			}
+		}
+
+		if err = walkdir(path.Join(disk.bucketsDir, bucketName, "objects"), "", fn); err != nil {
+			return nil, err
+		}
+	}
+
+	return versions, nil
+}
+
+// lookupObject returns object directory and object name of given object name hash having given version ID.
+func (disk *Disk) lookupObject(versions map[string][]objectVersion, objectNameHash, versionID string) (bucketDir, objectName string, found bool) {
+	for _, version := range versions[versionID] {
+		for _, objectName := range []string{version.dirName, version.dirName + "/"} {
+			if xhash.SumInBase64(objectName) == objectNameHash {
+				return path.Join(disk.bucketsDir, version.bucketName), objectName, true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+func (disk *Disk) recoverDefaults(report *recovery.Report) error {
+	type defaultCandidate struct {
+		filename       string
+		objectNameHash string
+		versionID      string
+	}
+
+	versionIDs := make(map[string]struct{})
+
+	tmpNames, tmpModes, err := readdir(disk.tmpDir)
+	if err != nil {
+		return err
+	}
+
+	var tmpCandidates []defaultCandidate
+	tmpDefaults := make(map[string]struct{})
+	for _, name := range tmpNames {
+		objectNameHash, _, ok := parseDefaultName(name)
+		if !ok || !tmpModes[name].IsRegular() {
+			continue
+		}
+
+		filename := path.Join(disk.tmpDir, name)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		tmpCandidates = append(tmpCandidates, defaultCandidate{filename, objectNameHash, string(data)})
+		tmpDefaults[objectNameHash+"."+string(data)] = struct{}{}
+		versionIDs[string(data)] = struct{}{}
+	}
+
+	// Buckets are not scanned unless CompleteUpload() is interrupted.
+	if len(tmpCandidates) == 0 {
+		return nil
+	}
+
+	// Every CompleteUpload() leaves replaced default file in trash as <OBJECTHASH>.default.<VERSIONID> of new
+	// version, hence only those paired with default file of same version in tmp are of interrupted CompleteUpload().
+	trashNames, trashModes, err := readdir(disk.trashDir)
+	if err != nil {
+		return err
+	}
+
+	var trashCandidates []defaultCandidate
+	for _, name := range trashNames {
+		objectNameHash, versionID, ok := parseDefaultName(name)
+		if !ok || !trashModes[name].IsRegular() {
+			continue
+		}
+
+		if _, found := tmpDefaults[objectNameHash+"."+versionID]; !found {
+			continue
+		}
+
+		trashCandidates = append(trashCandidates, defaultCandidate{path.Join(disk.trashDir, name), objectNameHash, versionID})
+		versionIDs[versionID] = struct{}{}
+	}
+
+	versions, err := disk.findVersions(versionIDs)
+	if err != nil {
+		return err
+	}
+
+	// Default file in tmp is left when CompleteUpload() is interrupted before or after moving current default file to trash.
+	for _, candidate := range tmpCandidates {
+		trashFile := path.Join(disk.trashDir, path.Base(candidate.filename))
+
+		bucketDir, objectName, found := disk.lookupObject(versions, candidate.objectNameHash, candidate.versionID)
+		if !found {
+			report.Add(disk.relPath(candidate.filename), "default file of missing version", recovery.RollBack, os.Rename(candidate.filename, trashFile))
+			continue
+		}
+
+		objectDir := path.Join(bucketDir, "objects", objectName)
+		defaultFile := getDefaultFile(objectDir, objectName)
+		if xos.Exist(defaultFile) {
+			report.Add(disk.relPath(candidate.filename), "unused default file", recovery.RollBack, os.Rename(candidate.filename, trashFile))
+			continue
+		}
+
+		if xos.Exist(path.Join(objectDir, candidate.versionID+".datainfo")) {
+			report.Add(disk.relPath(defaultFile), "default file not renamed", recovery.RollForward, os.Rename(candidate.filename, defaultFile))
+			continue
+		}
+
+		// Version is created without data info; roll back whole version.
+		versionFile := path.Join(objectDir, candidate.versionID)
+		trashVersionFile := path.Join(disk.trashDir, candidate.objectNameHash+"."+candidate.versionID)
+		err1 := os.Rename(candidate.filename, trashFile)
+		err2 := os.Rename(versionFile, trashVersionFile)
+		err3 := xos.RemovePath(objectDir, path.Join(bucketDir, "objects"), false)
+		report.Add(disk.relPath(versionFile), "version without data info", recovery.RollBack, mergeErrors(err1, err2, err3))
+	}
+
+	// Default file in trash is restored if object is left without default file.
+	for _, candidate := range trashCandidates {
+		bucketDir, objectName, found := disk.lookupObject(versions, candidate.objectNameHash, candidate.versionID)
+		if !found {
+			continue
+		}
+
+		objectDir := path.Join(bucketDir, "objects", objectName)
+		defaultFile := getDefaultFile(objectDir, objectName)
+		if xos.Exist(defaultFile) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(candidate.filename)
+		if err != nil {
+			return err
+		}
+
+		if !xos.Exist(path.Join(objectDir, string(data))) {
+			continue
+		}
+
+		report.Add(disk.relPath(defaultFile), "default file left in trash", recovery.RollBack, os.Rename(candidate.filename, defaultFile))
+	}
+
+	return nil
+}
+
+func (disk *Disk) recoverTmp(report *recovery.Report) error {
+	names, _, err := readdir(disk.tmpDir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		tmpFile := path.Join(disk.tmpDir, name)
+		trashFile := path.Join(disk.trashDir, name)
+		report.Add(disk.relPath(tmpFile), "leftover temporary file", recovery.RollBack, os.Rename(tmpFile, trashFile))
+	}
+
+	return nil
+}
+
+func (disk *Disk) recoverUploads(report *recovery.Report) error {
+	bucketNames, modes, err := readdir(disk.bucketsDir)
+	if err != nil {
+		return err
+	}
+
+	for _, bucketName := range bucketNames {
+		if !modes[bucketName].IsDir() {
+			continue
+		}
+
+		if err = disk.recoverBucketUploads(report, path.Join(disk.bucketsDir, bucketName)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (disk *Disk) recoverBucketUploads(report *recovery.Report, bucketDir string) error {
+	multipartDir := path.Join(bucketDir, "multipart")
+	uploadidsDir := path.Join(bucketDir, "uploadids")
+
+	// Multipart metadata files mapped by <OBJECTHASH>/<UPLOADID>.
+	metaDataFiles := make(map[string]string)
+	fn := func(dirName string, filenames []string) {
+		for _, filename := range filenames {
+			var uploadID, objectName string
+			switch {
+			case strings.HasSuffix(filename, "."+objectID):
+				uploadID = strings.TrimSuffix(filename, "."+objectID)
+				objectName = dirName
+			case strings.HasSuffix(filename, "."+slashObjectID):
+				uploadID = strings.TrimSuffix(filename, "."+slashObjectID)
+				objectName = dirName + "/"
+			default:
+				continue
+			}
+
+			metaDataFiles[path.Join(xhash.SumInBase64(objectName), uploadID)] = path.Join(multipartDir, dirName, filename)
+		}
+	}
+
+	if err := walkdir(multipartDir, "", fn); err != nil {
+		return err
+	}
+
+	objectNameHashes, modes, err := readdir(uploadidsDir)
+	if err != nil {
+		return err
+	}
+
+	for _, objectNameHash := range objectNameHashes {
+		if !modes[objectNameHash].IsDir() {
+			continue
+		}
+
+		uploadIDs, modes, err := readdir(path.Join(uploadidsDir, objectNameHash))
+		if err != nil {
+			return err
+		}
+
+		for _, uploadID := range uploadIDs {
+			if !modes[uploadID].IsDir() {
+				continue
+			}
+
+			key := path.Join(objectNameHash, uploadID)
+			if _, found := metaDataFiles[key]; found {
+				delete(metaDataFiles, key)
+				continue
+			}
+
+			uploadIDDir := path.Join(uploadidsDir, objectNameHash, uploadID)
+			trashUploadIDDir := path.Join(disk.trashDir, objectNameHash+"."+uploadID+"."+newTempName())
+			err1 := os.Rename(uploadIDDir, trashUploadIDDir)
+			err2 := xos.RemovePath(path.Dir(uploadIDDir), uploadidsDir, false)
+			report.Add(disk.relPath(uploadIDDir), "upload ID directory without multipart metadata", recovery.RollBack, mergeErrors(err1, err2))
+		}
+	}
+
+	keys := make([]string, 0, len(metaDataFiles))
+	for key := range metaDataFiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		metaDataFile := metaDataFiles[key]
+		trashMetaDataFile := path.Join(disk.trashDir, path.Base(metaDataFile))
+		err1 := os.Rename(metaDataFile, trashMetaDataFile)
+		err2 := xos.RemovePath(path.Dir(metaDataFile), multipartDir, false)
+		report.Add(disk.relPath(metaDataFile), "multipart metadata without upload ID directory", recovery.RollForward, mergeErrors(err1, err2))
+	}
+
+	return nil
+}
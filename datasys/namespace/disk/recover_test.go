@@ -0,0 +1,159 @@
+package disk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/balamurugana/goat/datasys/dataspace/disk"
+	"github.com/balamurugana/goat/datasys/namespace/s3"
+	"github.com/balamurugana/goat/datasys/recovery"
+	xhash "github.com/balamurugana/goat/pkg/hash"
+	xos "github.com/balamurugana/goat/pkg/os"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestRecoverStore(t *testing.T) {
+	id := xrand.NewID(8).String()
+	storeDir := id
+	if err := os.Mkdir(storeDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	nsDisk, err := NewDisk(id, storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = nsDisk.CreateBucket("bucket", &s3.Bucket{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	bucketDir := path.Join(storeDir, "buckets", "bucket")
+
+	// CreateUpload() interrupted before creating multipart metadata.
+	uploadID1 := disk.NewUploadID()
+	if err = nsDisk.CreateUpload("bucket", "obj1", uploadID1, &s3.Upload{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(path.Join(bucketDir, "multipart", "obj1", uploadID1.String()+"."+objectID)); err != nil {
+		t.Fatal(err)
+	}
+
+	// AbortUpload() interrupted after moving upload ID directory to trash.
+	uploadID2 := disk.NewUploadID()
+	if err = nsDisk.CreateUpload("bucket", "obj2", uploadID2, &s3.Upload{}); err != nil {
+		t.Fatal(err)
+	}
+	uploadIDDir2 := path.Join(bucketDir, "uploadids", xhash.SumInBase64("obj2"), uploadID2.String())
+	if err = os.Rename(uploadIDDir2, path.Join(storeDir, "trash", uploadID2.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	// CompleteUpload() interrupted after moving current default file to trash.
+	uploadID3 := disk.NewUploadID()
+	if err = nsDisk.CreateUpload("bucket", "obj3", uploadID3, &s3.Upload{}); err != nil {
+		t.Fatal(err)
+	}
+	versionID1 := disk.NewVersionID()
+	if _, err = nsDisk.CompleteUpload("bucket", "obj3", uploadID3, &s3.Object{}, []byte("{}"), versionID1, true); err != nil {
+		t.Fatal(err)
+	}
+	versionID2 := disk.NewVersionID()
+	objectDir3 := path.Join(bucketDir, "objects", "obj3")
+	hash3 := xhash.SumInBase64("obj3")
+	if err = ioutil.WriteFile(path.Join(objectDir3, versionID2.String()), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path.Join(objectDir3, versionID2.String()+".datainfo"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Rename(path.Join(objectDir3, objectID), path.Join(storeDir, "trash", fmt.Sprintf("%v.default.%v", hash3, versionID2))); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path.Join(storeDir, "tmp", fmt.Sprintf("%v.default.%v", hash3, newTempName())), []byte(versionID2.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// CompleteUpload() interrupted before creating data info file.
+	versionID3 := disk.NewVersionID()
+	objectDir4 := path.Join(bucketDir, "objects", "obj4")
+	if err = xos.CreatePath(objectDir4, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path.Join(objectDir4, versionID3.String()), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path.Join(storeDir, "tmp", fmt.Sprintf("%v.default.%v", xhash.SumInBase64("obj4/"), newTempName())), []byte(versionID3.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Default file replaced by completed CompleteUpload() is left in trash without default file in tmp, hence it
+	// is not restored even if object has no default file.
+	uploadID5 := disk.NewUploadID()
+	if err = nsDisk.CreateUpload("bucket", "obj5", uploadID5, &s3.Upload{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = nsDisk.CompleteUpload("bucket", "obj5", uploadID5, &s3.Object{}, []byte("{}"), disk.NewVersionID(), true); err != nil {
+		t.Fatal(err)
+	}
+	uploadID5 = disk.NewUploadID()
+	if err = nsDisk.CreateUpload("bucket", "obj5", uploadID5, &s3.Upload{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = nsDisk.CompleteUpload("bucket", "obj5", uploadID5, &s3.Object{}, []byte("{}"), disk.NewVersionID(), true); err != nil {
+		t.Fatal(err)
+	}
+	objectDir5 := path.Join(bucketDir, "objects", "obj5")
+	if err = os.Remove(path.Join(objectDir5, objectID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(path.Join(storeDir, "tmp", "leftover"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if nsDisk, err = NewDisk(id, storeDir); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedEntries := []recovery.Entry{
+		{Path: path.Join("buckets", "bucket", "multipart", "obj2", uploadID2.String()+"."+objectID), Reason: "multipart metadata without upload ID directory", Action: recovery.RollForward},
+		{Path: path.Join("buckets", "bucket", "objects", "obj3", objectID), Reason: "default file not renamed", Action: recovery.RollForward},
+		{Path: path.Join("buckets", "bucket", "objects", "obj4", versionID3.String()), Reason: "version without data info", Action: recovery.RollBack},
+		{Path: path.Join("buckets", "bucket", "uploadids", xhash.SumInBase64("obj1"), uploadID1.String()), Reason: "upload ID directory without multipart metadata", Action: recovery.RollBack},
+		{Path: path.Join("tmp", "leftover"), Reason: "leftover temporary file", Action: recovery.RollBack},
+	}
+
+	entries := nsDisk.RecoveryReport().Entries
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Fatalf("mismatch: expected: %v, got: %v", expectedEntries, entries)
+	}
+
+	data, err := ioutil.ReadFile(path.Join(objectDir3, objectID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != versionID2.String() {
+		t.Fatalf("default version mismatch: expected: %v, got: %v", versionID2, string(data))
+	}
+
+	for _, name := range []string{objectDir4, path.Join(objectDir5, objectID), path.Join(bucketDir, "multipart", "obj2"), path.Join(bucketDir, "uploadids", xhash.SumInBase64("obj1"))} {
+		if xos.Exist(name) {
+			t.Fatalf("%v: expected: <not exist>, got: <exist>", name)
+		}
+	}
+
+	if nsDisk, err = NewDisk(id, storeDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := nsDisk.RecoveryReport().Entries; len(entries) != 0 {
+		t.Fatalf("mismatch: expected: <empty>, got: %v", entries)
+	}
+}
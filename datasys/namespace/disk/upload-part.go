@@ -3,7 +3,6 @@ package disk
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
 
@@ -27,12 +26,12 @@ func (disk *Disk) UploadPart(bucketName, objectName string, uploadID disk.Upload
 	}
 
 	tempPartFile := path.Join(disk.tmpDir, fmt.Sprintf("%v.part.%v", partNumber, newTempName()))
-	if err := xos.WriteJSONFile(tempPartFile, partInfo); err != nil {
+	if err := disk.fs.WriteJSONFile(tempPartFile, partInfo); err != nil {
 		return err
 	}
 
 	tempDataInfoFile := path.Join(disk.tmpDir, fmt.Sprintf("%v.datainfo.%v", partNumber, newTempName()))
-	if err := ioutil.WriteFile(tempDataInfoFile, dataInfo, 0644); err != nil {
+	if err := disk.fs.WriteBytes(tempDataInfoFile, dataInfo); err != nil {
 		return err
 	}
 
@@ -52,13 +51,13 @@ func (disk *Disk) UploadPart(bucketName, objectName string, uploadID disk.Upload
 		}
 	}
 
-	if err := os.Rename(tempPartFile, partFile); err != nil {
+	if err := disk.fs.Rename(tempPartFile, partFile); err != nil {
 		os.Rename(trashPartFile, partFile)
 		os.Rename(trashDataInfoFile, dataInfoFile)
 		return err
 	}
 
-	if err := os.Rename(tempDataInfoFile, dataInfoFile); err != nil {
+	if err := disk.fs.Rename(tempDataInfoFile, dataInfoFile); err != nil {
 		os.Remove(partFile)
 		os.Rename(trashPartFile, partFile)
 		os.Rename(trashDataInfoFile, dataInfoFile)
@@ -0,0 +1,12 @@
+package s3
+
+// Error is S3 error returned to client by its code, message and HTTP status code.
+type Error struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (err *Error) Error() string {
+	return err.Code + ": " + err.Message
+}
@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+)
+
+// StorageClassHeader is S3 request header selecting storage class of object.
+const StorageClassHeader = "x-amz-storage-class"
+
+const (
+	StandardStorageClass          = "STANDARD"
+	ReducedRedundancyStorageClass = "REDUCED_REDUNDANCY"
+)
+
+// ErrInvalidStorageClass is S3 error of storage class not configured in the deployment.
+var ErrInvalidStorageClass = &Error{
+	Code:       "InvalidStorageClass",
+	Message:    "The storage class you specified is not valid.",
+	StatusCode: http.StatusBadRequest,
+}
+
+// GetStorageClass returns storage class requested by x-amz-storage-class header; STANDARD if not given.
+func GetStorageClass(header http.Header) string {
+	if storageClass := header.Get(StorageClassHeader); storageClass != "" {
+		return storageClass
+	}
+
+	return StandardStorageClass
+}
+
+// TempFileSaver saves temporary file by erasure profile of named storage class, e.g. erasure dataspace or pool.
+type TempFileSaver interface {
+	SaveTempFileWithClass(filename string, data io.Reader, size uint64, bitrotProtection bool, storageClass string) (checksum string, err error)
+}
+
+func saveTempFile(ds TempFileSaver, filename string, data io.Reader, size uint64, storageClass string) (checksum string, err error) {
+	checksum, err = ds.SaveTempFileWithClass(filename, data, size, true, storageClass)
+	if errors.Is(err, xerrors.ErrInvalidStorageClass) {
+		err = ErrInvalidStorageClass
+	}
+
+	return checksum, err
+}
+
+// SaveObject saves data of PUT object into temporary file of dataspace by erasure profile of storage class
+// requested by x-amz-storage-class header, and keeps the storage class in objectInfo to be persisted with the
+// object. Storage class not configured is ErrInvalidStorageClass.
+func SaveObject(ds TempFileSaver, header http.Header, filename string, data io.Reader, size uint64, objectInfo *Object) (checksum string, err error) {
+	storageClass := GetStorageClass(header)
+	if checksum, err = saveTempFile(ds, filename, data, size, storageClass); err != nil {
+		return "", err
+	}
+
+	objectInfo.StorageClass = storageClass
+	return checksum, nil
+}
+
+// InitUpload keeps storage class requested by x-amz-storage-class header of CreateMultipartUpload in
+// uploadInfo, so that its parts are saved by SavePart() and its object is completed by that storage class.
+func InitUpload(header http.Header, uploadInfo *Upload) {
+	uploadInfo.Object.StorageClass = GetStorageClass(header)
+}
+
+// SavePart saves data of UploadPart into temporary file of dataspace by erasure profile of storage class of
+// the upload, and keeps the storage class in partInfo. Storage class not configured is ErrInvalidStorageClass.
+func SavePart(ds TempFileSaver, uploadInfo *Upload, filename string, data io.Reader, size uint64, partInfo *Part) (checksum string, err error) {
+	storageClass := uploadInfo.Object.StorageClass
+	if storageClass == "" {
+		storageClass = StandardStorageClass
+	}
+
+	if checksum, err = saveTempFile(ds, filename, data, size, storageClass); err != nil {
+		return "", err
+	}
+
+	partInfo.StorageClass = storageClass
+	return checksum, nil
+}
@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	xerrors "github.com/balamurugana/goat/datasys/errors"
+)
+
+type testTempFileSaver map[string]string
+
+func (saver testTempFileSaver) SaveTempFileWithClass(filename string, data io.Reader, size uint64, bitrotProtection bool, storageClass string) (string, error) {
+	if storageClass != StandardStorageClass && storageClass != ReducedRedundancyStorageClass {
+		return "", xerrors.ErrInvalidStorageClass
+	}
+
+	if _, err := io.Copy(ioutil.Discard, data); err != nil {
+		return "", err
+	}
+
+	saver[filename] = storageClass
+	return "checksum", nil
+}
+
+func TestSaveObject(t *testing.T) {
+	testCases := []struct {
+		storageClass         string
+		expectedStorageClass string
+		err                  error
+	}{
+		{"", StandardStorageClass, nil},
+		{StandardStorageClass, StandardStorageClass, nil},
+		{ReducedRedundancyStorageClass, ReducedRedundancyStorageClass, nil},
+		{"GLACIER", "", ErrInvalidStorageClass},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				header := http.Header{}
+				if testCase.storageClass != "" {
+					header.Set(StorageClassHeader, testCase.storageClass)
+				}
+
+				saver := testTempFileSaver{}
+				objectInfo := &Object{}
+				_, err := SaveObject(saver, header, "object", strings.NewReader("data"), 4, objectInfo)
+				if err != testCase.err {
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.err, err)
+				}
+
+				if objectInfo.StorageClass != testCase.expectedStorageClass {
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.expectedStorageClass, objectInfo.StorageClass)
+				}
+
+				uploadInfo := &Upload{}
+				InitUpload(header, uploadInfo)
+				partInfo := &Part{}
+				if _, err = SavePart(saver, uploadInfo, "part", strings.NewReader("data"), 4, partInfo); err != testCase.err {
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.err, err)
+				}
+
+				if partInfo.StorageClass != testCase.expectedStorageClass || saver["part"] != testCase.expectedStorageClass {
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.expectedStorageClass, partInfo.StorageClass)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,69 @@
+package recovery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Action denotes how a leftover of interrupted operation is repaired.
+type Action string
+
+const (
+	// RollForward denotes interrupted operation is completed.
+	RollForward Action = "roll-forward"
+
+	// RollBack denotes interrupted operation is undone by moving its leftover to trash.
+	RollBack Action = "roll-back"
+)
+
+// Entry is a leftover found by recovery scan.
+type Entry struct {
+	Path   string // Path relative to store directory.
+	Reason string
+	Action Action
+	Err    error // Non-nil if repair is failed.
+}
+
+func (entry Entry) String() string {
+	s := fmt.Sprintf("%v %v: %v", entry.Action, entry.Path, entry.Reason)
+	if entry.Err != nil {
+		s += fmt.Sprintf("; %v", entry.Err)
+	}
+
+	return s
+}
+
+// Report is the result of recovery scan done on a disk.
+type Report struct {
+	Entries []Entry
+}
+
+// Add adds new entry to the report.
+func (report *Report) Add(path, reason string, action Action, err error) {
+	report.Entries = append(report.Entries, Entry{
+		Path:   path,
+		Reason: reason,
+		Action: action,
+		Err:    err,
+	})
+}
+
+// Failed returns entries failed to repair.
+func (report *Report) Failed() (entries []Entry) {
+	for _, entry := range report.Entries {
+		if entry.Err != nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func (report *Report) String() string {
+	s := make([]string, len(report.Entries))
+	for i, entry := range report.Entries {
+		s[i] = entry.String()
+	}
+
+	return strings.Join(s, "\n")
+}
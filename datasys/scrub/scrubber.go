@@ -0,0 +1,329 @@
+package scrub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	xos "github.com/balamurugana/goat/pkg/os"
+	xtime "github.com/balamurugana/goat/pkg/time"
+)
+
+// ErrStopped denotes a pass interrupted by Stop(). Next pass resumes from checkpoint.
+var ErrStopped = errors.New("scrubber stopped")
+
+// VerifyFunc verifies entry of given name. All data of the entry should be read through throttle so that
+// configured I/O rate is honoured.
+type VerifyFunc func(name string, throttle func(io.Reader) io.Reader) error
+
+// Default checkpoint interval of a pass; see SetCheckpointInterval().
+const (
+	defaultCheckpointEntries  = 1000
+	defaultCheckpointDuration = 30 * time.Second
+)
+
+// checkpoint is progress of a pass saved periodically and on Stop().
+type checkpoint struct {
+	LastName string            `json:"lastName"`
+	Corrupt  map[string]string `json:"corrupt,omitempty"` // Name of corrupt entry to its error.
+
+	// Name of corrupt entry to error returned by onCorrupt, e.g. on healing it.
+	Unrepaired map[string]string `json:"unrepaired,omitempty"`
+}
+
+// rateLimiter delays reads so that bytes read per second do not exceed given rate.
+type rateLimiter struct {
+	bytesPerSec uint64
+	mutex       sync.Mutex
+	start       time.Time
+	bytes       uint64
+}
+
+func (limiter *rateLimiter) reset() {
+	limiter.mutex.Lock()
+	limiter.start = time.Now()
+	limiter.bytes = 0
+	limiter.mutex.Unlock()
+}
+
+func (limiter *rateLimiter) wait(n int) {
+	if limiter.bytesPerSec == 0 {
+		return
+	}
+
+	limiter.mutex.Lock()
+	limiter.bytes += uint64(n)
+	expected := time.Duration(float64(limiter.bytes) / float64(limiter.bytesPerSec) * float64(time.Second))
+	elapsed := time.Since(limiter.start)
+	limiter.mutex.Unlock()
+
+	if expected > elapsed {
+		time.Sleep(expected - elapsed)
+	}
+}
+
+type throttledReader struct {
+	reader  io.Reader
+	limiter *rateLimiter
+}
+
+func (reader *throttledReader) Read(b []byte) (n int, err error) {
+	n, err = reader.reader.Read(b)
+	reader.limiter.wait(n)
+	return n, err
+}
+
+// Scrubber verifies every entry of a directory of <INDEX>/<NAME> layout, e.g. data directory of a disk, in
+// sorted order of names at limited I/O rate. Progress is checkpointed to a file periodically and on Stop() so
+// that a pass interrupted by Stop() resumes after last verified entry, and after restart re-verifies at most
+// entries of one checkpoint interval.
+type Scrubber struct {
+	dir            string
+	checkpointFile string
+	verify         VerifyFunc
+	onCorrupt      func(name string, err error) error
+	limiter        *rateLimiter
+
+	checkpointEntries  int
+	checkpointDuration time.Duration
+
+	mutex      sync.Mutex
+	corrupt    map[string]string
+	unrepaired map[string]string
+
+	ticker *xtime.RandTicker
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewScrubber creates new scrubber of given directory reading at most bytesPerSec bytes per second; zero
+// means no limit. If onCorrupt is not nil, it is called for each entry failed verification e.g. to heal it;
+// its error is recorded in the pass and returned by Unrepaired().
+func NewScrubber(dir, checkpointFile string, bytesPerSec uint64, verify VerifyFunc, onCorrupt func(name string, err error) error) *Scrubber {
+	return &Scrubber{
+		dir:            dir,
+		checkpointFile: checkpointFile,
+		verify:         verify,
+		onCorrupt:      onCorrupt,
+		limiter:        &rateLimiter{bytesPerSec: bytesPerSec},
+
+		checkpointEntries:  defaultCheckpointEntries,
+		checkpointDuration: defaultCheckpointDuration,
+	}
+}
+
+// SetCheckpointInterval sets checkpoint of a pass to be saved after given number of entries verified or
+// given duration elapsed since last save, whichever is earlier. Default is 1000 entries or 30 seconds. Zero
+// value of either disables that condition; zero value of both saves checkpoint after each entry.
+func (scrubber *Scrubber) SetCheckpointInterval(entries int, duration time.Duration) {
+	scrubber.checkpointEntries = entries
+	scrubber.checkpointDuration = duration
+}
+
+// checkpointDue returns whether checkpoint is to be saved after given number of entries verified since last
+// save at given time.
+func (scrubber *Scrubber) checkpointDue(entries int, lastSaved time.Time) bool {
+	if scrubber.checkpointEntries <= 0 && scrubber.checkpointDuration <= 0 {
+		return true
+	}
+
+	if scrubber.checkpointEntries > 0 && entries >= scrubber.checkpointEntries {
+		return true
+	}
+
+	return scrubber.checkpointDuration > 0 && time.Since(lastSaved) >= scrubber.checkpointDuration
+}
+
+func (scrubber *Scrubber) loadCheckpoint() (*checkpoint, error) {
+	data, err := ioutil.ReadFile(scrubber.checkpointFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &checkpoint{}, nil
+		}
+
+		return nil, err
+	}
+
+	var cp checkpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+
+	return &cp, nil
+}
+
+func (scrubber *Scrubber) saveCheckpoint(cp *checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := scrubber.checkpointFile + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, scrubber.checkpointFile)
+}
+
+func readdirnames(dir string, dirOnly bool) ([]string, error) {
+	var names []string
+	picker := func(name string, mode os.FileMode) (stop bool) {
+		if !dirOnly || mode.IsDir() {
+			names = append(names, name)
+		}
+
+		return false
+	}
+
+	if err := xos.Readdirnames(dir, picker); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (scrubber *Scrubber) stopped() bool {
+	if scrubber.stopCh == nil {
+		return false
+	}
+
+	select {
+	case <-scrubber.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Scrub does one pass over the directory starting after checkpointed entry, if any; returns number of
+// entries verified in this call and corrupt entries found in whole pass.
+func (scrubber *Scrubber) Scrub() (count int, corrupt map[string]string, err error) {
+	cp, err := scrubber.loadCheckpoint()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if cp.Corrupt == nil {
+		cp.Corrupt = make(map[string]string)
+	}
+
+	if cp.Unrepaired == nil {
+		cp.Unrepaired = make(map[string]string)
+	}
+
+	indices, err := readdirnames(scrubber.dir, true)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	scrubber.limiter.reset()
+	throttle := func(reader io.Reader) io.Reader {
+		return &throttledReader{reader: reader, limiter: scrubber.limiter}
+	}
+
+	unsaved, lastSaved := 0, time.Now()
+	for _, index := range indices {
+		names, err := readdirnames(path.Join(scrubber.dir, index), true)
+		if err != nil {
+			return count, nil, err
+		}
+
+		for _, name := range names {
+			if name <= cp.LastName {
+				continue
+			}
+
+			if scrubber.stopped() {
+				if unsaved > 0 {
+					if err = scrubber.saveCheckpoint(cp); err != nil {
+						return count, nil, fmt.Errorf("unable to save checkpoint; %v", err)
+					}
+				}
+
+				return count, nil, ErrStopped
+			}
+
+			if verr := scrubber.verify(name, throttle); verr != nil {
+				cp.Corrupt[name] = verr.Error()
+				if scrubber.onCorrupt != nil {
+					if rerr := scrubber.onCorrupt(name, verr); rerr != nil {
+						cp.Unrepaired[name] = rerr.Error()
+					}
+				}
+			}
+
+			cp.LastName = name
+			count++
+			unsaved++
+
+			if scrubber.checkpointDue(unsaved, lastSaved) {
+				if err = scrubber.saveCheckpoint(cp); err != nil {
+					return count, nil, fmt.Errorf("unable to save checkpoint; %v", err)
+				}
+
+				unsaved, lastSaved = 0, time.Now()
+			}
+		}
+	}
+
+	if err = os.Remove(scrubber.checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return count, nil, err
+	}
+
+	scrubber.mutex.Lock()
+	scrubber.corrupt = cp.Corrupt
+	scrubber.unrepaired = cp.Unrepaired
+	scrubber.mutex.Unlock()
+
+	return count, cp.Corrupt, nil
+}
+
+// Corrupt returns corrupt entries, mapped to their errors, found by last completed pass.
+func (scrubber *Scrubber) Corrupt() map[string]string {
+	scrubber.mutex.Lock()
+	defer scrubber.mutex.Unlock()
+
+	return scrubber.corrupt
+}
+
+// Unrepaired returns corrupt entries, mapped to errors returned by onCorrupt, found by last completed pass.
+func (scrubber *Scrubber) Unrepaired() map[string]string {
+	scrubber.mutex.Lock()
+	defer scrubber.mutex.Unlock()
+
+	return scrubber.unrepaired
+}
+
+// Start runs scrubber in background at random intervals between minimum and maximum duration.
+func (scrubber *Scrubber) Start(minInterval, maxInterval time.Duration) {
+	scrubber.ticker = xtime.NewRandTicker(minInterval, maxInterval)
+	scrubber.stopCh = make(chan struct{})
+	scrubber.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(scrubber.doneCh)
+		for range scrubber.ticker.C {
+			scrubber.Scrub()
+		}
+	}()
+}
+
+// Stop stops background scrubber started by Start(). Running pass is interrupted after current entry, its
+// checkpoint is saved and it resumes from checkpoint on next start.
+func (scrubber *Scrubber) Stop() {
+	if scrubber.ticker != nil {
+		close(scrubber.stopCh)
+		scrubber.ticker.Stop()
+		<-scrubber.doneCh
+		scrubber.ticker = nil
+	}
+}
@@ -0,0 +1,171 @@
+package scrub
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestScrub(t *testing.T) {
+	dir := xrand.NewID(8).String()
+	names := []string{"ab1", "ab2", "cd1", "cd2", "cd3"}
+	for _, name := range names {
+		if err := os.MkdirAll(path.Join(dir, name[:2], name), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func() {
+		os.RemoveAll(dir)
+	}()
+
+	checkpointFile := path.Join(dir, "scrub.json")
+	var verified []string
+	var scrubber *Scrubber
+	stopAtCD1 := true
+	verify := func(name string, throttle func(io.Reader) io.Reader) error {
+		verified = append(verified, name)
+		if name == "cd1" && stopAtCD1 {
+			close(scrubber.stopCh)
+			stopAtCD1 = false
+		}
+
+		if name == "ab2" {
+			return errors.New("checksum mismatch")
+		}
+
+		return nil
+	}
+
+	var corruptNames []string
+	onCorrupt := func(name string, err error) error {
+		corruptNames = append(corruptNames, name)
+		return errors.New("heal failed")
+	}
+
+	// First pass is stopped after cd1.
+	scrubber = NewScrubber(dir, checkpointFile, 0, verify, onCorrupt)
+	scrubber.stopCh = make(chan struct{})
+	count, _, err := scrubber.Scrub()
+	if err != ErrStopped {
+		t.Fatalf("mismatch: expected: %v, got: %v", ErrStopped, err)
+	}
+
+	if count != 3 {
+		t.Fatalf("mismatch: count: expected: 3, got: %v", count)
+	}
+
+	// Restarted scrubber resumes after cd1.
+	verified = nil
+	scrubber = NewScrubber(dir, checkpointFile, 0, verify, onCorrupt)
+	count, corrupt, err := scrubber.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"cd2", "cd3"}; count != 2 || !reflect.DeepEqual(verified, expected) {
+		t.Fatalf("mismatch: expected: %v, got: %v", expected, verified)
+	}
+
+	expectedCorrupt := map[string]string{"ab2": "checksum mismatch"}
+	if !reflect.DeepEqual(corrupt, expectedCorrupt) || !reflect.DeepEqual(scrubber.Corrupt(), expectedCorrupt) {
+		t.Fatalf("mismatch: expected: %v, got: %v", expectedCorrupt, corrupt)
+	}
+
+	if !reflect.DeepEqual(corruptNames, []string{"ab2"}) {
+		t.Fatalf("mismatch: expected: [ab2], got: %v", corruptNames)
+	}
+
+	expectedUnrepaired := map[string]string{"ab2": "heal failed"}
+	if unrepaired := scrubber.Unrepaired(); !reflect.DeepEqual(unrepaired, expectedUnrepaired) {
+		t.Fatalf("mismatch: expected: %v, got: %v", expectedUnrepaired, unrepaired)
+	}
+
+	if _, err = os.Stat(checkpointFile); !os.IsNotExist(err) {
+		t.Fatalf("mismatch: expected: <checkpoint removed>, got: %v", err)
+	}
+
+	// Next pass starts over.
+	verified = nil
+	if count, _, err = scrubber.Scrub(); err != nil || count != len(names) {
+		t.Fatalf("mismatch: count: expected: %v, got: %v, %v", len(names), count, err)
+	}
+}
+
+func TestScrubRate(t *testing.T) {
+	dir := xrand.NewID(8).String()
+	if err := os.MkdirAll(path.Join(dir, "ab", "ab1"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll(dir)
+	}()
+
+	verify := func(name string, throttle func(io.Reader) io.Reader) error {
+		_, err := io.Copy(ioutil.Discard, throttle(strings.NewReader(strings.Repeat("a", 2000))))
+		return err
+	}
+
+	scrubber := NewScrubber(dir, path.Join(dir, "scrub.json"), 10000, verify, nil)
+	start := time.Now()
+	if _, _, err := scrubber.Scrub(); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("mismatch: elapsed: expected: >= 150ms, got: %v", elapsed)
+	}
+}
+
+func TestScrubCheckpointInterval(t *testing.T) {
+	dir := xrand.NewID(8).String()
+	names := []string{"ab1", "ab2", "cd1", "cd2", "cd3"}
+	for _, name := range names {
+		if err := os.MkdirAll(path.Join(dir, name[:2], name), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func() {
+		os.RemoveAll(dir)
+	}()
+
+	checkpointFile := path.Join(dir, "scrub.json")
+	scrubber := NewScrubber(dir, checkpointFile, 0, nil, nil)
+	scrubber.SetCheckpointInterval(2, 0)
+
+	// Checkpoint seen while verifying each entry; saved after every two entries.
+	lastNames := map[string]string{}
+	scrubber.verify = func(name string, throttle func(io.Reader) io.Reader) error {
+		cp, err := scrubber.loadCheckpoint()
+		if err != nil {
+			return err
+		}
+
+		lastNames[name] = cp.LastName
+		return nil
+	}
+
+	count, _, err := scrubber.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != len(names) {
+		t.Fatalf("mismatch: count: expected: %v, got: %v", len(names), count)
+	}
+
+	expected := map[string]string{"ab1": "", "ab2": "", "cd1": "ab2", "cd2": "ab2", "cd3": "cd2"}
+	if !reflect.DeepEqual(lastNames, expected) {
+		t.Fatalf("mismatch: expected: %v, got: %v", expected, lastNames)
+	}
+}
@@ -0,0 +1,146 @@
+package trash
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	xos "github.com/balamurugana/goat/pkg/os"
+	xtime "github.com/balamurugana/goat/pkg/time"
+)
+
+// Reaper removes entries of a trash directory once they stay in trash longer than grace period.
+// Grace period is counted from the time an entry is first seen by the reaper, hence any Revert*()
+// call made within grace period still finds its entry in trash.
+type Reaper struct {
+	dir         string
+	gracePeriod time.Duration
+	skip        func(name string) bool
+
+	mutex     sync.Mutex
+	seen      map[string]time.Time
+	reclaimed uint64
+
+	ticker *xtime.RandTicker
+	doneCh chan struct{}
+}
+
+// NewReaper creates new reaper of trash directory. If skip function is not nil, it is called for
+// each entry and the entry is left in trash if it returns true e.g. entry is still in use.
+func NewReaper(dir string, gracePeriod time.Duration, skip func(name string) bool) *Reaper {
+	return &Reaper{
+		dir:         dir,
+		gracePeriod: gracePeriod,
+		skip:        skip,
+		seen:        make(map[string]time.Time),
+	}
+}
+
+func entrySize(name string) (size uint64) {
+	filepath.Walk(name, func(_ string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() {
+			size += uint64(fi.Size())
+		}
+
+		return nil
+	})
+
+	return size
+}
+
+// Reap does one pass over trash directory and removes expired entries; returns number of
+// entries removed and bytes reclaimed.
+func (reaper *Reaper) Reap() (count int, bytes uint64, err error) {
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+
+	names := make(map[string]struct{})
+	picker := func(name string, mode os.FileMode) (stop bool) {
+		names[name] = struct{}{}
+		return false
+	}
+
+	if err = xos.Readdirnames(reaper.dir, picker); err != nil {
+		return 0, 0, err
+	}
+
+	// Forget entries which are gone from trash e.g. reverted.
+	for name := range reaper.seen {
+		if _, found := names[name]; !found {
+			delete(reaper.seen, name)
+		}
+	}
+
+	var errs []error
+	now := time.Now()
+	for name := range names {
+		seenAt, found := reaper.seen[name]
+		if !found {
+			reaper.seen[name] = now
+			seenAt = now
+		}
+
+		if now.Sub(seenAt) < reaper.gracePeriod {
+			continue
+		}
+
+		if reaper.skip != nil && reaper.skip(name) {
+			continue
+		}
+
+		entry := path.Join(reaper.dir, name)
+		size := entrySize(entry)
+		if rerr := os.RemoveAll(entry); rerr != nil {
+			errs = append(errs, rerr)
+			continue
+		}
+
+		delete(reaper.seen, name)
+		count++
+		bytes += size
+	}
+
+	atomic.AddUint64(&reaper.reclaimed, bytes)
+
+	switch len(errs) {
+	case 0:
+	case 1:
+		err = errs[0]
+	default:
+		err = fmt.Errorf("multiple remove error; %v", errs)
+	}
+
+	return count, bytes, err
+}
+
+// Reclaimed returns total bytes reclaimed by this reaper.
+func (reaper *Reaper) Reclaimed() uint64 {
+	return atomic.LoadUint64(&reaper.reclaimed)
+}
+
+// Start runs reaper in background at random intervals between minimum and maximum duration.
+// Random interval avoids running reapers of all disks in lockstep.
+func (reaper *Reaper) Start(minInterval, maxInterval time.Duration) {
+	reaper.ticker = xtime.NewRandTicker(minInterval, maxInterval)
+	reaper.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(reaper.doneCh)
+		for range reaper.ticker.C {
+			reaper.Reap()
+		}
+	}()
+}
+
+// Stop stops background reaper started by Start() and waits for running pass to finish.
+func (reaper *Reaper) Stop() {
+	if reaper.ticker != nil {
+		reaper.ticker.Stop()
+		<-reaper.doneCh
+		reaper.ticker = nil
+	}
+}
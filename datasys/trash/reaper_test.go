@@ -0,0 +1,100 @@
+package trash
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func populate(t *testing.T, trashDir string) {
+	if err := os.Mkdir(path.Join(trashDir, "upload"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path.Join(trashDir, "upload", "1.part"), make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path.Join(trashDir, "upload", "2.part"), make([]byte, 24), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path.Join(trashDir, "bucket.acl.json"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path.Join(trashDir, "busy"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaperReap(t *testing.T) {
+	trashDir := xrand.NewID(8).String()
+	if err := os.Mkdir(trashDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(trashDir)
+
+	populate(t, trashDir)
+
+	skip := func(name string) bool {
+		return name == "busy"
+	}
+
+	reaper := NewReaper(trashDir, 50*time.Millisecond, skip)
+
+	count, bytes, err := reaper.Reap()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 || bytes != 0 {
+		t.Fatalf("within grace period: expected: 0, 0, got: %v, %v", count, bytes)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if count, bytes, err = reaper.Reap(); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 || bytes != 1124 {
+		t.Fatalf("after grace period: expected: 2, 1124, got: %v, %v", count, bytes)
+	}
+
+	if reaper.Reclaimed() != 1124 {
+		t.Fatalf("reclaimed: expected: 1124, got: %v", reaper.Reclaimed())
+	}
+
+	fis, err := ioutil.ReadDir(trashDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fis) != 1 || fis[0].Name() != "busy" {
+		t.Fatalf("expected only busy entry left, got: %v", fis)
+	}
+}
+
+func TestReaperStart(t *testing.T) {
+	trashDir := xrand.NewID(8).String()
+	if err := os.Mkdir(trashDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(trashDir)
+
+	populate(t, trashDir)
+
+	reaper := NewReaper(trashDir, 0, nil)
+	reaper.Start(10*time.Millisecond, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	reaper.Stop()
+
+	if reaper.Reclaimed() != 1134 {
+		t.Fatalf("reclaimed: expected: 1134, got: %v", reaper.Reclaimed())
+	}
+}
@@ -36,6 +36,11 @@ func isNetError(err error) bool {
 
 var globalRPCAPIVersion = RPCVersion{1, 0, 0}
 
+// APIVersion - returns RPC version accepted by AuthArgs.Authenticate().
+func APIVersion() RPCVersion {
+	return globalRPCAPIVersion
+}
+
 // RPCVersion - RPC semantic version based on semver 2.0.0 https://semver.org/.
 type RPCVersion struct {
 	Major uint64
@@ -0,0 +1,172 @@
+package erasure
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// GetShardReaderAt function type returns random access reader of whole shard of shardID.
+type GetShardReaderAt func(shardID string) (io.ReaderAt, error)
+
+// ReaderAt is random access reader returned by NewReaderAt(). ShardErrors() returns shards failed so far.
+type ReaderAt interface {
+	io.ReaderAt
+	ShardErrors() []ShardError
+}
+
+type decodeReaderAt struct {
+	getShardReaderAt GetShardReaderAt
+	info             *Info
+	decoder          reedsolomon.Encoder
+	blockCount       uint64
+	blockSize        uint64
+	lastShardSize    uint64
+
+	mutex   sync.Mutex
+	readers []io.ReaderAt
+	errs    []error
+	shards  [][]byte
+}
+
+// readShards reads shards of given block from shard readers not failed yet until DataCount shards are available.
+func (dr *decodeReaderAt) readShards(index, shardSize uint64) error {
+	for i := range dr.shards {
+		dr.shards[i] = dr.shards[i][:0]
+	}
+
+	successCount := uint64(0)
+	next := uint64(0)
+	for successCount < dr.info.DataCount {
+		var ids []uint64
+		for ; next < uint64(len(dr.shards)) && uint64(len(ids)) < dr.info.DataCount-successCount; next++ {
+			if dr.errs[next] == nil {
+				ids = append(ids, next)
+			}
+		}
+
+		if len(ids) == 0 {
+			return fmt.Errorf("too many read errors; %v", dr.errs)
+		}
+
+		var wg sync.WaitGroup
+		for _, i := range ids {
+			wg.Add(1)
+			go func(i uint64) {
+				defer wg.Done()
+				if dr.readers[i] == nil {
+					if dr.readers[i], dr.errs[i] = dr.getShardReaderAt(dr.info.ShardIDs[i]); dr.errs[i] != nil {
+						return
+					}
+				}
+
+				shard := dr.shards[i][:shardSize]
+				if _, dr.errs[i] = dr.readers[i].ReadAt(shard, int64(index*dr.info.ShardSize)); dr.errs[i] == nil {
+					dr.shards[i] = shard
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		for _, i := range ids {
+			if dr.errs[i] == nil {
+				successCount++
+			}
+		}
+	}
+
+	if next > dr.info.DataCount {
+		return dr.decoder.ReconstructData(dr.shards)
+	}
+
+	return nil
+}
+
+// ReadAt reads len(b) bytes from offset off. Only blocks covering the range are read and decoded.
+func (dr *decodeReaderAt) ReadAt(b []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+
+	for n < len(b) {
+		if uint64(off) >= dr.info.Size {
+			return n, io.EOF
+		}
+
+		index := uint64(off) / dr.blockSize
+		shardSize := dr.info.ShardSize
+		if index == dr.blockCount-1 {
+			shardSize = dr.lastShardSize
+		}
+
+		if err = dr.readShards(index, shardSize); err != nil {
+			return n, err
+		}
+
+		blockEnd := (index + 1) * dr.blockSize
+		if blockEnd > dr.info.Size {
+			blockEnd = dr.info.Size
+		}
+
+		for pos := uint64(off) - index*dr.blockSize; n < len(b) && index*dr.blockSize+pos < blockEnd; {
+			shard := dr.shards[pos/shardSize][pos%shardSize:]
+			if remaining := blockEnd - index*dr.blockSize - pos; uint64(len(shard)) > remaining {
+				shard = shard[:remaining]
+			}
+
+			copied := copy(b[n:], shard)
+			n += copied
+			pos += uint64(copied)
+			off += int64(copied)
+		}
+	}
+
+	return n, nil
+}
+
+// ShardErrors returns shards failed so far.
+func (dr *decodeReaderAt) ShardErrors() []ShardError {
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+
+	return getShardErrors(dr.info.ShardIDs, dr.errs)
+}
+
+// NewReaderAt returns random access reader of data encoded by Write(). Shard readers are opened on demand
+// and a failed shard reader is not used again. *InfoError is returned if info is invalid.
+func NewReaderAt(getShardReaderAt GetShardReaderAt, info *Info) (ReaderAt, error) {
+	if err := validateReadInfo(info); err != nil {
+		return nil, err
+	}
+
+	count := info.DataCount + info.ParityCount
+	decoder, err := reedsolomon.New(int(info.DataCount), int(info.ParityCount))
+	if err != nil {
+		return nil, err
+	}
+
+	blockCount, blockSize, _, lastShardSize := info.Compute()
+
+	shards := make([][]byte, count)
+	for i := range shards {
+		shards[i] = make([]byte, 0, info.ShardSize)
+	}
+
+	return &decodeReaderAt{
+		getShardReaderAt: getShardReaderAt,
+		info:             info,
+		decoder:          decoder,
+		blockCount:       blockCount,
+		blockSize:        blockSize,
+		lastShardSize:    lastShardSize,
+		readers:          make([]io.ReaderAt, count),
+		errs:             make([]error, count),
+		shards:           shards,
+	}, nil
+}
@@ -13,6 +13,30 @@ import (
 // GetShardReader function type returns reader for shardID with limits of offset and length.
 type GetShardReader func(shardID string, offset, length int64) (io.Reader, error)
 
+// ShardError is error of a shard failed to open or read while decoding.
+type ShardError struct {
+	ShardID string
+	Err     error
+}
+
+// Reader is reader returned by NewReader(). If some shards fail, data is decoded from parity shards and
+// failed shards are reported by ShardErrors() so that caller can log, count or heal them.
+type Reader interface {
+	io.Reader
+	ShardErrors() []ShardError
+}
+
+// getShardErrors returns errors of failed shards in order of shardIDs.
+func getShardErrors(shardIDs []string, errs []error) (shardErrs []ShardError) {
+	for i, err := range errs {
+		if err != nil {
+			shardErrs = append(shardErrs, ShardError{ShardID: shardIDs[i], Err: err})
+		}
+	}
+
+	return shardErrs
+}
+
 type decodeReader struct {
 	readersLen     uint64
 	getShardReader GetShardReader
@@ -165,10 +189,11 @@ func (dr *decodeReader) readBlock() error {
 	if dr.index == dr.blocksToRead-1 {
 		dr.bytesAvailable = dr.bytesToReadInLastBlock
 
+		// First shard is already shortened by bytes skipped if first block is also last block.
 		var i uint64
 		for i = dr.shardIndex; i < dr.info.DataCount; i++ {
-			if dr.bytesToReadInLastBlock > dr.shardSize {
-				dr.bytesToReadInLastBlock -= dr.shardSize
+			if shardLength := uint64(len(dr.shards[i])); dr.bytesToReadInLastBlock > shardLength {
+				dr.bytesToReadInLastBlock -= shardLength
 			} else {
 				dr.shards[i] = dr.shards[i][:dr.bytesToReadInLastBlock]
 				i++
@@ -209,31 +234,26 @@ func (dr *decodeReader) Read(b []byte) (n int, err error) {
 	return n, nil
 }
 
-// NewReader reads data shards from readers and exposes a reader.
-func NewReader(getShardReader GetShardReader, shards [][]byte, info *Info, offset int64, length uint64) (io.Reader, error) {
-	count := info.DataCount + info.ParityCount
-
-	if uint64(len(shards)) != count {
-		panic("len(shards) != info.DataCount+info.ParityCount")
-	}
-
-	for i, shard := range shards {
-		if uint64(len(shard)) != info.ShardSize {
-			panic(fmt.Errorf("len(shards[%v]) != info.ShardSize", i))
-		}
-	}
+// ShardErrors returns shards failed so far.
+func (dr *decodeReader) ShardErrors() []ShardError {
+	return getShardErrors(dr.info.ShardIDs, dr.errs)
+}
 
-	if uint64(len(info.ShardIDs)) != count {
-		panic("len(info.ShardIDs) != info.DataCount+info.ParityCount")
+// NewReader reads data shards from readers and exposes a reader. *InfoError is returned if info or shards are
+// invalid.
+func NewReader(getShardReader GetShardReader, shards [][]byte, info *Info, offset int64, length uint64) (Reader, error) {
+	if err := validateReadInfo(info); err != nil {
+		return nil, err
 	}
 
-	if dups := getDuplicates(info.ShardIDs); dups != nil {
-		panic(fmt.Errorf("duplicate IDs %v found in info.ShardIDs", dups))
+	if err := validateShards(shards, info); err != nil {
+		return nil, err
 	}
 
+	count := info.DataCount + info.ParityCount
 	decoder, err := reedsolomon.New(int(info.DataCount), int(info.ParityCount))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	if offset < 0 {
@@ -244,7 +264,7 @@ func NewReader(getShardReader GetShardReader, shards [][]byte, info *Info, offse
 		return nil, errors.New("insufficient data")
 	}
 
-	if uint64(offset)+length > info.Size {
+	if length > info.Size || uint64(offset) > info.Size-length {
 		return nil, errors.New("insufficient data")
 	}
 
@@ -13,12 +13,31 @@ import (
 	xrand "github.com/balamurugana/goat/pkg/rand"
 )
 
+func checkShardErrors(t *testing.T, shardErrs []ShardError, info *Info, missingShards []int) {
+	var expected, got []string
+	for _, j := range missingShards {
+		expected = append(expected, info.ShardIDs[j])
+	}
+
+	for _, shardErr := range shardErrs {
+		if shardErr.Err == nil {
+			t.Fatalf("%v: expected: <error>, got: <nil>", shardErr.ShardID)
+		}
+		got = append(got, shardErr.ShardID)
+	}
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("mismatch: shard errors: expected: %v, got: %v", expected, got)
+	}
+}
+
 func TestNewReader(t *testing.T) {
 	testCases := []struct {
-		info     *Info
-		offset   int64
-		length   uint64
-		checksum string
+		info          *Info
+		offset        int64
+		length        uint64
+		checksum      string
+		missingShards []int
 	}{
 		{
 			info: &Info{
@@ -53,6 +72,18 @@ func TestNewReader(t *testing.T) {
 			length:   1048986,
 			checksum: "3faf5850c140d6f2ad36e0ba7324d306e1589d50fc17fa0cc1a1ccbf76d87332",
 		},
+		{
+			info: &Info{
+				DataCount:   3,
+				ParityCount: 2,
+				Size:        70009289,
+				ShardSize:   MiB,
+			},
+			offset:        3145649,
+			length:        1048986,
+			checksum:      "3faf5850c140d6f2ad36e0ba7324d306e1589d50fc17fa0cc1a1ccbf76d87332",
+			missingShards: []int{0, 2},
+		},
 	}
 
 	for i, testCase := range testCases {
@@ -63,6 +94,12 @@ func TestNewReader(t *testing.T) {
 				defer os.RemoveAll(dirname)
 				testWrite(t, testCase.info, dirname)
 
+				for _, j := range testCase.missingShards {
+					if err := os.Remove(testCase.info.ShardIDs[j]); err != nil {
+						t.Fatal(err)
+					}
+				}
+
 				files := map[string]*os.File{}
 				filesMutex := sync.Mutex{}
 				getShardReader := func(shardID string, offset, length int64) (io.Reader, error) {
@@ -102,6 +139,92 @@ func TestNewReader(t *testing.T) {
 				if !reflect.DeepEqual(checksum, testCase.checksum) {
 					t.Fatalf("expected: %v, got: %v", testCase.checksum, checksum)
 				}
+
+				checkShardErrors(t, reader.ShardErrors(), testCase.info, testCase.missingShards)
+			},
+		)
+	}
+}
+
+func TestNewReaderAt(t *testing.T) {
+	testCases := []struct {
+		info          *Info
+		missingShards []int
+	}{
+		{&Info{DataCount: 1, ParityCount: 3, Size: 32283, ShardSize: 4096}, nil},
+		{&Info{DataCount: 2, ParityCount: 2, Size: 70009289, ShardSize: MiB}, nil},
+		{&Info{DataCount: 2, ParityCount: 2, Size: 70009289, ShardSize: MiB}, []int{0}},
+		{&Info{DataCount: 3, ParityCount: 2, Size: 70009289, ShardSize: MiB}, []int{1, 2}},
+	}
+
+	ranges := []struct {
+		offset, length int64
+		checksum       string
+	}{
+		{3145649, 1048986, "3faf5850c140d6f2ad36e0ba7324d306e1589d50fc17fa0cc1a1ccbf76d87332"},
+		{10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
+		{0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				dirname := xrand.NewID(8).String()
+				defer os.RemoveAll(dirname)
+				testWrite(t, testCase.info, dirname)
+
+				for _, j := range testCase.missingShards {
+					if err := os.Remove(testCase.info.ShardIDs[j]); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				files := map[string]*os.File{}
+				filesMutex := sync.Mutex{}
+				getShardReaderAt := func(shardID string) (io.ReaderAt, error) {
+					file, err := os.Open(shardID)
+					if err != nil {
+						return nil, err
+					}
+
+					filesMutex.Lock()
+					files[shardID] = file
+					filesMutex.Unlock()
+					return file, nil
+				}
+
+				defer func() {
+					for _, file := range files {
+						file.Close()
+					}
+				}()
+
+				readerAt, err := NewReaderAt(getShardReaderAt, testCase.info)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, r := range ranges {
+					if uint64(r.offset+r.length) > testCase.info.Size {
+						continue
+					}
+
+					hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+					if _, err := io.Copy(hasher, io.NewSectionReader(readerAt, r.offset, r.length)); err != nil {
+						t.Fatal(err)
+					}
+
+					if checksum := hasher.HexSum(nil); checksum != r.checksum {
+						t.Fatalf("range %v-%v: expected: %v, got: %v", r.offset, r.length, r.checksum, checksum)
+					}
+				}
+
+				if _, err := readerAt.ReadAt(make([]byte, 10), int64(testCase.info.Size)-5); err != io.EOF {
+					t.Fatalf("mismatch: expected: %v, got: %v", io.EOF, err)
+				}
+
+				checkShardErrors(t, readerAt.ShardErrors(), testCase.info, testCase.missingShards)
 			},
 		)
 	}
@@ -13,58 +13,121 @@ import (
 // GetShardWriter function type returns a writer for shardID.
 type GetShardWriter func(shardID string) (io.Writer, error)
 
-// Write reads block of data using shards from reader with info; then erasure encodes and writes each shards into individual writers;
-// returns error if successful writer count is less than minSuccessWriters.
-func Write(getShardWriter GetShardWriter, shards [][]byte, info *Info, reader io.Reader, minSuccessWriters uint64) ([]string, string, error) {
-	count := info.DataCount + info.ParityCount
-
-	if uint64(len(shards)) != count {
-		panic("len(shards) != info.DataCount+info.ParityCount")
-	}
+// shardWriters writes encoded shards of each block to writers of shards and hashes them. A writer failed once
+// is not written any more.
+type shardWriters struct {
+	writers    []io.Writer
+	hashers    []xhash.Hash
+	errs       []error
+	minSuccess uint64
+}
 
-	for i, shard := range shards {
-		if uint64(len(shard)) != info.ShardSize {
-			panic(fmt.Errorf("len(shards[%v]) != info.ShardSize", i))
-		}
+func newShardWriters(getShardWriter GetShardWriter, info *Info, minSuccessWriters uint64) (*shardWriters, error) {
+	count := len(info.ShardIDs)
+	sw := &shardWriters{
+		writers:    make([]io.Writer, count),
+		hashers:    make([]xhash.Hash, count),
+		errs:       make([]error, count),
+		minSuccess: minSuccessWriters,
 	}
 
-	if uint64(len(info.ShardIDs)) != count {
-		panic("len(info.ShardIDs) != info.DataCount+info.ParityCount")
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sw.writers[i], sw.errs[i] = getShardWriter(info.ShardIDs[i])
+		}(i)
 	}
+	wg.Wait()
 
-	if dups := getDuplicates(info.ShardIDs); dups != nil {
-		panic(fmt.Errorf("duplicate IDs %v found in info.ShardIDs", dups))
+	successCount := uint64(0)
+	for i := 0; i < count; i++ {
+		if sw.errs[i] == nil {
+			successCount++
+		}
+		sw.hashers[i] = xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
 	}
 
-	encoder, err := reedsolomon.New(int(info.DataCount), int(info.ParityCount))
-	if err != nil {
-		panic(err)
+	if successCount < minSuccessWriters {
+		return nil, fmt.Errorf("multiple error on getShardWriter(); %v", sw.errs)
 	}
 
-	shardSize := info.ShardSize
-	blockCount, blockSize, lastBlockSize, lastShardSize := info.Compute()
+	return sw, nil
+}
 
-	writers := make([]io.Writer, count)
-	errs := make([]error, len(writers))
+func (sw *shardWriters) write(shards [][]byte) error {
 	var wg sync.WaitGroup
-	for i := uint64(0); i < count; i++ {
+	for i := 0; i < len(sw.writers); i++ {
 		wg.Add(1)
-		go func(i uint64) {
+		go func(i int) {
 			defer wg.Done()
-			writers[i], errs[i] = getShardWriter(info.ShardIDs[i])
+			if sw.writers[i] != nil {
+				if _, sw.errs[i] = sw.writers[i].Write(shards[i]); sw.errs[i] == nil {
+					sw.hashers[i].Write(shards[i])
+					return
+				}
+
+				sw.writers[i] = nil
+				sw.hashers[i] = nil
+			}
+
+			if sw.errs[i] == nil {
+				sw.errs[i] = errors.New("nil writer")
+			}
 		}(i)
 	}
 	wg.Wait()
 
 	successCount := uint64(0)
-	for i := uint64(0); i < count; i++ {
-		if errs[i] == nil {
+	for i := 0; i < len(sw.errs); i++ {
+		if sw.errs[i] == nil {
 			successCount++
 		}
 	}
 
-	if successCount < minSuccessWriters {
-		return nil, "", fmt.Errorf("multiple error on getShardWriter(); %v", errs)
+	if successCount < sw.minSuccess {
+		return fmt.Errorf("too many write errors; %v", sw.errs)
+	}
+
+	return nil
+}
+
+// sums returns checksum of each shard written; it is empty for failed writers.
+func (sw *shardWriters) sums() []string {
+	shardSums := make([]string, len(sw.hashers))
+	for i := range sw.hashers {
+		if sw.hashers[i] != nil {
+			shardSums[i] = sw.hashers[i].HexSum(nil)
+		}
+	}
+
+	return shardSums
+}
+
+// Write reads block of data using shards from reader with info; then erasure encodes and writes each shards into individual writers;
+// returns error if successful writer count is less than minSuccessWriters, or *InfoError if info or shards are invalid. If info.Size is UnknownSize, data is read until EOF and
+// info.Size is set to size of data read.
+func Write(getShardWriter GetShardWriter, shards [][]byte, info *Info, reader io.Reader, minSuccessWriters uint64) ([]string, string, error) {
+	if err := info.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	if err := validateShards(shards, info); err != nil {
+		return nil, "", err
+	}
+
+	encoder, err := reedsolomon.New(int(info.DataCount), int(info.ParityCount))
+	if err != nil {
+		return nil, "", err
+	}
+
+	shardSize := info.ShardSize
+	blockCount, blockSize, lastBlockSize, lastShardSize := info.Compute()
+
+	sw, err := newShardWriters(getShardWriter, info, minSuccessWriters)
+	if err != nil {
+		return nil, "", err
 	}
 
 	clear := func(b []byte, offset, length uint64) {
@@ -98,86 +161,88 @@ func Write(getShardWriter GetShardWriter, shards [][]byte, info *Info, reader io
 		return nil
 	}
 
-	shardHashers := make([]xhash.Hash, count)
-	for i := range shardHashers {
-		shardHashers[i] = xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
-	}
-	writeShards := func() error {
-		var wg sync.WaitGroup
-		for i := 0; i < len(writers); i++ {
-			wg.Add(1)
-			go func(i int) {
-				defer wg.Done()
-				if writers[i] != nil {
-					if _, errs[i] = writers[i].Write(shards[i]); errs[i] == nil {
-						shardHashers[i].Write(shards[i])
-						return
-					}
-
-					writers[i] = nil
-					shardHashers[i] = nil
-				}
-
-				if errs[i] == nil {
-					errs[i] = errors.New("nil writer")
-				}
-			}(i)
+	encodeShards := func() error {
+		if err := encoder.Encode(shards); err != nil {
+			return err
 		}
-		wg.Wait()
 
-		successCount := uint64(0)
-		for i := 0; i < len(errs); i++ {
-			if errs[i] == nil {
-				successCount++
-			}
+		ok, err := encoder.Verify(shards)
+		if err != nil {
+			return err
 		}
-
-		if successCount < minSuccessWriters {
-			return fmt.Errorf("too many write errors; %v", errs)
+		if !ok {
+			return fmt.Errorf("verification failed on encoded shards")
 		}
 
-		return nil
+		return sw.write(shards)
 	}
 
-	for i := uint64(0); i < blockCount; i++ {
-		if i == blockCount-1 {
-			blockSize = lastBlockSize
+	if info.Size == UnknownSize {
+		// Size of a block is known after it is read, hence it is read fully before splitting into data shards.
+		block := make([]byte, blockSize)
+		size := uint64(0)
+		for {
+			n, err := io.ReadFull(reader, block)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, "", err
+			}
 
-			if shardSize != lastShardSize {
-				shardSize = lastShardSize
+			dataHasher.Write(block[:n])
+			size += uint64(n)
+
+			if uint64(n) < blockSize {
+				shardSize = (uint64(n) + info.DataCount - 1) / info.DataCount
 				for s := range shards {
 					shards[s] = shards[s][:shardSize]
 				}
 			}
-		}
 
-		if err := readDataShards(); err != nil {
-			return nil, "", err
-		}
+			for s := uint64(0); s < info.DataCount; s++ {
+				offset := s * shardSize
+				if offset > uint64(n) {
+					offset = uint64(n)
+				}
 
-		if err := encoder.Encode(shards); err != nil {
-			return nil, "", err
-		}
+				copied := uint64(copy(shards[s], block[offset:n]))
+				clear(shards[s], copied, shardSize)
+			}
 
-		ok, err := encoder.Verify(shards)
-		if err != nil {
-			return nil, "", err
-		}
-		if !ok {
-			return nil, "", fmt.Errorf("verification failed on encoded shards")
-		}
+			if err := encodeShards(); err != nil {
+				return nil, "", err
+			}
 
-		if err := writeShards(); err != nil {
-			return nil, "", err
+			if uint64(n) < blockSize {
+				break
+			}
 		}
-	}
 
-	shardSums := make([]string, count)
-	for i := range shardHashers {
-		if shardHashers[i] != nil {
-			shardSums[i] = shardHashers[i].HexSum(nil)
+		info.Size = size
+	} else {
+		for i := uint64(0); i < blockCount; i++ {
+			if i == blockCount-1 {
+				blockSize = lastBlockSize
+
+				if shardSize != lastShardSize {
+					shardSize = lastShardSize
+					for s := range shards {
+						shards[s] = shards[s][:shardSize]
+					}
+				}
+			}
+
+			if err := readDataShards(); err != nil {
+				return nil, "", err
+			}
+
+			if err := encodeShards(); err != nil {
+				return nil, "", err
+			}
 		}
 	}
 
-	return shardSums, dataHasher.HexSum(nil), nil
+	return sw.sums(), dataHasher.HexSum(nil), nil
 }
@@ -1,6 +1,7 @@
 package erasure
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"math/rand"
@@ -262,3 +263,76 @@ func TestWrite(t *testing.T) {
 		)
 	}
 }
+
+func TestWriteUnknownSize(t *testing.T) {
+	testCases := []struct {
+		info *Info
+	}{
+		{&Info{DataCount: 4, ParityCount: 2, Size: 32283, ShardSize: 1024}},
+		{&Info{DataCount: 4, ParityCount: 2, Size: 8192, ShardSize: 1024}},
+		{&Info{DataCount: 3, ParityCount: 2, Size: 1000, ShardSize: 1024}},
+		{&Info{DataCount: 1, ParityCount: 3, Size: 0, ShardSize: 1024}},
+	}
+
+	write := func(info *Info, reader io.Reader) (map[string][]byte, []string, string) {
+		count := info.DataCount + info.ParityCount
+		info.ShardIDs = make([]string, count)
+		buffers := make(map[string]*bytes.Buffer)
+		for i := range info.ShardIDs {
+			info.ShardIDs[i] = fmt.Sprintf("shard.%v", i)
+			buffers[info.ShardIDs[i]] = new(bytes.Buffer)
+		}
+
+		getShardWriter := func(shardID string) (io.Writer, error) {
+			return buffers[shardID], nil
+		}
+
+		shards := make([][]byte, count)
+		for i := range shards {
+			shards[i] = make([]byte, info.ShardSize)
+		}
+
+		shardChecksums, checksum, err := Write(getShardWriter, shards, info, reader, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := make(map[string][]byte)
+		for shardID, buffer := range buffers {
+			data[shardID] = buffer.Bytes()
+		}
+
+		return data, shardChecksums, checksum
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				size := testCase.info.Size
+				expectedShards, expectedShardChecksums, expectedChecksum := write(testCase.info, io.LimitReader(randReader(), int64(size)))
+
+				info := &Info{DataCount: testCase.info.DataCount, ParityCount: testCase.info.ParityCount, Size: UnknownSize, ShardSize: testCase.info.ShardSize}
+				shards, shardChecksums, checksum := write(info, io.LimitReader(randReader(), int64(size)))
+
+				if info.Size != size {
+					t.Fatalf("mismatch: size: expected: %v, got: %v", size, info.Size)
+				}
+
+				if checksum != expectedChecksum {
+					t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+				}
+
+				if !reflect.DeepEqual(shardChecksums, expectedShardChecksums) {
+					t.Fatalf("mismatch: shard checksums: expected: %v, got: %v", expectedShardChecksums, shardChecksums)
+				}
+
+				for shardID, data := range expectedShards {
+					if !bytes.Equal(shards[shardID], data) {
+						t.Fatalf("%v: mismatch: shard data differs", shardID)
+					}
+				}
+			},
+		)
+	}
+}
@@ -1,5 +1,39 @@
 package erasure
 
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// MaxShards is maximum number of data and parity shards of Info.
+const MaxShards = 256
+
+// Errors of invalid Info returned by Info.Validate(), Write() and readers wrapped in *InfoError.
+var (
+	ErrZeroDataCount     = errors.New("data count is zero")
+	ErrTooManyShards     = errors.New("too many shards")
+	ErrZeroShardSize     = errors.New("shard size is zero")
+	ErrSizeOverflow      = errors.New("size overflow")
+	ErrShardIDsCount     = errors.New("shard IDs count mismatch")
+	ErrDuplicateShardIDs = errors.New("duplicate shard IDs")
+	ErrShardsMismatch    = errors.New("shards mismatch")
+)
+
+// InfoError is error of invalid Info. Err is one of Err* errors of this package; errors.Is() matches it.
+type InfoError struct {
+	Err    error
+	Detail string
+}
+
+func (e *InfoError) Error() string {
+	return fmt.Sprintf("invalid erasure info: %v; %v", e.Err, e.Detail)
+}
+
+func (e *InfoError) Unwrap() error {
+	return e.Err
+}
+
 func getDuplicates(sl []string) (dups []string) {
 	m := map[string]struct{}{}
 	for _, s := range sl {
@@ -13,6 +47,10 @@ func getDuplicates(sl []string) (dups []string) {
 	return dups
 }
 
+// UnknownSize is Info.Size of data whose size is known only at EOF. Write() encodes such data block by block
+// until EOF and sets Info.Size. It is same as UnknownSize of pkg/os so that it is passed through to shard files.
+const UnknownSize = math.MaxUint64
+
 type Info struct {
 	DataCount   uint64 `json:"dataCount"`
 	ParityCount uint64 `json:"parityCount"`
@@ -32,6 +70,60 @@ type Info struct {
 	ShardIDs []string `json:"shardIDs"`
 }
 
+// Validate returns *InfoError if info can not be encoded or decoded. Size may be UnknownSize.
+func (info Info) Validate() error {
+	switch {
+	case info.DataCount == 0:
+		return &InfoError{ErrZeroDataCount, "DataCount must be positive"}
+	case info.DataCount > MaxShards || info.ParityCount > MaxShards || info.DataCount+info.ParityCount > MaxShards:
+		return &InfoError{ErrTooManyShards, fmt.Sprintf("DataCount %v + ParityCount %v exceeds %v", info.DataCount, info.ParityCount, MaxShards)}
+	case info.ShardSize == 0:
+		return &InfoError{ErrZeroShardSize, "ShardSize must be positive"}
+	case info.ShardSize > math.MaxInt32:
+		return &InfoError{ErrSizeOverflow, fmt.Sprintf("ShardSize %v exceeds %v", info.ShardSize, math.MaxInt32)}
+	case info.Size > math.MaxInt64 && info.Size != UnknownSize:
+		return &InfoError{ErrSizeOverflow, fmt.Sprintf("Size %v exceeds %v", info.Size, int64(math.MaxInt64))}
+	case uint64(len(info.ShardIDs)) != info.DataCount+info.ParityCount:
+		return &InfoError{ErrShardIDsCount, fmt.Sprintf("len(ShardIDs) %v != DataCount+ParityCount %v", len(info.ShardIDs), info.DataCount+info.ParityCount)}
+	}
+
+	if dups := getDuplicates(info.ShardIDs); dups != nil {
+		return &InfoError{ErrDuplicateShardIDs, fmt.Sprintf("%v found in ShardIDs", dups)}
+	}
+
+	return nil
+}
+
+// validateReadInfo returns *InfoError if data of info can not be read, i.e. info is invalid or its size is
+// unknown.
+func validateReadInfo(info *Info) error {
+	if err := info.Validate(); err != nil {
+		return err
+	}
+
+	if info.Size == UnknownSize {
+		return &InfoError{ErrSizeOverflow, "Size is UnknownSize"}
+	}
+
+	return nil
+}
+
+// validateShards returns *InfoError if shards are not buffers of each shard of info.
+func validateShards(shards [][]byte, info *Info) error {
+	if uint64(len(shards)) != info.DataCount+info.ParityCount {
+		return &InfoError{ErrShardsMismatch, fmt.Sprintf("len(shards) %v != DataCount+ParityCount %v", len(shards), info.DataCount+info.ParityCount)}
+	}
+
+	for i, shard := range shards {
+		if uint64(len(shard)) != info.ShardSize {
+			return &InfoError{ErrShardsMismatch, fmt.Sprintf("len(shards[%v]) %v != ShardSize %v", i, len(shard), info.ShardSize)}
+		}
+	}
+
+	return nil
+}
+
+// Compute returns block layout of info. info must be valid by Validate().
 func (info Info) Compute() (blockCount, blockSize, lastBlockSize, lastShardSize uint64) {
 	blockSize = info.DataCount * info.ShardSize
 	blockCount = info.Size / blockSize
@@ -1,7 +1,14 @@
 package erasure
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -49,3 +56,187 @@ func TestInfoCompute(t *testing.T) {
 		)
 	}
 }
+
+func TestInfoValidate(t *testing.T) {
+	testCases := []struct {
+		info        Info
+		expectedErr error
+	}{
+		{Info{4, 2, 32283, MiB, []string{"0", "1", "2", "3", "4", "5"}}, nil},
+		{Info{1, 0, UnknownSize, MiB, []string{"0"}}, nil},
+		{Info{0, 2, 32283, MiB, []string{"0", "1"}}, ErrZeroDataCount},
+		{Info{200, 57, 32283, MiB, nil}, ErrTooManyShards},
+		{Info{math.MaxUint64, 2, 32283, MiB, nil}, ErrTooManyShards},
+		{Info{4, 2, 32283, 0, []string{"0", "1", "2", "3", "4", "5"}}, ErrZeroShardSize},
+		{Info{4, 2, 32283, math.MaxUint64 / 2, []string{"0", "1", "2", "3", "4", "5"}}, ErrSizeOverflow},
+		{Info{4, 2, math.MaxInt64 + 1, MiB, []string{"0", "1", "2", "3", "4", "5"}}, ErrSizeOverflow},
+		{Info{4, 2, 32283, MiB, []string{"0", "1", "2", "3", "4"}}, ErrShardIDsCount},
+		{Info{4, 2, 32283, MiB, []string{"0", "1", "2", "3", "4", "1"}}, ErrDuplicateShardIDs},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				err := testCase.info.Validate()
+				if testCase.expectedErr == nil {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+
+				var infoErr *InfoError
+				if !errors.As(err, &infoErr) || !errors.Is(err, testCase.expectedErr) {
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.expectedErr, err)
+				}
+			},
+		)
+	}
+}
+
+func TestInvalidInfo(t *testing.T) {
+	info := &Info{DataCount: 4, ParityCount: 2, Size: 32283, ShardSize: 1024, ShardIDs: []string{"0", "1", "2", "3", "4", "1"}}
+	getShardWriter := func(shardID string) (io.Writer, error) {
+		return ioutil.Discard, nil
+	}
+	getShardReader := func(shardID string, offset, length int64) (io.Reader, error) {
+		return nil, errors.New("not found")
+	}
+
+	shards := make([][]byte, 6)
+	for i := range shards {
+		shards[i] = make([]byte, info.ShardSize)
+	}
+
+	if _, _, err := Write(getShardWriter, shards, info, randReader(), 6); !errors.Is(err, ErrDuplicateShardIDs) {
+		t.Fatalf("mismatch: expected: %v, got: %v", ErrDuplicateShardIDs, err)
+	}
+
+	if _, _, err := WritePipelined(getShardWriter, info, randReader(), 6, PipelineOptions{}); !errors.Is(err, ErrDuplicateShardIDs) {
+		t.Fatalf("mismatch: expected: %v, got: %v", ErrDuplicateShardIDs, err)
+	}
+
+	if _, err := NewReader(getShardReader, shards, info, 0, 10); !errors.Is(err, ErrDuplicateShardIDs) {
+		t.Fatalf("mismatch: expected: %v, got: %v", ErrDuplicateShardIDs, err)
+	}
+
+	info.ShardIDs[5] = "5"
+	if _, _, err := Write(getShardWriter, shards[:5], info, randReader(), 6); !errors.Is(err, ErrShardsMismatch) {
+		t.Fatalf("mismatch: expected: %v, got: %v", ErrShardsMismatch, err)
+	}
+
+	if _, err := NewReader(getShardReader, shards, info, 32283, 1); err == nil {
+		t.Fatalf("expected: <error>, got: <nil>")
+	}
+
+	info.Size = UnknownSize
+	if _, err := NewReaderAt(nil, info); !errors.Is(err, ErrSizeOverflow) {
+		t.Fatalf("mismatch: expected: %v, got: %v", ErrSizeOverflow, err)
+	}
+}
+
+func FuzzInfoValidate(f *testing.F) {
+	f.Add(uint64(4), uint64(2), uint64(32283), uint64(MiB), "0,1,2,3,4,5")
+	f.Add(uint64(0), uint64(0), uint64(0), uint64(0), "")
+	f.Add(uint64(math.MaxUint64), uint64(1), uint64(UnknownSize), uint64(math.MaxUint64), "0,0")
+
+	f.Fuzz(func(t *testing.T, dataCount, parityCount, size, shardSize uint64, shardIDs string) {
+		info := Info{DataCount: dataCount, ParityCount: parityCount, Size: size, ShardSize: shardSize, ShardIDs: strings.Split(shardIDs, ",")}
+		if err := info.Validate(); err != nil {
+			var infoErr *InfoError
+			if !errors.As(err, &infoErr) {
+				t.Fatalf("mismatch: expected: *InfoError, got: %T", err)
+			}
+			return
+		}
+
+		if info.Size == UnknownSize {
+			return
+		}
+
+		blockCount, blockSize, lastBlockSize, lastShardSize := info.Compute()
+		if blockSize == 0 || lastBlockSize > blockSize || lastShardSize > shardSize || (info.Size > 0 && blockCount == 0) {
+			t.Fatalf("invalid layout: %v, %v, %v, %v", blockCount, blockSize, lastBlockSize, lastShardSize)
+		}
+	})
+}
+
+// FuzzInfo checks that Write() and readers return errors instead of panicking on arbitrary info.
+func FuzzInfo(f *testing.F) {
+	f.Add(uint64(4), uint64(2), uint64(32283), uint64(1024), "0,1,2,3,4,5", int64(10), uint64(7))
+	f.Add(uint64(1), uint64(0), uint64(0), uint64(1), "0", int64(0), uint64(0))
+	f.Add(uint64(2), uint64(2), uint64(5000), uint64(0), "0,1,2,3", int64(-1), uint64(1))
+	f.Add(uint64(3), uint64(1), uint64(UnknownSize), uint64(512), "0,1,2,3", int64(math.MaxInt64), uint64(math.MaxUint64))
+	f.Add(uint64(3), uint64(1), uint64(5038), uint64(6), "0,1,2,", int64(165), uint64(10)) // Range within a block.
+
+	data, err := ioutil.ReadAll(io.LimitReader(randReader(), 64*1024))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, dataCount, parityCount, size, shardSize uint64, shardIDs string, offset int64, length uint64) {
+		info := &Info{DataCount: dataCount, ParityCount: parityCount, Size: size, ShardSize: shardSize, ShardIDs: strings.Split(shardIDs, ",")}
+
+		// Shard buffers are allocated for reasonable layouts only; others must be rejected without them.
+		count := dataCount + parityCount
+		if count > 300 || count < dataCount {
+			count = 300
+		}
+		bufSize := shardSize
+		if bufSize > 64*1024 {
+			bufSize = 0
+		}
+
+		shards := make([][]byte, count)
+		for i := range shards {
+			shards[i] = make([]byte, bufSize)
+		}
+
+		buffers := map[string]*bytes.Buffer{}
+		var mutex sync.Mutex
+		getShardWriter := func(shardID string) (io.Writer, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			buffers[shardID] = new(bytes.Buffer)
+			return buffers[shardID], nil
+		}
+
+		if _, _, err := Write(getShardWriter, shards, info, bytes.NewReader(data), count); err != nil {
+			return
+		}
+
+		getShardReader := func(shardID string, offset, length int64) (io.Reader, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			buffer, found := buffers[shardID]
+			if !found {
+				return nil, errors.New("shard not found")
+			}
+			return io.NewSectionReader(bytes.NewReader(buffer.Bytes()), offset, length), nil
+		}
+
+		reader, err := NewReader(getShardReader, shards, info, offset, length)
+		if err == nil {
+			if _, err = io.Copy(ioutil.Discard, reader); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		getShardReaderAt := func(shardID string) (io.ReaderAt, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return bytes.NewReader(buffers[shardID].Bytes()), nil
+		}
+
+		readerAt, err := NewReaderAt(getShardReaderAt, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if length > 64*1024 {
+			length = 64 * 1024
+		}
+		readerAt.ReadAt(make([]byte, length), offset)
+	})
+}
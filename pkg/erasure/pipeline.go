@@ -0,0 +1,251 @@
+package erasure
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	xhash "github.com/balamurugana/goat/pkg/hash"
+	"github.com/klauspost/reedsolomon"
+)
+
+// PipelineOptions controls WritePipelined().
+type PipelineOptions struct {
+	QueueLength int  // Maximum blocks read ahead of block being written; 4 if zero.
+	Encoders    int  // Number of blocks encoded in parallel; GOMAXPROCS if zero.
+	Verify      bool // Verify parity of each encoded block.
+}
+
+// shardBufferPools holds *sync.Pool of shard buffers by shard size.
+var shardBufferPools sync.Map
+
+func getShardBuffers(count int, shardSize uint64) [][]byte {
+	pool, _ := shardBufferPools.LoadOrStore(shardSize, &sync.Pool{
+		New: func() interface{} {
+			return make([]byte, shardSize)
+		},
+	})
+
+	shards := make([][]byte, count)
+	for i := range shards {
+		shards[i] = pool.(*sync.Pool).Get().([]byte)
+	}
+
+	return shards
+}
+
+func putShardBuffers(shards [][]byte, shardSize uint64) {
+	if pool, found := shardBufferPools.Load(shardSize); found {
+		for i := range shards {
+			pool.(*sync.Pool).Put(shards[i][:shardSize])
+		}
+	}
+}
+
+// blockReader reads data block by block into data shards.
+type blockReader struct {
+	reader    io.Reader
+	info      *Info
+	remaining uint64 // UnknownSize if data is read until EOF.
+	size      uint64
+	hasher    xhash.Hash
+	buf       []byte
+}
+
+func newBlockReader(reader io.Reader, info *Info) *blockReader {
+	return &blockReader{
+		reader:    reader,
+		info:      info,
+		remaining: info.Size,
+		hasher:    xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil),
+	}
+}
+
+// read reads next block into data shards of given full size shards and returns shard size of the block. Zero
+// shard size is end of data.
+func (br *blockReader) read(shards [][]byte) (shardSize uint64, err error) {
+	dataCount := br.info.DataCount
+	blockSize := dataCount * br.info.ShardSize
+
+	var block []byte
+	blockLength := blockSize
+	if br.remaining == UnknownSize {
+		// Size of a block is known after it is read, hence it is read fully before splitting into data shards.
+		if br.buf == nil {
+			br.buf = make([]byte, blockSize)
+		}
+
+		n, err := io.ReadFull(br.reader, br.buf)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+
+			return 0, err
+		}
+
+		block = br.buf[:n]
+		blockLength = uint64(n)
+	} else if br.remaining < blockSize {
+		blockLength = br.remaining
+	}
+
+	if blockLength == 0 {
+		return 0, nil
+	}
+
+	shardSize = (blockLength + dataCount - 1) / dataCount
+	for s := uint64(0); s < dataCount; s++ {
+		shard := shards[s][:shardSize]
+		offset, length := s*shardSize, uint64(0)
+		if offset < blockLength {
+			if length = blockLength - offset; length > shardSize {
+				length = shardSize
+			}
+		}
+
+		if block != nil {
+			copy(shard, block[offset:offset+length])
+		} else if _, err = io.ReadFull(br.reader, shard[:length]); err != nil {
+			return 0, err
+		}
+
+		br.hasher.Write(shard[:length])
+		for i := length; i < shardSize; i++ {
+			shard[i] = 0
+		}
+	}
+
+	br.size += blockLength
+	if br.remaining != UnknownSize {
+		br.remaining -= blockLength
+	}
+
+	return shardSize, nil
+}
+
+type pipelineBlock struct {
+	shards  [][]byte
+	encoded chan struct{}
+	err     error
+}
+
+// WritePipelined is same as Write() but overlaps reading, encoding and writing of blocks. Blocks are read ahead
+// up to opts.QueueLength into pooled shard buffers, encoded by opts.Encoders in parallel and written in order.
+// Exactly info.Size bytes are read from reader unless it is UnknownSize.
+func WritePipelined(getShardWriter GetShardWriter, info *Info, reader io.Reader, minSuccessWriters uint64, opts PipelineOptions) ([]string, string, error) {
+	if err := info.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	if opts.QueueLength <= 0 {
+		opts.QueueLength = 4
+	}
+
+	if opts.Encoders <= 0 {
+		opts.Encoders = runtime.GOMAXPROCS(0)
+	}
+
+	encoders := make([]reedsolomon.Encoder, opts.Encoders)
+	for i := range encoders {
+		var err error
+		if encoders[i], err = reedsolomon.New(int(info.DataCount), int(info.ParityCount)); err != nil {
+			return nil, "", err
+		}
+	}
+
+	sw, err := newShardWriters(getShardWriter, info, minSuccessWriters)
+	if err != nil {
+		return nil, "", err
+	}
+
+	count := len(info.ShardIDs)
+	encodeCh := make(chan *pipelineBlock, opts.QueueLength)
+	writeCh := make(chan *pipelineBlock, opts.QueueLength)
+	stopCh := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := range encoders {
+		wg.Add(1)
+		go func(encoder reedsolomon.Encoder) {
+			defer wg.Done()
+			for block := range encodeCh {
+				block.err = encoder.Encode(block.shards)
+				if block.err == nil && opts.Verify {
+					var ok bool
+					if ok, block.err = encoder.Verify(block.shards); block.err == nil && !ok {
+						block.err = fmt.Errorf("verification failed on encoded shards")
+					}
+				}
+				close(block.encoded)
+			}
+		}(encoders[i])
+	}
+
+	writeErrCh := make(chan error, 1)
+	go func() {
+		var err error
+		for block := range writeCh {
+			<-block.encoded
+			if err == nil {
+				if err = block.err; err == nil {
+					err = sw.write(block.shards)
+				}
+
+				if err != nil {
+					close(stopCh)
+				}
+			}
+
+			putShardBuffers(block.shards, info.ShardSize)
+		}
+
+		writeErrCh <- err
+	}()
+
+	br := newBlockReader(reader, info)
+	var readErr error
+readLoop:
+	for {
+		shards := getShardBuffers(count, info.ShardSize)
+		var shardSize uint64
+		if shardSize, readErr = br.read(shards); readErr != nil || shardSize == 0 {
+			putShardBuffers(shards, info.ShardSize)
+			break
+		}
+
+		for i := range shards {
+			shards[i] = shards[i][:shardSize]
+		}
+
+		// Queue is bounded by writeCh, hence blocks are queued to be written before to be encoded.
+		block := &pipelineBlock{shards: shards, encoded: make(chan struct{})}
+		select {
+		case writeCh <- block:
+			encodeCh <- block
+		case <-stopCh:
+			putShardBuffers(shards, info.ShardSize)
+			break readLoop
+		}
+	}
+
+	close(encodeCh)
+	close(writeCh)
+	wg.Wait()
+
+	if err = <-writeErrCh; err != nil {
+		return nil, "", err
+	}
+
+	if readErr != nil {
+		return nil, "", readErr
+	}
+
+	if info.Size == UnknownSize {
+		info.Size = br.size
+	}
+
+	return sw.sums(), br.hasher.HexSum(nil), nil
+}
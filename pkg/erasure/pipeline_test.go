@@ -0,0 +1,202 @@
+package erasure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func writeToBuffers(info *Info, failedShards int, write func(getShardWriter GetShardWriter) ([]string, string, error)) (map[string][]byte, []string, string, error) {
+	info.ShardIDs = make([]string, info.DataCount+info.ParityCount)
+	buffers := make(map[string]*bytes.Buffer)
+	for i := range info.ShardIDs {
+		info.ShardIDs[i] = fmt.Sprintf("shard.%v", i)
+		buffers[info.ShardIDs[i]] = new(bytes.Buffer)
+	}
+
+	var mutex sync.Mutex
+	getShardWriter := func(shardID string) (io.Writer, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for i := 0; i < failedShards; i++ {
+			if shardID == info.ShardIDs[i] {
+				return failingWriter{}, nil
+			}
+		}
+
+		return buffers[shardID], nil
+	}
+
+	shardChecksums, checksum, err := write(getShardWriter)
+
+	data := make(map[string][]byte)
+	for shardID, buffer := range buffers {
+		data[shardID] = buffer.Bytes()
+	}
+
+	return data, shardChecksums, checksum, err
+}
+
+func TestWritePipelined(t *testing.T) {
+	testCases := []struct {
+		dataCount    uint64
+		parityCount  uint64
+		size         uint64
+		shardSize    uint64
+		unknownSize  bool
+		opts         PipelineOptions
+		failedShards int
+		expectErr    bool
+	}{
+		{4, 2, 32283, 1024, false, PipelineOptions{}, 0, false},
+		{4, 2, 8192, 1024, false, PipelineOptions{QueueLength: 1, Encoders: 1}, 0, false},
+		{3, 2, 1000, 1024, false, PipelineOptions{Verify: true}, 0, false},
+		{1, 3, 0, 1024, false, PipelineOptions{}, 0, false},
+		{4, 2, 32283, 1024, true, PipelineOptions{Encoders: 3}, 0, false},
+		{4, 2, 8192, 1024, true, PipelineOptions{}, 0, false},
+		{4, 2, 32283, 1024, false, PipelineOptions{}, 1, false},
+		{4, 2, 32283, 1024, false, PipelineOptions{QueueLength: 2}, 2, true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				minSuccess := testCase.dataCount + testCase.parityCount - 1
+				info := &Info{DataCount: testCase.dataCount, ParityCount: testCase.parityCount, Size: testCase.size, ShardSize: testCase.shardSize}
+				expectedShards, expectedShardChecksums, expectedChecksum, err := writeToBuffers(info, testCase.failedShards, func(getShardWriter GetShardWriter) ([]string, string, error) {
+					shards := make([][]byte, len(info.ShardIDs))
+					for i := range shards {
+						shards[i] = make([]byte, info.ShardSize)
+					}
+
+					return Write(getShardWriter, shards, info, io.LimitReader(randReader(), int64(testCase.size)), minSuccess)
+				})
+				if testCase.expectErr {
+					if err == nil {
+						t.Fatalf("expected: <error>, got: <nil>")
+					}
+				} else if err != nil {
+					t.Fatal(err)
+				}
+
+				pipelinedInfo := &Info{DataCount: testCase.dataCount, ParityCount: testCase.parityCount, Size: testCase.size, ShardSize: testCase.shardSize}
+				if testCase.unknownSize {
+					pipelinedInfo.Size = UnknownSize
+				}
+
+				shards, shardChecksums, checksum, err := writeToBuffers(pipelinedInfo, testCase.failedShards, func(getShardWriter GetShardWriter) ([]string, string, error) {
+					return WritePipelined(getShardWriter, pipelinedInfo, io.LimitReader(randReader(), int64(testCase.size)), minSuccess, testCase.opts)
+				})
+				if testCase.expectErr {
+					if err == nil {
+						t.Fatalf("expected: <error>, got: <nil>")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if pipelinedInfo.Size != testCase.size {
+					t.Fatalf("mismatch: size: expected: %v, got: %v", testCase.size, pipelinedInfo.Size)
+				}
+
+				if checksum != expectedChecksum {
+					t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+				}
+
+				if !reflect.DeepEqual(shardChecksums, expectedShardChecksums) {
+					t.Fatalf("mismatch: shard checksums: expected: %v, got: %v", expectedShardChecksums, shardChecksums)
+				}
+
+				for shardID, data := range expectedShards {
+					if !bytes.Equal(shards[shardID], data) {
+						t.Fatalf("%v: mismatch: shard data differs", shardID)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestWritePipelinedShortData(t *testing.T) {
+	info := &Info{DataCount: 4, ParityCount: 2, Size: 32283, ShardSize: 1024}
+	_, _, _, err := writeToBuffers(info, 0, func(getShardWriter GetShardWriter) ([]string, string, error) {
+		return WritePipelined(getShardWriter, info, io.LimitReader(randReader(), 20000), 6, PipelineOptions{})
+	})
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("mismatch: expected: %v, got: %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+const benchmarkSize = 64 * MiB
+
+func benchmarkWrite(b *testing.B, shardSize uint64, write func(getShardWriter GetShardWriter, info *Info, reader io.Reader) error) {
+	getShardWriter := func(shardID string) (io.Writer, error) {
+		return ioutil.Discard, nil
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(randReader(), benchmarkSize))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(benchmarkSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		info := &Info{DataCount: 8, ParityCount: 4, Size: benchmarkSize, ShardSize: shardSize}
+		info.ShardIDs = make([]string, info.DataCount+info.ParityCount)
+		for j := range info.ShardIDs {
+			info.ShardIDs[j] = fmt.Sprintf("shard.%v", j)
+		}
+
+		if err := write(getShardWriter, info, bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+var benchmarkShardSizes = []uint64{64 * 1024, 256 * 1024, MiB, 4 * MiB}
+
+// BenchmarkWrite measures throughput of Write() for comparison with BenchmarkWritePipelined.
+func BenchmarkWrite(b *testing.B) {
+	for _, shardSize := range benchmarkShardSizes {
+		b.Run(fmt.Sprintf("shardSize=%vKiB", shardSize/1024), func(b *testing.B) {
+			benchmarkWrite(b, shardSize, func(getShardWriter GetShardWriter, info *Info, reader io.Reader) error {
+				shards := make([][]byte, len(info.ShardIDs))
+				for i := range shards {
+					shards[i] = make([]byte, info.ShardSize)
+				}
+
+				_, _, err := Write(getShardWriter, shards, info, reader, 12)
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkWritePipelined(b *testing.B) {
+	for _, verify := range []bool{false, true} {
+		for _, shardSize := range benchmarkShardSizes {
+			b.Run(fmt.Sprintf("shardSize=%vKiB/verify=%v", shardSize/1024, verify), func(b *testing.B) {
+				benchmarkWrite(b, shardSize, func(getShardWriter GetShardWriter, info *Info, reader io.Reader) error {
+					_, _, err := WritePipelined(getShardWriter, info, reader, 12, PipelineOptions{Verify: verify})
+					return err
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package erasure
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var errPrefetchReaderClosed = errors.New("prefetch reader closed")
+
+// PrefetchReader is reader returned by NewPrefetchReader(). Close() must be called to stop prefetching.
+type PrefetchReader interface {
+	Reader
+	io.Closer
+}
+
+type prefetchBlock struct {
+	data      []byte
+	shardErrs []ShardError
+	err       error
+}
+
+type prefetchReader struct {
+	dr        *decodeReader
+	blockSize uint64
+	blockCh   chan *prefetchBlock
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+
+	// Shard readers opened so far, closed by Close() to interrupt block being decoded, and shard errors of
+	// blocks decoded before Close().
+	closers          []io.Closer
+	stopped          bool
+	decodedShardErrs []ShardError
+	mutex            sync.Mutex
+
+	block     *prefetchBlock
+	offset    int
+	shardErrs []ShardError
+	err       error
+	closed    bool
+}
+
+// prefetch decodes blocks in background until end of data, an error or Close().
+func (pr *prefetchReader) prefetch() {
+	defer close(pr.blockCh)
+
+	for {
+		block := &prefetchBlock{}
+		if block.err = pr.dr.readBlock(); block.err == nil {
+			// Shards are reused by next readBlock(), hence available data is copied into pooled buffer.
+			block.data = getShardBuffers(1, pr.blockSize)[0][:0]
+			for i := pr.dr.shardIndex; i < pr.dr.info.DataCount; i++ {
+				block.data = append(block.data, pr.dr.shards[i]...)
+			}
+
+			if uint64(len(block.data)) > pr.dr.bytesAvailable {
+				block.data = block.data[:pr.dr.bytesAvailable]
+			}
+		}
+		block.shardErrs = pr.dr.ShardErrors()
+
+		// Block being decoded on Close() may fail by closed shard readers, hence it is dropped with its errors.
+		pr.mutex.Lock()
+		if pr.stopped {
+			pr.mutex.Unlock()
+			pr.release(block)
+			return
+		}
+		pr.decodedShardErrs = block.shardErrs
+		pr.mutex.Unlock()
+
+		select {
+		case pr.blockCh <- block:
+		case <-pr.stopCh:
+			pr.release(block)
+			return
+		}
+
+		if block.err != nil {
+			return
+		}
+	}
+}
+
+func (pr *prefetchReader) release(block *prefetchBlock) {
+	if block != nil && block.data != nil {
+		putShardBuffers([][]byte{block.data}, pr.blockSize)
+		block.data = nil
+	}
+}
+
+func (pr *prefetchReader) Read(b []byte) (n int, err error) {
+	if pr.closed {
+		return 0, errPrefetchReaderClosed
+	}
+
+	for n < len(b) {
+		if pr.block == nil || pr.offset == len(pr.block.data) {
+			if pr.err != nil {
+				return n, pr.err
+			}
+
+			pr.release(pr.block)
+			pr.block, pr.offset = <-pr.blockCh, 0
+			if pr.block == nil {
+				pr.err = errPrefetchReaderClosed
+				continue
+			}
+
+			pr.shardErrs = pr.block.shardErrs
+			if pr.block.err != nil {
+				pr.err = pr.block.err
+				continue
+			}
+		}
+
+		copied := copy(b[n:], pr.block.data[pr.offset:])
+		pr.offset += copied
+		n += copied
+	}
+
+	return n, nil
+}
+
+// ShardErrors returns shards failed in blocks read so far, or in all blocks decoded after Close().
+func (pr *prefetchReader) ShardErrors() []ShardError {
+	return pr.shardErrs
+}
+
+// getShardReader opens shard reader by given function and keeps it to be closed by Close(). No shard reader is
+// opened after Close().
+func (pr *prefetchReader) getShardReader(getShardReader GetShardReader) GetShardReader {
+	return func(shardID string, offset, length int64) (io.Reader, error) {
+		pr.mutex.Lock()
+		stopped := pr.stopped
+		pr.mutex.Unlock()
+		if stopped {
+			return nil, errPrefetchReaderClosed
+		}
+
+		reader, err := getShardReader(shardID, offset, length)
+		if err != nil {
+			return nil, err
+		}
+
+		if closer, ok := reader.(io.Closer); ok {
+			pr.mutex.Lock()
+			pr.closers = append(pr.closers, closer)
+			stopped = pr.stopped
+			pr.mutex.Unlock()
+			if stopped {
+				closer.Close()
+			}
+		}
+
+		return reader, nil
+	}
+}
+
+// Close stops prefetching and closes shard readers implementing io.Closer so that block being decoded is
+// interrupted instead of waited for, e.g. on a hung shard reader. Close() returns once prefetching stopped,
+// hence shards passed to NewPrefetchReader() may be reused afterwards. Shard readers may be closed again by
+// their owner.
+func (pr *prefetchReader) Close() error {
+	pr.stopOnce.Do(func() {
+		pr.mutex.Lock()
+		pr.stopped = true
+		closers := pr.closers
+		pr.mutex.Unlock()
+
+		close(pr.stopCh)
+		for _, closer := range closers {
+			closer.Close()
+		}
+	})
+
+	for block := range pr.blockCh {
+		pr.release(block)
+	}
+
+	pr.release(pr.block)
+	pr.block = nil
+	if !pr.closed {
+		pr.shardErrs = pr.decodedShardErrs
+		pr.closed = true
+	}
+
+	return nil
+}
+
+// NewPrefetchReader is same as NewReader() but decodes up to readAhead blocks in background ahead of block being
+// read. readAhead less than one is taken as one.
+func NewPrefetchReader(getShardReader GetShardReader, shards [][]byte, info *Info, offset int64, length uint64, readAhead int) (PrefetchReader, error) {
+	reader, err := NewReader(getShardReader, shards, info, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	if readAhead < 1 {
+		readAhead = 1
+	}
+
+	// A decoded block waits to be sent while blockCh is full, hence blockCh holds one block less than readAhead.
+	pr := &prefetchReader{
+		dr:        reader.(*decodeReader),
+		blockSize: info.DataCount * info.ShardSize,
+		blockCh:   make(chan *prefetchBlock, readAhead-1),
+		stopCh:    make(chan struct{}),
+	}
+	pr.dr.getShardReader = pr.getShardReader(getShardReader)
+
+	go pr.prefetch()
+
+	return pr, nil
+}
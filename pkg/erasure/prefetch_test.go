@@ -0,0 +1,187 @@
+package erasure
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	xhash "github.com/balamurugana/goat/pkg/hash"
+	xos "github.com/balamurugana/goat/pkg/os"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestNewPrefetchReader(t *testing.T) {
+	testCases := []struct {
+		info          *Info
+		offset        int64
+		length        uint64
+		readAhead     int
+		checksum      string
+		missingShards []int
+	}{
+		{&Info{DataCount: 1, ParityCount: 3, Size: 32283, ShardSize: 4096}, 0, 10, 0, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9", nil},
+		{&Info{DataCount: 1, ParityCount: 3, Size: 32283, ShardSize: 4096}, 10, 7, 2, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25", nil},
+		{&Info{DataCount: 1, ParityCount: 3, Size: 32283, ShardSize: 4096}, 0, 32283, 3, "53e488c20a4168a2d093f7d221e649582f87ccb54124bf85afa4fb5619211621", nil},
+		{&Info{DataCount: 2, ParityCount: 2, Size: 70009289, ShardSize: MiB}, 3145649, 1048986, 1, "3faf5850c140d6f2ad36e0ba7324d306e1589d50fc17fa0cc1a1ccbf76d87332", nil},
+		{&Info{DataCount: 3, ParityCount: 2, Size: 70009289, ShardSize: MiB}, 3145649, 1048986, 4, "3faf5850c140d6f2ad36e0ba7324d306e1589d50fc17fa0cc1a1ccbf76d87332", []int{0, 2}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				dirname := xrand.NewID(8).String()
+				defer os.RemoveAll(dirname)
+				testWrite(t, testCase.info, dirname)
+
+				for _, j := range testCase.missingShards {
+					if err := os.Remove(testCase.info.ShardIDs[j]); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				files := map[string]*os.File{}
+				filesMutex := sync.Mutex{}
+				getShardReader := func(shardID string, offset, length int64) (io.Reader, error) {
+					file, err := os.Open(shardID)
+					if err != nil {
+						return nil, err
+					}
+
+					filesMutex.Lock()
+					files[shardID] = file
+					filesMutex.Unlock()
+					return xos.NewSectionFileReader(file, offset, length), nil
+				}
+
+				shards := make([][]byte, testCase.info.DataCount+testCase.info.ParityCount)
+				for j := range shards {
+					shards[j] = make([]byte, testCase.info.ShardSize)
+				}
+
+				defer func() {
+					for _, file := range files {
+						file.Close()
+					}
+				}()
+
+				reader, err := NewPrefetchReader(getShardReader, shards, testCase.info, testCase.offset, testCase.length, testCase.readAhead)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+				if _, err = io.Copy(hasher, reader); err != nil {
+					t.Fatal(err)
+				}
+				if checksum := hasher.HexSum(nil); checksum != testCase.checksum {
+					t.Fatalf("mismatch: expected: %v, got: %v", testCase.checksum, checksum)
+				}
+
+				checkShardErrors(t, reader.ShardErrors(), testCase.info, testCase.missingShards)
+
+				if err = reader.Close(); err != nil {
+					t.Fatal(err)
+				}
+
+				checkShardErrors(t, reader.ShardErrors(), testCase.info, testCase.missingShards)
+			},
+		)
+	}
+}
+
+func TestPrefetchReaderClose(t *testing.T) {
+	info := &Info{DataCount: 2, ParityCount: 2, Size: 32283, ShardSize: 1024}
+	dirname := xrand.NewID(8).String()
+	defer os.RemoveAll(dirname)
+	testWrite(t, info, dirname)
+
+	var files []*os.File
+	var filesMutex sync.Mutex
+	getShardReader := func(shardID string, offset, length int64) (io.Reader, error) {
+		file, err := os.Open(shardID)
+		if err != nil {
+			return nil, err
+		}
+
+		filesMutex.Lock()
+		files = append(files, file)
+		filesMutex.Unlock()
+		return xos.NewSectionFileReader(file, offset, length), nil
+	}
+
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	shards := make([][]byte, info.DataCount+info.ParityCount)
+	for i := range shards {
+		shards[i] = make([]byte, info.ShardSize)
+	}
+
+	reader, err := NewPrefetchReader(getShardReader, shards, info, 0, info.Size, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 3000)
+	if _, err = io.ReadFull(reader, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = reader.Read(b); !errors.Is(err, errPrefetchReaderClosed) {
+		t.Fatalf("mismatch: expected: %v, got: %v", errPrefetchReaderClosed, err)
+	}
+
+	if _, err = NewPrefetchReader(getShardReader, shards, info, 30000, 3000, 1); err == nil {
+		t.Fatalf("expected: <error>, got: <nil>")
+	}
+}
+
+func TestPrefetchReaderCloseHung(t *testing.T) {
+	info := &Info{DataCount: 2, ParityCount: 2, Size: 32283, ShardSize: 1024, ShardIDs: []string{"s0", "s1", "s2", "s3"}}
+
+	// Shard readers never return data until they are closed.
+	getShardReader := func(shardID string, offset, length int64) (io.Reader, error) {
+		reader, _ := io.Pipe()
+		return reader, nil
+	}
+
+	shards := make([][]byte, info.DataCount+info.ParityCount)
+	for i := range shards {
+		shards[i] = make([]byte, info.ShardSize)
+	}
+
+	reader, err := NewPrefetchReader(getShardReader, shards, info, 0, info.Size, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- reader.Close()
+	}()
+
+	select {
+	case err = <-doneCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("mismatch: expected: Close() returns, got: <hung>")
+	}
+
+	if shardErrs := reader.ShardErrors(); len(shardErrs) != 0 {
+		t.Fatalf("mismatch: expected: [], got: %v", shardErrs)
+	}
+}
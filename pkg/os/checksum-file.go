@@ -2,15 +2,50 @@ package os
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 
 	xhash "github.com/balamurugana/goat/pkg/hash"
 )
 
+const (
+	// ChecksumFormatV1 is JSON header followed by hex encoded checksum of each block in a line.
+	ChecksumFormatV1 = 1
+
+	// ChecksumFormatV2 is fixed size binary header followed by raw checksum of each block.
+	ChecksumFormatV2 = 2
+
+	// ChecksumFormatInline is ChecksumFormatV2 header at the beginning of data file followed by each block
+	// and its raw checksum. No companion .checksum file is created.
+	ChecksumFormatInline = 3
+)
+
+// Binary header of ChecksumFormatV2 and ChecksumFormatInline. All integers are in little endian.
+//
+// | Offset | Length | Field                                                  |
+// |--------|--------|--------------------------------------------------------|
+// | 0      | 8      | Magic "GOATCSUM"                                       |
+// | 8      | 4      | Format version                                         |
+// | 12     | 32     | Hash name, zero padded                                 |
+// | 44     | 4      | Hash key length                                        |
+// | 48     | 64     | Hash key, zero padded                                  |
+// | 112    | 4      | Raw checksum length                                    |
+// | 116    | 8      | Block size                                             |
+// | 124    | 8      | Block count                                            |
+// | 132    | 8      | Data length                                            |
+// | 140    | 32     | HighwayHash256 with default key of above 140 bytes     |
+const (
+	checksumV2Magic      = "GOATCSUM"
+	checksumV2HeaderSize = 172
+	checksumV2NameSize   = 32
+	checksumV2KeySize    = 64
+)
+
 type checksumHeader struct {
 	HashName   string `json:"hashName"`
 	HashKey    string `json:"hashKey"`
@@ -20,28 +55,105 @@ type checksumHeader struct {
 	DataLength uint64 `json:"dataLength"`
 }
 
+func (header *checksumHeader) marshalBinary(format int, sumLength uint) ([]byte, error) {
+	key, err := hex.DecodeString(header.HashKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(header.HashName) > checksumV2NameSize || len(key) > checksumV2KeySize {
+		return nil, errors.New("hash name or key too long")
+	}
+
+	data := make([]byte, checksumV2HeaderSize)
+	copy(data[0:8], checksumV2Magic)
+	binary.LittleEndian.PutUint32(data[8:12], uint32(format))
+	copy(data[12:44], header.HashName)
+	binary.LittleEndian.PutUint32(data[44:48], uint32(len(key)))
+	copy(data[48:112], key)
+	binary.LittleEndian.PutUint32(data[112:116], uint32(sumLength))
+	binary.LittleEndian.PutUint64(data[116:124], uint64(header.BlockSize))
+	binary.LittleEndian.PutUint64(data[124:132], uint64(header.BlockCount))
+	binary.LittleEndian.PutUint64(data[132:140], header.DataLength)
+
+	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+	hasher.Write(data[:140])
+	copy(data[140:], hasher.Sum(nil))
+
+	return data, nil
+}
+
+func (header *checksumHeader) unmarshalBinary(data []byte) (format int, sumLength uint, err error) {
+	if string(data[0:8]) != checksumV2Magic {
+		return 0, 0, errors.New("invalid checksum file magic")
+	}
+
+	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+	hasher.Write(data[:140])
+	if !bytes.Equal(hasher.Sum(nil), data[140:checksumV2HeaderSize]) {
+		return 0, 0, errors.New("checksum file header corrupted")
+	}
+
+	switch format = int(binary.LittleEndian.Uint32(data[8:12])); format {
+	case ChecksumFormatV2, ChecksumFormatInline:
+	default:
+		return 0, 0, errors.New("unsupported checksum file version")
+	}
+
+	keyLength := binary.LittleEndian.Uint32(data[44:48])
+	if keyLength > checksumV2KeySize {
+		return 0, 0, errors.New("invalid hash key length")
+	}
+
+	sumLength = uint(binary.LittleEndian.Uint32(data[112:116]))
+	header.HashName = string(bytes.TrimRight(data[12:44], "\x00"))
+	header.HashKey = hex.EncodeToString(data[48 : 48+keyLength])
+	header.HashLength = sumLength * 2
+	header.BlockSize = uint(binary.LittleEndian.Uint64(data[116:124]))
+	header.BlockCount = uint(binary.LittleEndian.Uint64(data[124:132]))
+	header.DataLength = binary.LittleEndian.Uint64(data[132:140])
+
+	return format, sumLength, nil
+}
+
 type checksumFile struct {
 	*os.File
-	hasher xhash.Hash
-	header *checksumHeader
-	buf    []byte
+	hasher    xhash.Hash
+	header    *checksumHeader
+	format    int
+	sumLength uint // Raw checksum length for ChecksumFormatV2 and ChecksumFormatInline.
+	buf       []byte
+
+	headerLength int // Length of header written by newChecksumFile().
 }
 
-func createChecksumFile(filename string, blockSize, blockCount uint, size uint64) (*checksumFile, error) {
-	var file *os.File
-	var err error
+func createChecksumFile(filename string, hasher xhash.Hash, blockSize, blockCount uint, size uint64, format int) (*checksumFile, error) {
+	if format == 0 {
+		format = ChecksumFormatV1
+	}
 
-	if file, err = os.OpenFile(filename+".checksum", os.O_WRONLY|os.O_CREATE, 0644); err != nil {
+	if format != ChecksumFormatV1 && format != ChecksumFormatV2 {
+		return nil, errors.New("unknown checksum file format")
+	}
+
+	file, err := os.OpenFile(filename+".checksum", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		if err != nil {
-			file.Close()
-		}
-	}()
+	checksumFile, err := newChecksumFile(file, hasher, blockSize, blockCount, size, format)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return checksumFile, nil
+}
+
+// newChecksumFile writes checksum header of given format to file.
+func newChecksumFile(file *os.File, hasher xhash.Hash, blockSize, blockCount uint, size uint64, format int) (*checksumFile, error) {
+	var err error
 
-	hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
 	header := &checksumHeader{
 		HashName:   hasher.Name(),
 		HashKey:    hasher.HashKey(),
@@ -51,17 +163,65 @@ func createChecksumFile(filename string, blockSize, blockCount uint, size uint64
 		DataLength: size,
 	}
 
-	if err = json.NewEncoder(file).Encode(header); err != nil {
+	sumLength := uint(hasher.Size())
+	var data []byte
+	if format == ChecksumFormatV1 {
+		if data, err = json.Marshal(header); err == nil {
+			data = append(data, '\n')
+		}
+	} else {
+		data, err = header.marshalBinary(format, sumLength)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = file.Write(data); err != nil {
 		return nil, err
 	}
 
 	return &checksumFile{
-		File:   file,
-		hasher: hasher,
-		header: header,
+		File:         file,
+		hasher:       hasher,
+		header:       header,
+		format:       format,
+		sumLength:    sumLength,
+		headerLength: len(data),
 	}, nil
 }
 
+// setLength rewrites checksum header with given block count and data length, e.g. once data of UnknownSize is
+// written. JSON header of ChecksumFormatV1 is padded by spaces to its written length.
+func (file *checksumFile) setLength(blockCount uint, size uint64) error {
+	header := *file.header
+	header.BlockCount = blockCount
+	header.DataLength = size
+
+	var data []byte
+	var err error
+	if file.format == ChecksumFormatV1 {
+		if data, err = json.Marshal(header); err != nil {
+			return err
+		}
+
+		if len(data) >= file.headerLength {
+			return errors.New("checksum header exceeds written length")
+		}
+
+		data = append(append(data, bytes.Repeat([]byte(" "), file.headerLength-len(data)-1)...), '\n')
+	} else if data, err = header.marshalBinary(file.format, file.sumLength); err != nil {
+		return err
+	}
+
+	if _, err = file.File.WriteAt(data, 0); err != nil {
+		return err
+	}
+
+	*file.header = header
+	return nil
+}
+
 func openChecksumFile(filename string) (*checksumFile, error) {
 	var file *os.File
 	var err error
@@ -86,6 +246,16 @@ func openChecksumFile(filename string) (*checksumFile, error) {
 		}
 
 		data = append(data, buf[:n]...)
+		if bytes.HasPrefix(data, []byte(checksumV2Magic)) {
+			if len(data) < checksumV2HeaderSize {
+				err = io.ErrUnexpectedEOF
+				return nil, err
+			}
+
+			data = data[:checksumV2HeaderSize]
+			break
+		}
+
 		if i := bytes.IndexRune(data, '\n'); i >= 0 {
 			data = data[:i+1]
 			break
@@ -96,13 +266,74 @@ func openChecksumFile(filename string) (*checksumFile, error) {
 		return nil, err
 	}
 
+	format := ChecksumFormatV1
+	var sumLength uint
 	header := new(checksumHeader)
-	if err = json.Unmarshal(data, header); err != nil {
+	if bytes.HasPrefix(data, []byte(checksumV2Magic)) {
+		format, sumLength, err = header.unmarshalBinary(data)
+		if err == nil && format != ChecksumFormatV2 {
+			err = errors.New("unsupported checksum file version")
+		}
+	} else {
+		err = json.Unmarshal(data, header)
+	}
+
+	if err != nil {
 		return nil, err
 	}
 
-	var key []byte
-	if key, err = hex.DecodeString(header.HashKey); err != nil {
+	var checksumFile *checksumFile
+	checksumFile, err = loadChecksumFile(file, header, format, sumLength)
+	return checksumFile, err
+}
+
+// openInlineChecksumFile reads ChecksumFormatInline header of data file. Returned checksum file shares
+// given file and is positioned at first block.
+func openInlineChecksumFile(file *os.File) (*checksumFile, error) {
+	data := make([]byte, checksumV2HeaderSize)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, err
+	}
+
+	header := new(checksumHeader)
+	format, sumLength, err := header.unmarshalBinary(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if format != ChecksumFormatInline {
+		return nil, errors.New("not an inline checksummed file")
+	}
+
+	return loadChecksumFile(file, header, format, sumLength)
+}
+
+// validate returns error if block layout of header is inconsistent, e.g. of corrupt ChecksumFormatV1 header which
+// has no checksum, so that readers never divide by zero block size or read beyond blocks.
+func (header *checksumHeader) validate() error {
+	if header.BlockSize == 0 {
+		return errors.New("invalid checksum header; zero block size")
+	}
+
+	blockCount := header.DataLength / uint64(header.BlockSize)
+	if blockCount*uint64(header.BlockSize) < header.DataLength {
+		blockCount++
+	}
+
+	if uint64(header.BlockCount) != blockCount {
+		return fmt.Errorf("invalid checksum header; block count %v does not match data length %v of block size %v", header.BlockCount, header.DataLength, header.BlockSize)
+	}
+
+	return nil
+}
+
+func loadChecksumFile(file *os.File, header *checksumHeader, format int, sumLength uint) (*checksumFile, error) {
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(header.HashKey)
+	if err != nil {
 		return nil, err
 	}
 
@@ -110,36 +341,86 @@ func openChecksumFile(filename string) (*checksumFile, error) {
 		key = nil
 	}
 
-	var hasher xhash.Hash
-	if hasher, err = xhash.NewHash(header.HashName, key); err != nil {
+	hasher, err := xhash.NewHash(header.HashName, key)
+	if err != nil {
 		return nil, err
 	}
 
+	if format != ChecksumFormatV1 && sumLength != uint(hasher.Size()) {
+		return nil, errors.New("checksum length mismatch")
+	}
+
 	return &checksumFile{
-		File:   file,
-		header: header,
-		hasher: hasher,
+		File:      file,
+		header:    header,
+		hasher:    hasher,
+		format:    format,
+		sumLength: sumLength,
 	}, nil
 }
 
+// IsInlineFile returns whether given file is written with ChecksumFormatInline.
+func IsInlineFile(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+
+	_, err = openInlineChecksumFile(file)
+	return err == nil
+}
+
 func (file *checksumFile) Write(b []byte) (n int, err error) {
 	file.hasher.Reset()
 	if n, err = file.hasher.Write(b); err != nil {
 		return n, err
 	}
 
-	_, err = file.File.WriteString(file.hasher.HexSum(nil) + "\n")
+	switch file.format {
+	case ChecksumFormatV1:
+		_, err = file.File.WriteString(file.hasher.HexSum(nil) + "\n")
+	case ChecksumFormatInline:
+		if _, err = file.File.Write(b); err != nil {
+			return 0, err
+		}
+		fallthrough
+	default:
+		_, err = file.File.Write(file.hasher.Sum(nil))
+	}
 
 	return n, err
 }
 
 func (file *checksumFile) Skip(blockCount uint) error {
-	bytesToSkip := int64(blockCount * (file.header.HashLength + 1)) // skip hashes including '\n'
+	var bytesToSkip int64
+	switch file.format {
+	case ChecksumFormatV1:
+		bytesToSkip = int64(blockCount * (file.header.HashLength + 1)) // skip hashes including '\n'
+	case ChecksumFormatInline:
+		bytesToSkip = int64(blockCount * (file.header.BlockSize + file.sumLength))
+	default:
+		bytesToSkip = int64(blockCount * file.sumLength)
+	}
+
 	_, err := file.File.Seek(bytesToSkip, io.SeekCurrent)
 	return err
 }
 
 func (file *checksumFile) ReadSum() (string, error) {
+	if file.format != ChecksumFormatV1 {
+		if file.buf == nil {
+			file.buf = make([]byte, file.sumLength)
+		}
+
+		if _, err := io.ReadFull(file, file.buf); err != nil {
+			return "", err
+		}
+
+		return hex.EncodeToString(file.buf), nil
+	}
+
 	if file.buf == nil {
 		file.buf = make([]byte, file.header.HashLength+1)
 	}
@@ -10,6 +10,7 @@ import (
 type checksummedReader struct {
 	file         *os.File
 	checksumFile *checksumFile
+	inline       bool // checksumFile shares file; each block is followed by its checksum.
 
 	blocksToRead            int64
 	bytesToSkipInFirstBlock int64
@@ -36,15 +37,24 @@ func (reader *checksummedReader) readBlock() error {
 		reader.block = reader.block[:reader.lastBlockSize]
 	}
 
-	checksum, err := reader.checksumFile.ReadSum()
-	if err != nil {
-		return err
+	var checksum string
+	var err error
+	if !reader.inline {
+		if checksum, err = reader.checksumFile.ReadSum(); err != nil {
+			return err
+		}
 	}
 
 	if _, err = io.ReadFull(reader.file, reader.block); err != nil {
 		return err
 	}
 
+	if reader.inline {
+		if checksum, err = reader.checksumFile.ReadSum(); err != nil {
+			return err
+		}
+	}
+
 	reader.checksumFile.hasher.Reset()
 	if _, err = reader.checksumFile.hasher.Write(reader.block); err != nil {
 		return err
@@ -91,6 +101,10 @@ func (reader *checksummedReader) Read(b []byte) (n int, err error) {
 }
 
 func (reader *checksummedReader) Close() error {
+	if reader.inline {
+		return reader.file.Close()
+	}
+
 	err1 := reader.file.Close()
 	err2 := reader.checksumFile.Close()
 
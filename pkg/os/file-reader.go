@@ -0,0 +1,297 @@
+package os
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileReader is random access reader of a file written by WriteFile().
+type FileReader interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+	Size() int64
+}
+
+type plainFile struct {
+	*os.File
+	size int64
+}
+
+func (file *plainFile) Size() int64 {
+	return file.size
+}
+
+// blockCache keeps recently verified blocks in least recently used order.
+type blockCache struct {
+	capacity int
+	list     *list.List
+	entries  map[int64]*list.Element
+}
+
+type cachedBlock struct {
+	index int64
+	data  []byte
+}
+
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{
+		capacity: capacity,
+		list:     list.New(),
+		entries:  make(map[int64]*list.Element),
+	}
+}
+
+func (cache *blockCache) get(index int64) ([]byte, bool) {
+	element, found := cache.entries[index]
+	if !found {
+		return nil, false
+	}
+
+	cache.list.MoveToFront(element)
+	return element.Value.(*cachedBlock).data, true
+}
+
+func (cache *blockCache) put(index int64, data []byte) {
+	if cache.capacity <= 0 {
+		return
+	}
+
+	if element, found := cache.entries[index]; found {
+		element.Value.(*cachedBlock).data = data
+		cache.list.MoveToFront(element)
+		return
+	}
+
+	cache.entries[index] = cache.list.PushFront(&cachedBlock{index: index, data: data})
+	if cache.list.Len() > cache.capacity {
+		element := cache.list.Back()
+		cache.list.Remove(element)
+		delete(cache.entries, element.Value.(*cachedBlock).index)
+	}
+}
+
+// checksummedFile verifies only blocks touched by reads.
+type checksummedFile struct {
+	file         *os.File
+	checksumFile *checksumFile
+	inline       bool
+	sumOffset    int64 // Offset of first checksum in checksum file or first block in inline file.
+	size         int64
+	offset       int64
+
+	mutex sync.Mutex
+	cache *blockCache
+	sum   []byte
+}
+
+func (file *checksummedFile) blockLength(index int64) int64 {
+	blockSize := int64(file.checksumFile.header.BlockSize)
+	if length := file.size - index*blockSize; length < blockSize {
+		return length
+	}
+
+	return blockSize
+}
+
+func (file *checksummedFile) readSum(index, blockLength int64) (err error) {
+	header := file.checksumFile.header
+	switch {
+	case file.inline:
+		offset := file.sumOffset + index*int64(header.BlockSize+file.checksumFile.sumLength) + blockLength
+		_, err = file.file.ReadAt(file.sum, offset)
+	case file.checksumFile.format == ChecksumFormatV1:
+		_, err = file.checksumFile.ReadAt(file.sum, file.sumOffset+index*int64(header.HashLength+1))
+	default:
+		_, err = file.checksumFile.ReadAt(file.sum, file.sumOffset+index*int64(file.checksumFile.sumLength))
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// readBlock returns verified block of given index. Returned data must not be modified.
+func (file *checksummedFile) readBlock(index int64) ([]byte, error) {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+
+	if data, found := file.cache.get(index); found {
+		return data, nil
+	}
+
+	blockLength := file.blockLength(index)
+	offset := index * int64(file.checksumFile.header.BlockSize)
+	if file.inline {
+		offset = file.sumOffset + index*int64(file.checksumFile.header.BlockSize+file.checksumFile.sumLength)
+	}
+
+	data := make([]byte, blockLength)
+	if _, err := file.file.ReadAt(data, offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	if err := file.readSum(index, blockLength); err != nil {
+		return nil, err
+	}
+
+	hasher := file.checksumFile.hasher
+	hasher.Reset()
+	hasher.Write(data)
+	if file.checksumFile.format == ChecksumFormatV1 {
+		if checksum := hasher.HexSum(nil); checksum != string(file.sum) {
+			return nil, fmt.Errorf("checksum mismatch; expected: %v, got: %v", string(file.sum), checksum)
+		}
+	} else if checksum := hasher.Sum(nil); !bytes.Equal(checksum, file.sum) {
+		return nil, fmt.Errorf("checksum mismatch; expected: %x, got: %x", file.sum, checksum)
+	}
+
+	file.cache.put(index, data)
+	return data, nil
+}
+
+// ReadAt reads len(b) bytes from offset off. Only blocks covering the range are read and verified.
+func (file *checksummedFile) ReadAt(b []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	blockSize := int64(file.checksumFile.header.BlockSize)
+	for n < len(b) {
+		if off >= file.size {
+			return n, io.EOF
+		}
+
+		index := off / blockSize
+		data, err := file.readBlock(index)
+		if err != nil {
+			return n, err
+		}
+
+		copied := copy(b[n:], data[off-index*blockSize:])
+		n += copied
+		off += int64(copied)
+	}
+
+	return n, nil
+}
+
+func (file *checksummedFile) Read(b []byte) (n int, err error) {
+	n, err = file.ReadAt(b, file.offset)
+	file.offset += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (file *checksummedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += file.offset
+	case io.SeekEnd:
+		offset += file.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	file.offset = offset
+	return offset, nil
+}
+
+func (file *checksummedFile) Size() int64 {
+	return file.size
+}
+
+func (file *checksummedFile) Close() error {
+	err1 := file.file.Close()
+	if file.inline {
+		return err1
+	}
+
+	err2 := file.checksumFile.Close()
+	if err1 != nil {
+		if err2 != nil {
+			return errors.New("multiple close error")
+		}
+
+		return err1
+	}
+
+	return err2
+}
+
+// OpenFileReader opens given file for random access. If bitrotProtection is set, each block is verified
+// when it is read first and up to cacheBlocks recently verified blocks are kept in memory. Checksum header
+// is read once on open.
+func OpenFileReader(filename string, bitrotProtection bool, cacheBlocks int) (FileReader, error) {
+	var file *os.File
+	var err error
+	if file, err = os.Open(filename); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			file.Close()
+		}
+	}()
+
+	if !bitrotProtection {
+		var fi os.FileInfo
+		if fi, err = file.Stat(); err != nil {
+			return nil, err
+		}
+
+		return &plainFile{File: file, size: fi.Size()}, nil
+	}
+
+	var checksumFile *checksumFile
+	var inline bool
+	if checksumFile, inline, err = openChecksum(filename, file); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil && !inline {
+			checksumFile.Close()
+		}
+	}()
+
+	var sumOffset int64
+	if sumOffset, err = checksumFile.Seek(0, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+
+	sumLength := checksumFile.header.HashLength
+	if checksumFile.format != ChecksumFormatV1 {
+		sumLength = checksumFile.sumLength
+	}
+
+	return &checksummedFile{
+		file:         file,
+		checksumFile: checksumFile,
+		inline:       inline,
+		sumOffset:    sumOffset,
+		size:         int64(checksumFile.header.DataLength),
+		cache:        newBlockCache(cacheBlocks),
+		sum:          make([]byte, sumLength),
+	}, nil
+}
@@ -0,0 +1,212 @@
+package os
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	xhash "github.com/balamurugana/goat/pkg/hash"
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+func TestOpenFileReader(t *testing.T) {
+	testCases := []struct {
+		opts             WriteOptions
+		bitrotProtection bool
+	}{
+		{WriteOptions{}, false},
+		{WriteOptions{BitrotProtection: true, BlockSize: 4096}, true},
+		{WriteOptions{BitrotProtection: true, BlockSize: 4096, HashName: xhash.SHA256Algorithm, ChecksumFormat: ChecksumFormatV2}, true},
+		{WriteOptions{BitrotProtection: true, BlockSize: 4096, ChecksumFormat: ChecksumFormatInline}, true},
+		{WriteOptions{BitrotProtection: true}, true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				filename := xrand.NewID(8).String()
+				if _, err := WriteFileWithOptions(filename, randReader(), 16279, testCase.opts); err != nil {
+					t.Fatal(err)
+				}
+
+				defer func() {
+					if err := RemoveFile(filename, testCase.bitrotProtection); err != nil {
+						t.Error(err)
+					}
+				}()
+
+				fr, err := OpenFileReader(filename, testCase.bitrotProtection, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer func() {
+					if err := fr.Close(); err != nil {
+						t.Error(err)
+					}
+				}()
+
+				if fr.Size() != 16279 {
+					t.Fatalf("mismatch: size: expected: 16279, got: %v", fr.Size())
+				}
+
+				checksum := func(r io.Reader) string {
+					hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+					if _, err := io.Copy(hasher, r); err != nil {
+						t.Fatal(err)
+					}
+
+					return hasher.HexSum(nil)
+				}
+
+				// Random access in any order including block boundaries.
+				ranges := []struct {
+					offset, length int64
+					checksum       string
+				}{
+					{12958, 3321, "080128a5fef0be24af237cf201080e2c74444fbd22e6cb5d7f24cd6580c51c99"},
+					{10, 7, "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25"},
+					{0, 10, "cb681256c303aaacfc24ed94cb5ffd6a84fcde8a6721213b0a757ba40ac4a4a9"},
+					{0, 16279, "cfdb0f1b0043595e8913f22af69eead850eb249dffb41f545495cbe6dee9240b"},
+				}
+
+				for _, r := range ranges {
+					if got := checksum(io.NewSectionReader(fr, r.offset, r.length)); got != r.checksum {
+						t.Fatalf("range %v-%v: expected: %v, got: %v", r.offset, r.length, r.checksum, got)
+					}
+				}
+
+				if _, err = fr.Seek(10, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+
+				if got := checksum(io.LimitReader(fr, 7)); got != ranges[1].checksum {
+					t.Fatalf("seek: expected: %v, got: %v", ranges[1].checksum, got)
+				}
+
+				if _, err = fr.ReadAt(make([]byte, 10), 16270); err != io.EOF {
+					t.Fatalf("mismatch: expected: %v, got: %v", io.EOF, err)
+				}
+			},
+		)
+	}
+
+	t.Run(
+		"corrupted-block",
+		func(t *testing.T) {
+			filename := xrand.NewID(8).String()
+			opts := WriteOptions{BitrotProtection: true, BlockSize: 4096, ChecksumFormat: ChecksumFormatV2}
+			if _, err := WriteFileWithOptions(filename, randReader(), 16279, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			defer RemoveFile(filename, true)
+
+			file, err := os.OpenFile(filename, os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = file.WriteAt([]byte{0, 0, 0, 0}, 2*4096+100); err != nil {
+				file.Close()
+				t.Fatal(err)
+			}
+			file.Close()
+
+			fr, err := OpenFileReader(filename, true, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer fr.Close()
+
+			if _, err = fr.ReadAt(make([]byte, 4096), 4096); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = fr.ReadAt(make([]byte, 100), 3*4096); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = fr.ReadAt(make([]byte, 10), 2*4096); err == nil {
+				t.Fatalf("expected: <checksum mismatch>, got: <nil>")
+			}
+		},
+	)
+}
+
+func TestBlockCache(t *testing.T) {
+	cache := newBlockCache(2)
+	cache.put(0, []byte("0"))
+	cache.put(1, []byte("1"))
+	cache.get(0)
+	cache.put(2, []byte("2"))
+
+	if _, found := cache.get(1); found {
+		t.Fatalf("block 1: expected: <evicted>, got: <found>")
+	}
+
+	for _, index := range []int64{0, 2} {
+		if data, found := cache.get(index); !found || string(data) != fmt.Sprint(index) {
+			t.Fatalf("block %v: expected: %v, got: %v", index, index, string(data))
+		}
+	}
+
+	cache = newBlockCache(0)
+	cache.put(0, []byte("0"))
+	if _, found := cache.get(0); found {
+		t.Fatalf("block 0: expected: <not cached>, got: <found>")
+	}
+}
+
+func TestOpenCorruptHeader(t *testing.T) {
+	testCases := []struct {
+		old string
+		new string
+	}{
+		{`"blockSize":4096,`, `"blockSize":0   ,`},
+		{`"blockCount":4,`, `"blockCount":9,`},
+		{`"dataLength":16279`, `"dataLength":96279`},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				filename := xrand.NewID(8).String()
+				opts := WriteOptions{BitrotProtection: true, BlockSize: 4096, ChecksumFormat: ChecksumFormatV1}
+				if _, err := WriteFileWithOptions(filename, randReader(), 16279, opts); err != nil {
+					t.Fatal(err)
+				}
+
+				defer RemoveFile(filename, true)
+
+				data, err := os.ReadFile(filename + ".checksum")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				corrupted := bytes.Replace(data, []byte(testCase.old), []byte(testCase.new), 1)
+				if bytes.Equal(corrupted, data) {
+					t.Fatalf("%v: not found in header", testCase.old)
+				}
+
+				if err = os.WriteFile(filename+".checksum", corrupted, 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				if rc, err := OpenFile(filename, 0, 16279, true); err == nil {
+					rc.Close()
+					t.Fatalf("OpenFile: expected: <error>, got: <nil>")
+				}
+
+				if fr, err := OpenFileReader(filename, true, 2); err == nil {
+					fr.Close()
+					t.Fatalf("OpenFileReader: expected: <error>, got: <nil>")
+				}
+			},
+		)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 
 	"github.com/balamurugana/goat/pkg/boundary"
@@ -13,6 +14,10 @@ import (
 
 const defaultBlockSize = 1024 * 1024 // 1 MiB
 
+// UnknownSize is size of data whose length is known only at EOF, e.g. a chunked upload without content length.
+// Data is written until EOF and checksum header is rewritten with actual block count and data length.
+const UnknownSize = math.MaxUint64
+
 type SectionFileReader struct {
 	file   *os.File
 	reader *io.SectionReader
@@ -33,7 +38,47 @@ func NewSectionFileReader(file *os.File, offset, length int64) *SectionFileReade
 	}
 }
 
+// WriteOptions controls checksumming of WriteFileWithOptions().
+type WriteOptions struct {
+	BitrotProtection bool
+	BlockSize        uint64 // Checksum block size; 1 MiB if zero.
+	HashName         string // Checksum hash algorithm; HighwayHash256 if empty.
+	HashKey          []byte // Checksum hash key; default key of hash algorithm if nil.
+	ChecksumFormat   int    // Checksum file format; ChecksumFormatV1 if zero.
+}
+
+func (opts WriteOptions) newHash() (xhash.Hash, error) {
+	hashName := opts.HashName
+	if hashName == "" {
+		hashName = xhash.HighwayHash256Algorithm
+	}
+
+	return xhash.NewHash(hashName, opts.HashKey)
+}
+
 func WriteFile(filename string, data io.Reader, size uint64, bitrotProtection bool) (checksum string, err error) {
+	return writeFile(filename, data, size, WriteOptions{BitrotProtection: bitrotProtection}, false)
+}
+
+// WriteFileWithOptions is same as WriteFile() with given checksum block size and hash algorithm. Returned
+// checksum of whole data is always HighwayHash256 with default key.
+func WriteFileWithOptions(filename string, data io.Reader, size uint64, opts WriteOptions) (checksum string, err error) {
+	return writeFile(filename, data, size, opts, false)
+}
+
+func writeFile(filename string, data io.Reader, size uint64, opts WriteOptions, sync bool) (checksum string, err error) {
+	blockSize := opts.BlockSize
+	if blockSize == 0 {
+		blockSize = defaultBlockSize
+	}
+
+	var hasher xhash.Hash
+	if opts.BitrotProtection {
+		if hasher, err = opts.newHash(); err != nil {
+			return "", err
+		}
+	}
+
 	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return "", err
@@ -43,38 +88,87 @@ func WriteFile(filename string, data io.Reader, size uint64, bitrotProtection bo
 
 	hashWriter := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
 	var writer io.Writer
+	var checksumFile *checksumFile
 
-	blockCount := size / defaultBlockSize
-	if blockCount*defaultBlockSize < size {
+	blockCount := size / blockSize
+	if blockCount*blockSize < size {
 		blockCount++
 	}
 
-	if bitrotProtection {
-		checksumFile, err := createChecksumFile(filename, defaultBlockSize, uint(blockCount), size)
-		if err != nil {
+	switch {
+	case opts.BitrotProtection && opts.ChecksumFormat == ChecksumFormatInline:
+		if checksumFile, err = newChecksumFile(file, hasher, uint(blockSize), uint(blockCount), size, ChecksumFormatInline); err != nil {
+			return "", err
+		}
+
+		writer = io.MultiWriter(checksumFile, hashWriter)
+	case opts.BitrotProtection:
+		if checksumFile, err = createChecksumFile(filename, hasher, uint(blockSize), uint(blockCount), size, opts.ChecksumFormat); err != nil {
 			return "", err
 		}
 
 		defer checksumFile.Close()
 
 		writer = io.MultiWriter(file, checksumFile, hashWriter)
-	} else {
+	default:
 		writer = io.MultiWriter(file, hashWriter)
 	}
 
-	buf := make([]byte, defaultBlockSize)
+	buf := make([]byte, blockSize)
+
+	if size == UnknownSize {
+		size = 0
+		for blockCount = 0; ; blockCount++ {
+			var n int
+			if n, err = io.ReadFull(data, buf); errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return "", err
+			}
+
+			if _, err = writer.Write(buf[:n]); err != nil {
+				return "", err
+			}
+
+			size += uint64(n)
+			if uint64(n) < blockSize {
+				blockCount++
+				break
+			}
+		}
 
-	for i := uint64(0); i < blockCount; i++ {
-		if i == (blockCount - 1) {
-			buf = buf[:size-i*defaultBlockSize]
+		if checksumFile != nil {
+			if err = checksumFile.setLength(uint(blockCount), size); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		for i := uint64(0); i < blockCount; i++ {
+			if i == (blockCount - 1) {
+				buf = buf[:size-i*blockSize]
+			}
+
+			if _, err = io.ReadFull(data, buf); err != nil {
+				return "", err
+			}
+
+			if _, err = writer.Write(buf); err != nil {
+				return "", err
+			}
 		}
+	}
 
-		if _, err = io.ReadFull(data, buf); err != nil {
+	if sync {
+		if err = syncFile(file); err != nil {
 			return "", err
 		}
 
-		if _, err = writer.Write(buf); err != nil {
-			return "", err
+		if checksumFile != nil && checksumFile.File != file {
+			if err = syncFile(checksumFile.File); err != nil {
+				return "", err
+			}
 		}
 	}
 
@@ -84,6 +178,10 @@ func WriteFile(filename string, data io.Reader, size uint64, bitrotProtection bo
 func RemoveFile(filename string, bitrotProtection bool) error {
 	var err2 error
 
+	if bitrotProtection && !Exist(filename+".checksum") && IsInlineFile(filename) {
+		bitrotProtection = false
+	}
+
 	err1 := os.Remove(filename)
 	if bitrotProtection {
 		err2 = os.Remove(filename + ".checksum")
@@ -100,6 +198,19 @@ func RemoveFile(filename string, bitrotProtection bool) error {
 	return err2
 }
 
+// openChecksum opens companion checksum file of filename. If it does not exist, inline checksum header of
+// given data file is read instead.
+func openChecksum(filename string, file *os.File) (checksumFile *checksumFile, inline bool, err error) {
+	if checksumFile, err = openChecksumFile(filename); errors.Is(err, os.ErrNotExist) {
+		var inlineErr error
+		if checksumFile, inlineErr = openInlineChecksumFile(file); inlineErr == nil {
+			return checksumFile, true, nil
+		}
+	}
+
+	return checksumFile, false, err
+}
+
 func OpenFile(filename string, offset int64, length uint64, bitrotProtection bool) (io.ReadCloser, error) {
 	var file *os.File
 	var err error
@@ -137,12 +248,13 @@ func OpenFile(filename string, offset int64, length uint64, bitrotProtection boo
 	}
 
 	var checksumFile *checksumFile
-	if checksumFile, err = openChecksumFile(filename); err != nil {
+	var inline bool
+	if checksumFile, inline, err = openChecksum(filename, file); err != nil {
 		return nil, err
 	}
 
 	defer func() {
-		if err != nil {
+		if err != nil && !inline {
 			checksumFile.Close()
 		}
 	}()
@@ -169,18 +281,21 @@ func OpenFile(filename string, offset int64, length uint64, bitrotProtection boo
 		return nil, err
 	}
 
-	bytesToSkip := blocksToSkip * int64(checksumFile.header.BlockSize)
-	if checksumFile.header.BlockCount <= 1 {
-		bytesToSkip = blocksToSkip * lastBlockSize
-	}
+	if !inline {
+		bytesToSkip := blocksToSkip * int64(checksumFile.header.BlockSize)
+		if checksumFile.header.BlockCount <= 1 {
+			bytesToSkip = blocksToSkip * lastBlockSize
+		}
 
-	if _, err = file.Seek(bytesToSkip, io.SeekStart); err != nil {
-		return nil, err
+		if _, err = file.Seek(bytesToSkip, io.SeekStart); err != nil {
+			return nil, err
+		}
 	}
 
 	return &checksummedReader{
 		file:                    file,
 		checksumFile:            checksumFile,
+		inline:                  inline,
 		blocksToRead:            blocksToRead,
 		bytesToSkipInFirstBlock: bytesToSkipInFirstBlock,
 		bytesToReadInLastBlock:  bytesToReadInLastBlock,
@@ -189,6 +304,10 @@ func OpenFile(filename string, offset int64, length uint64, bitrotProtection boo
 }
 
 func RenameFile(oldname, newname string, bitrotProtection bool) error {
+	if bitrotProtection && !Exist(oldname+".checksum") && IsInlineFile(oldname) {
+		bitrotProtection = false
+	}
+
 	if bitrotProtection {
 		if err := os.Rename(oldname+".checksum", newname+".checksum"); err != nil {
 			return err
@@ -198,7 +317,26 @@ func RenameFile(oldname, newname string, bitrotProtection bool) error {
 	return os.Rename(oldname, newname)
 }
 
+// LinkFile creates newname as hard link of oldname including its checksum file if bitrotProtection is set.
+func LinkFile(oldname, newname string, bitrotProtection bool) error {
+	if bitrotProtection && !Exist(oldname+".checksum") && IsInlineFile(oldname) {
+		bitrotProtection = false
+	}
+
+	if bitrotProtection {
+		if err := os.Link(oldname+".checksum", newname+".checksum"); err != nil {
+			return err
+		}
+	}
+
+	return os.Link(oldname, newname)
+}
+
 func WriteJSONFile(filename string, inter interface{}) error {
+	return writeJSONFile(filename, inter, false)
+}
+
+func writeJSONFile(filename string, inter interface{}, sync bool) error {
 	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
@@ -206,7 +344,15 @@ func WriteJSONFile(filename string, inter interface{}) error {
 
 	defer file.Close()
 
-	return json.NewEncoder(file).Encode(inter)
+	if err = json.NewEncoder(file).Encode(inter); err != nil {
+		return err
+	}
+
+	if sync {
+		return syncFile(file)
+	}
+
+	return nil
 }
 
 func ReadJSONFile(filename string, limit int64, inter interface{}) error {
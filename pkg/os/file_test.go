@@ -1,8 +1,11 @@
 package os
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"reflect"
@@ -273,3 +276,325 @@ func TestOpenFile(t *testing.T) {
 		)
 	}
 }
+
+func TestWriteFileWithOptions(t *testing.T) {
+	hashKey := make([]byte, 32)
+	for i := range hashKey {
+		hashKey[i] = byte(i * 7)
+	}
+
+	testCases := []struct {
+		size   uint64
+		opts   WriteOptions
+		header *checksumHeader
+		offset int64
+		length uint64
+		hash   string
+	}{
+		{
+			size: 16279,
+			opts: WriteOptions{BitrotProtection: true, BlockSize: 4096, HashName: xhash.SHA256Algorithm},
+			header: &checksumHeader{
+				HashName:   "SHA256",
+				HashLength: 64,
+				BlockSize:  4096,
+				BlockCount: 4,
+				DataLength: 16279,
+			},
+			offset: 10,
+			length: 7,
+			hash:   "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25",
+		},
+		{
+			size: 70009289,
+			opts: WriteOptions{BitrotProtection: true, BlockSize: 10 * 1024 * 1024, HashKey: hashKey},
+			header: &checksumHeader{
+				HashName:   "HighwayHash256",
+				HashKey:    hex.EncodeToString(hashKey),
+				HashLength: 64,
+				BlockSize:  10485760,
+				BlockCount: 7,
+				DataLength: 70009289,
+			},
+			offset: 3145649,
+			length: 1048986,
+			hash:   "3faf5850c140d6f2ad36e0ba7324d306e1589d50fc17fa0cc1a1ccbf76d87332",
+		},
+		{
+			size: 16279,
+			opts: WriteOptions{BitrotProtection: true, BlockSize: 4096, ChecksumFormat: ChecksumFormatV2},
+			header: &checksumHeader{
+				HashName:   "HighwayHash256",
+				HashKey:    "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20",
+				HashLength: 64,
+				BlockSize:  4096,
+				BlockCount: 4,
+				DataLength: 16279,
+			},
+			offset: 10,
+			length: 7,
+			hash:   "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				filename := xrand.NewID(8).String()
+
+				if _, err := WriteFileWithOptions(filename, randReader(), testCase.size, testCase.opts); err != nil {
+					t.Fatal(err)
+				}
+
+				defer func() {
+					if err := RemoveFile(filename, true); err != nil {
+						t.Error(err)
+					}
+				}()
+
+				csfile, err := openChecksumFile(filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				csfile.Close()
+
+				if !reflect.DeepEqual(testCase.header, csfile.header) {
+					t.Fatalf("header mismatch. expected: %+v, got: %+v", testCase.header, csfile.header)
+				}
+
+				rc, err := OpenFile(filename, testCase.offset, testCase.length, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer func() {
+					if err := rc.Close(); err != nil {
+						t.Error(err)
+					}
+				}()
+
+				hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+				if _, err = io.Copy(hasher, rc); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum := hasher.HexSum(nil); checksum != testCase.hash {
+					t.Fatalf("expected: %v, got: %v", testCase.hash, checksum)
+				}
+			},
+		)
+	}
+
+	t.Run(
+		"unknown-algorithm",
+		func(t *testing.T) {
+			filename := xrand.NewID(8).String()
+			opts := WriteOptions{BitrotProtection: true, HashName: "unknown"}
+			if _, err := WriteFileWithOptions(filename, randReader(), 10, opts); !errors.Is(err, xhash.ErrUnknownAlgorithm) {
+				t.Fatalf("expected: %v, got: %v", xhash.ErrUnknownAlgorithm, err)
+			}
+
+			if Exist(filename) {
+				os.Remove(filename)
+				t.Fatalf("%v: expected: <not exist>, got: <exist>", filename)
+			}
+		},
+	)
+}
+
+func TestWriteFileInline(t *testing.T) {
+	testCases := []struct {
+		size   uint64
+		opts   WriteOptions
+		offset int64
+		length uint64
+		hash   string
+	}{
+		{
+			size:   16279,
+			opts:   WriteOptions{BitrotProtection: true, BlockSize: 4096, HashName: xhash.SHA256Algorithm, ChecksumFormat: ChecksumFormatInline},
+			offset: 10,
+			length: 7,
+			hash:   "aa88fcc3f216be54199c57fd835b9921a6fd259edc834d115b6b898ccfaa4c25",
+		},
+		{
+			size:   70009289,
+			opts:   WriteOptions{BitrotProtection: true, BlockSize: 1024 * 1024, ChecksumFormat: ChecksumFormatInline},
+			offset: 3145649,
+			length: 1048986,
+			hash:   "3faf5850c140d6f2ad36e0ba7324d306e1589d50fc17fa0cc1a1ccbf76d87332",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				filename := xrand.NewID(8).String()
+				if _, err := WriteFileWithOptions(filename, randReader(), testCase.size, testCase.opts); err != nil {
+					t.Fatal(err)
+				}
+
+				if Exist(filename + ".checksum") {
+					t.Fatalf("%v.checksum: expected: <not exist>, got: <exist>", filename)
+				}
+
+				if !IsInlineFile(filename) {
+					t.Fatalf("%v: expected: <inline>, got: <not inline>", filename)
+				}
+
+				newname := xrand.NewID(8).String()
+				if err := RenameFile(filename, newname, true); err != nil {
+					os.Remove(filename)
+					t.Fatal(err)
+				}
+
+				defer func() {
+					if err := RemoveFile(newname, true); err != nil {
+						t.Error(err)
+					}
+				}()
+
+				rc, err := OpenFile(newname, testCase.offset, testCase.length, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer func() {
+					if err := rc.Close(); err != nil {
+						t.Error(err)
+					}
+				}()
+
+				hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+				if _, err = io.Copy(hasher, rc); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum := hasher.HexSum(nil); checksum != testCase.hash {
+					t.Fatalf("expected: %v, got: %v", testCase.hash, checksum)
+				}
+			},
+		)
+	}
+
+	t.Run(
+		"corrupted-block",
+		func(t *testing.T) {
+			filename := xrand.NewID(8).String()
+			opts := WriteOptions{BitrotProtection: true, BlockSize: 4096, ChecksumFormat: ChecksumFormatInline}
+			if _, err := WriteFileWithOptions(filename, randReader(), 16279, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			defer os.Remove(filename)
+
+			file, err := os.OpenFile(filename, os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = file.WriteAt([]byte{0, 0, 0, 0}, checksumV2HeaderSize+4096+32+100); err != nil {
+				file.Close()
+				t.Fatal(err)
+			}
+			file.Close()
+
+			// First block is intact.
+			rc, err := OpenFile(filename, 0, 4096, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rc, err = OpenFile(filename, 4096, 10, true); err != nil {
+				t.Fatal(err)
+			}
+			_, err = ioutil.ReadAll(rc)
+			rc.Close()
+			if err == nil {
+				t.Fatalf("expected: <checksum mismatch>, got: <nil>")
+			}
+		},
+	)
+}
+
+func TestWriteFileUnknownSize(t *testing.T) {
+	testCases := []struct {
+		size       uint64
+		opts       WriteOptions
+		blockCount uint
+	}{
+		{0, WriteOptions{BitrotProtection: true, BlockSize: 4096}, 0},
+		{16279, WriteOptions{BitrotProtection: true, BlockSize: 4096}, 4},
+		{16384, WriteOptions{BitrotProtection: true, BlockSize: 4096, ChecksumFormat: ChecksumFormatV2}, 4},
+		{16279, WriteOptions{BitrotProtection: true, BlockSize: 4096, ChecksumFormat: ChecksumFormatInline}, 4},
+		{16279, WriteOptions{BlockSize: 4096}, 0},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				expectedFilename := xrand.NewID(8).String()
+				expectedChecksum, err := WriteFileWithOptions(expectedFilename, randReader(), testCase.size, testCase.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer RemoveFile(expectedFilename, testCase.opts.BitrotProtection)
+
+				filename := xrand.NewID(8).String()
+				checksum, err := WriteFileWithOptions(filename, io.LimitReader(randReader(), int64(testCase.size)), UnknownSize, testCase.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer RemoveFile(filename, testCase.opts.BitrotProtection)
+
+				if checksum != expectedChecksum {
+					t.Fatalf("mismatch: checksum: expected: %v, got: %v", expectedChecksum, checksum)
+				}
+
+				if testCase.opts.BitrotProtection {
+					var file *checksumFile
+					if testCase.opts.ChecksumFormat == ChecksumFormatInline {
+						var f *os.File
+						if f, err = os.Open(filename); err != nil {
+							t.Fatal(err)
+						}
+
+						file, err = openInlineChecksumFile(f)
+					} else {
+						file, err = openChecksumFile(filename)
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer file.Close()
+
+					if file.header.BlockCount != testCase.blockCount || file.header.DataLength != testCase.size {
+						t.Fatalf("mismatch: expected: %v/%v, got: %v/%v", testCase.blockCount, testCase.size, file.header.BlockCount, file.header.DataLength)
+					}
+				}
+
+				rc, err := OpenFile(filename, 0, testCase.size, testCase.opts.BitrotProtection)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer rc.Close()
+
+				hasher := xhash.MustGetNewHash(xhash.HighwayHash256Algorithm, nil)
+				if _, err = io.Copy(hasher, rc); err != nil {
+					t.Fatal(err)
+				}
+
+				if checksum = hasher.HexSum(nil); checksum != expectedChecksum {
+					t.Fatalf("mismatch: data checksum: expected: %v, got: %v", expectedChecksum, checksum)
+				}
+			},
+		)
+	}
+}
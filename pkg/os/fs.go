@@ -0,0 +1,149 @@
+package os
+
+import (
+	"io"
+	"os"
+	"path"
+)
+
+// syncFile and syncDir flush file contents and directory entries to stable storage. They are
+// variables so that tests are able to observe what is made durable.
+var (
+	syncFile = func(file *os.File) error { return file.Sync() }
+	syncDir  = fsyncDir
+)
+
+// syncDirs syncs given directories once each.
+func syncDirs(dirs ...string) error {
+	synced := make(map[string]struct{})
+	for _, dir := range dirs {
+		if _, found := synced[dir]; found {
+			continue
+		}
+
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+
+		synced[dir] = struct{}{}
+	}
+
+	return nil
+}
+
+// FS provides write functions of this package with optional durability. If Sync is set, file contents,
+// checksum companion and parent directories of created and renamed entries are synced before return.
+type FS struct {
+	Sync bool
+}
+
+// WriteFile is same as WriteFile() with durability.
+func (fs FS) WriteFile(filename string, data io.Reader, size uint64, bitrotProtection bool) (checksum string, err error) {
+	return writeFile(filename, data, size, WriteOptions{BitrotProtection: bitrotProtection}, fs.Sync)
+}
+
+// WriteFileWithOptions is same as WriteFileWithOptions() with durability.
+func (fs FS) WriteFileWithOptions(filename string, data io.Reader, size uint64, opts WriteOptions) (checksum string, err error) {
+	return writeFile(filename, data, size, opts, fs.Sync)
+}
+
+// WriteJSONFile is same as WriteJSONFile() with durability.
+func (fs FS) WriteJSONFile(filename string, inter interface{}) error {
+	return writeJSONFile(filename, inter, fs.Sync)
+}
+
+// WriteBytes writes data to given file like ioutil.WriteFile() with durability.
+func (fs FS) WriteBytes(filename string, data []byte) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if _, err = file.Write(data); err != nil {
+		return err
+	}
+
+	if fs.Sync {
+		return syncFile(file)
+	}
+
+	return nil
+}
+
+// Mkdir is same as os.Mkdir() with durability.
+func (fs FS) Mkdir(name string, perm os.FileMode) error {
+	if err := os.Mkdir(name, perm); err != nil {
+		return err
+	}
+
+	if fs.Sync {
+		return syncDir(path.Dir(name))
+	}
+
+	return nil
+}
+
+// CreatePath is same as CreatePath() with durability.
+func (fs FS) CreatePath(cpath string, tempFile string, errorOnTailExist bool) error {
+	if !fs.Sync {
+		return CreatePath(cpath, tempFile, errorOnTailExist)
+	}
+
+	dir := cpath
+	if tempFile != "" {
+		dir = path.Dir(cpath)
+	}
+
+	// Parents of directories created here need to be synced.
+	var dirs []string
+	for ; !Exist(dir) && dir != "." && dir != "/"; dir = path.Dir(dir) {
+		dirs = append(dirs, path.Dir(dir))
+	}
+
+	if err := CreatePath(cpath, tempFile, errorOnTailExist); err != nil {
+		return err
+	}
+
+	if tempFile != "" {
+		dirs = append([]string{path.Dir(cpath), path.Dir(tempFile)}, dirs...)
+	}
+
+	return syncDirs(dirs...)
+}
+
+// SyncDir syncs entries of given directory if Sync is set.
+func (fs FS) SyncDir(name string) error {
+	if fs.Sync {
+		return syncDir(name)
+	}
+
+	return nil
+}
+
+// Rename is same as os.Rename() with durability.
+func (fs FS) Rename(oldname, newname string) error {
+	if err := os.Rename(oldname, newname); err != nil {
+		return err
+	}
+
+	if fs.Sync {
+		return syncDirs(path.Dir(newname), path.Dir(oldname))
+	}
+
+	return nil
+}
+
+// RenameFile is same as RenameFile() with durability.
+func (fs FS) RenameFile(oldname, newname string, bitrotProtection bool) error {
+	if err := RenameFile(oldname, newname, bitrotProtection); err != nil {
+		return err
+	}
+
+	if fs.Sync {
+		return syncDirs(path.Dir(newname), path.Dir(oldname))
+	}
+
+	return nil
+}
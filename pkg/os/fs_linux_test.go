@@ -0,0 +1,260 @@
+package os
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	xrand "github.com/balamurugana/goat/pkg/rand"
+)
+
+// crashRecorder simulates power loss of a directory tree. It tracks what is made durable by syncFile and
+// syncDir; on crash, directory entries not synced are lost and file contents not synced are empty.
+type crashRecorder struct {
+	rootDir  string
+	rootIno  uint64
+	dirs     map[uint64]map[string]uint64 // durable entries of directory inode.
+	isDir    map[uint64]bool
+	contents map[uint64][]byte // durable contents of file inode.
+
+	syncFile func(file *os.File) error
+	syncDir  func(name string) error
+}
+
+func inode(fi os.FileInfo) uint64 {
+	return fi.Sys().(*syscall.Stat_t).Ino
+}
+
+func newCrashRecorder(rootDir string) (*crashRecorder, error) {
+	fi, err := os.Stat(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := &crashRecorder{
+		rootDir:  rootDir,
+		rootIno:  inode(fi),
+		dirs:     make(map[uint64]map[string]uint64),
+		isDir:    map[uint64]bool{inode(fi): true},
+		contents: make(map[uint64][]byte),
+		syncFile: syncFile,
+		syncDir:  syncDir,
+	}
+
+	// Everything existing before is durable.
+	var snapshot func(dir string) error
+	snapshot = func(dir string) error {
+		if err := recorder.recordDir(dir); err != nil {
+			return err
+		}
+
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, fi := range fis {
+			name := path.Join(dir, fi.Name())
+			if fi.IsDir() {
+				if err = snapshot(name); err != nil {
+					return err
+				}
+			} else if err = recorder.recordFile(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err = snapshot(rootDir); err != nil {
+		return nil, err
+	}
+
+	syncFile = func(file *os.File) error {
+		if err := recorder.recordFile(file.Name()); err != nil {
+			return err
+		}
+
+		return recorder.syncFile(file)
+	}
+
+	syncDir = func(name string) error {
+		if err := recorder.recordDir(name); err != nil {
+			return err
+		}
+
+		return recorder.syncDir(name)
+	}
+
+	return recorder, nil
+}
+
+func (recorder *crashRecorder) recordFile(name string) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	recorder.contents[inode(fi)] = data
+	return nil
+}
+
+func (recorder *crashRecorder) recordDir(name string) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	fis, err := ioutil.ReadDir(name)
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]uint64)
+	for _, fi := range fis {
+		entries[fi.Name()] = inode(fi)
+		recorder.isDir[inode(fi)] = fi.IsDir()
+	}
+
+	recorder.dirs[inode(fi)] = entries
+	return nil
+}
+
+// crash replaces root directory by its durable state and stops recording.
+func (recorder *crashRecorder) crash() error {
+	syncFile = recorder.syncFile
+	syncDir = recorder.syncDir
+
+	var restore func(dir string, ino uint64) error
+	restore = func(dir string, ino uint64) error {
+		for name, childIno := range recorder.dirs[ino] {
+			childName := path.Join(dir, name)
+			if recorder.isDir[childIno] {
+				if err := os.Mkdir(childName, 0755); err != nil {
+					return err
+				}
+
+				if err := restore(childName, childIno); err != nil {
+					return err
+				}
+			} else if err := ioutil.WriteFile(childName, recorder.contents[childIno], 0644); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	crashDir := recorder.rootDir + ".crash"
+	if err := os.Mkdir(crashDir, 0755); err != nil {
+		return err
+	}
+
+	if err := restore(crashDir, recorder.rootIno); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(recorder.rootDir); err != nil {
+		return err
+	}
+
+	return os.Rename(crashDir, recorder.rootDir)
+}
+
+func TestFSCrash(t *testing.T) {
+	testCases := []struct {
+		sync          bool
+		expectDurable bool
+	}{
+		{false, false},
+		{true, true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("test%v", i),
+			func(t *testing.T) {
+				rootDir := xrand.NewID(8).String()
+				tmpDir := path.Join(rootDir, "tmp")
+				dataDir := path.Join(rootDir, "data")
+				if err := os.MkdirAll(tmpDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				defer os.RemoveAll(rootDir)
+
+				if err := os.Mkdir(dataDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				recorder, err := newCrashRecorder(rootDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				fs := FS{Sync: testCase.sync}
+
+				if _, err = fs.WriteFile(path.Join(tmpDir, "part"), randReader(), 16279, true); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = fs.RenameFile(path.Join(tmpDir, "part"), path.Join(dataDir, "part"), true); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = fs.WriteJSONFile(path.Join(tmpDir, "data.json"), map[string]string{"key": "value"}); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = fs.CreatePath(path.Join(dataDir, "a", "b", "data.json"), path.Join(tmpDir, "data.json"), false); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = fs.Mkdir(path.Join(dataDir, "c"), 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = fs.WriteBytes(path.Join(tmpDir, "default"), []byte("version")); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = fs.Rename(path.Join(tmpDir, "default"), path.Join(dataDir, "c", "default")); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = recorder.crash(); err != nil {
+					t.Fatal(err)
+				}
+
+				rc, err := OpenFile(path.Join(dataDir, "part"), 0, 16279, true)
+				if err == nil {
+					_, err = ioutil.ReadAll(rc)
+					rc.Close()
+				}
+				if durable := err == nil; durable != testCase.expectDurable {
+					t.Fatalf("part: durable: expected: %v, got: %v; %v", testCase.expectDurable, durable, err)
+				}
+
+				data := map[string]string{}
+				err = ReadJSONFile(path.Join(dataDir, "a", "b", "data.json"), -1, &data)
+				if durable := err == nil && data["key"] == "value"; durable != testCase.expectDurable {
+					t.Fatalf("data.json: durable: expected: %v, got: %v; %v", testCase.expectDurable, durable, err)
+				}
+
+				b, err := ioutil.ReadFile(path.Join(dataDir, "c", "default"))
+				if durable := err == nil && bytes.Equal(b, []byte("version")); durable != testCase.expectDurable {
+					t.Fatalf("default: durable: expected: %v, got: %v; %v", testCase.expectDurable, durable, err)
+				}
+			},
+		)
+	}
+}
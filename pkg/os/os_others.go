@@ -8,3 +8,13 @@ func Exist(name string) bool {
 	_, err := os.Lstat(name)
 	return err == nil
 }
+
+func fsyncDir(name string) error {
+	dir, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
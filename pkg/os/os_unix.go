@@ -2,8 +2,21 @@
 
 package os
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+)
 
 func Exist(name string) bool {
 	return syscall.Access(name, syscall.F_OK) == nil
 }
+
+func fsyncDir(name string) error {
+	dir, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
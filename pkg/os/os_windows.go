@@ -16,3 +16,8 @@ func Exist(name string) bool {
 	r1, _, _ := pathFileExistsA.Call(uintptr(unsafe.Pointer(name)))
 	return r1 == 1
 }
+
+// fsyncDir is no-op as directories cannot be synced on Windows; NTFS journals directory changes.
+func fsyncDir(name string) error {
+	return nil
+}
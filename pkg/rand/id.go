@@ -21,6 +21,15 @@ func NewID(length int) *ID {
 	return &ID{value: base64.RawURLEncoding.EncodeToString(b)}
 }
 
+// ParseID returns ID of given string which must be created by NewID().
+func ParseID(s string) (*ID, error) {
+	if _, err := base64.RawURLEncoding.DecodeString(s); err != nil {
+		return nil, err
+	}
+
+	return &ID{value: s}, nil
+}
+
 func (id ID) String() string {
 	return id.value
 }
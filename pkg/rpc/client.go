@@ -53,10 +53,10 @@ func (client *Client) Call(serviceMethod string, args interface{}, reader io.Rea
 	}
 
 	response, err := client.httpClient.Post(client.serviceURL, "", body)
-	response.Body = newDrainReader(response.Body)
 	if err != nil {
 		return nil, err
 	}
+	response.Body = newDrainReader(response.Body)
 
 	if response.StatusCode != http.StatusOK {
 		response.Body.Close()